@@ -101,6 +101,7 @@ type IRestClient interface {
 	Ping() (bool, error)
 	ReadNotifications(notifications chan<- models.Notification) error
 	SendExperimentMetric(experimentName string, metricName string, experimentMetric *models.ExperimentMetric, values map[string]string) error
+	GetExperimentMetrics(experimentName string, values map[string]string) ([]models.ExperimentSubsetMetric, error)
 	GetSubsetMap(values map[string]string) (*models.DestinationsSubsetsMap, error)
 }
 
@@ -931,8 +932,8 @@ func (s *RestClient) ReadNotifications(notifications chan<- models.Notification)
 }
 
 func (s *RestClient) SendExperimentMetric(experimentName string, metricName string, experimentMetric *models.ExperimentMetric, values map[string]string) error {
-	url, _ := getUrlForResource(s.URL, s.Version, "experiments", "metrics", "", values)
-	url += "&experiment_name=" + experimentName + "&metric_name=" + metricName
+	metricURL, _ := getUrlForResource(s.URL, s.Version, "experiments", "metrics", "", values)
+	metricURL += "&experiment_name=" + url.QueryEscape(experimentName) + "&metric_name=" + url.QueryEscape(metricName)
 	strJson, jsonMarshalError := json.Marshal(*experimentMetric)
 	if jsonMarshalError != nil {
 		return jsonMarshalError
@@ -946,7 +947,7 @@ func (s *RestClient) SendExperimentMetric(experimentName string, metricName stri
 			SetBody(body).
 			SetResult(&successResponse{}).
 			SetError(&errorResponse{}).
-			Put(url)
+			Put(metricURL)
 	})
 	if err != nil {
 		return err
@@ -958,6 +959,37 @@ func (s *RestClient) SendExperimentMetric(experimentName string, metricName stri
 	return nil
 }
 
+// GetExperimentMetrics returns metric aggregates collected for each destination and subset of an experiment
+func (s *RestClient) GetExperimentMetrics(experimentName string, values map[string]string) ([]models.ExperimentSubsetMetric, error) {
+	metricsURL, _ := getUrlForResource(s.URL, s.Version, "experiments", "metrics", "", values)
+	metricsURL += "&experiment_name=" + url.QueryEscape(experimentName)
+
+	resp, err := s.fallbackToRefreshToken(func() (*resty.Response, error) {
+		return resty.R().
+			SetHeader("Content-Type", "application/json").
+			SetHeader("Accept", "application/json").
+			SetAuthToken(s.getAccessToken()).
+			SetError(&errorResponse{}).
+			Get(metricsURL)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.IsError() {
+		return nil, getError(resp)
+	}
+
+	var experimentMetrics []models.ExperimentSubsetMetric
+	unmarshalError := json.Unmarshal(resp.Body(), &experimentMetrics)
+	if unmarshalError != nil {
+		return nil, unmarshalError
+	}
+
+	return experimentMetrics, nil
+}
+
 // GetSubsetMap returns a map for easy conversion of labels to subsets
 func (s *RestClient) GetSubsetMap(values map[string]string) (*models.DestinationsSubsetsMap, error) {
 	url, _ := getUrlForResource(s.URL, s.Version, "destination", "subsets/map", "", values)
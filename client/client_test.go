@@ -149,3 +149,47 @@ func TestClientPushMetricsModel(t *testing.T) {
 	assertEqual(t, true, result)
 	assertEqual(t, nil, err)
 }
+
+func TestClientGetExperimentMetrics(t *testing.T) {
+	ts := createTestServer(func(w http.ResponseWriter, r *http.Request) {
+		t.Logf("Method: %v", r.Method)
+		t.Logf("Path: %v", r.URL.Path)
+		if r.Method == resty.MethodGet {
+			switch r.URL.Path {
+			case "/api/v1/experiments/metrics":
+				assertEqual(t, "experiment 1&a#b", r.URL.Query().Get("experiment_name"))
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`[{"destination": "dest-1", "port": 9090, "subset": "subset1", "metricName": "conversion",
+				"metric": {"timestamp": 1, "numberOfElements": 100, "standardDeviation": 0.4, "average": 0.2}}]`))
+			default:
+				t.Logf("Unhandled Path: %v", r.URL.Path)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"message": "Url is incorrect"}`))
+			}
+		}
+	})
+	defer ts.Close()
+	restClient := client.NewRestClient(ts.URL, "Test-Token", "v1", false, "", nil)
+	values := make(map[string]string)
+	values["project"] = "project"
+	values["cluster"] = "cluster"
+	values["virtual_cluster"] = "virtualcluster"
+
+	metrics, err := restClient.GetExperimentMetrics("experiment 1&a#b", values)
+	assertError(t, err)
+	assertEqual(t, []models.ExperimentSubsetMetric{
+		{
+			Destination: "dest-1",
+			Port:        9090,
+			Subset:      "subset1",
+			MetricName:  "conversion",
+			Metric: models.ExperimentMetric{
+				Timestamp:         1,
+				NumberOfElements:  100,
+				StandardDeviation: 0.4,
+				Average:           0.2,
+			},
+		},
+	}, metrics)
+}
@@ -104,6 +104,11 @@ func (m *RestClientMock) SendExperimentMetric(experimentName string, metricName
 	return args.Error(0)
 }
 
+func (m *RestClientMock) GetExperimentMetrics(experimentName string, values map[string]string) ([]models.ExperimentSubsetMetric, error) {
+	args := m.Called(experimentName, values)
+	return args.Get(0).([]models.ExperimentSubsetMetric), args.Error(1)
+}
+
 func (m *RestClientMock) GetSubsetMap(values map[string]string) (*models.DestinationsSubsetsMap, error) {
 	args := m.Called(values)
 	return args.Get(0).(*models.DestinationsSubsetsMap), args.Error(1)
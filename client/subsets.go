@@ -0,0 +1,106 @@
+// Copyright © 2019 Developer developer@vamp.io
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/magneticio/vampkubistcli/models"
+)
+
+// SubsetResolution describes a destination and subset that a set of labels is mapped to
+type SubsetResolution struct {
+	Destination string                                `yaml:"destination" json:"destination"`
+	Subset      string                                `yaml:"subset" json:"subset"`
+	Labels      map[string]string                     `yaml:"labels" json:"labels"`
+	Ports       []models.DestinationPortSpecification `yaml:"ports" json:"ports"`
+}
+
+/*
+ParseSubsetMapKey converts a key of subset map to labels.
+Keys are comma separated label=value pairs,
+values without a label name are matched with the label names of the subset map by position.
+*/
+func ParseSubsetMapKey(key string, labelNames []string) map[string]string {
+	labels := make(map[string]string)
+	if key == "" {
+		return labels
+	}
+	for i, part := range strings.Split(key, ",") {
+		pair := strings.SplitN(part, "=", 2)
+		if len(pair) == 2 {
+			labels[strings.TrimSpace(pair[0])] = strings.TrimSpace(pair[1])
+		} else if i < len(labelNames) {
+			labels[labelNames[i]] = strings.TrimSpace(part)
+		}
+	}
+	return labels
+}
+
+// ListSubsets returns every destination and subset in the subset map sorted by destination and subset
+func ListSubsets(subsetMap *models.DestinationsSubsetsMap) []SubsetResolution {
+	return ResolveSubsets(subsetMap, nil)
+}
+
+/*
+ResolveSubsets returns destinations and subsets that the given labels are mapped to.
+A subset matches if all of its labels exist with the same value in the given labels,
+so pod labels can be passed directly.
+If labels is nil, every subset is returned.
+*/
+func ResolveSubsets(subsetMap *models.DestinationsSubsetsMap, labels map[string]string) []SubsetResolution {
+	res := []SubsetResolution{}
+	if subsetMap == nil {
+		return res
+	}
+	for key, labelsToPortMap := range subsetMap.DestinationsMap {
+		destination := labelsToPortMap.DestinationName
+		if destination == "" {
+			destination = key
+		}
+		for labelsKey, subsetToPorts := range labelsToPortMap.Map {
+			subsetLabels := ParseSubsetMapKey(labelsKey, subsetMap.Labels)
+			if labels != nil && !labelsMatch(subsetLabels, labels) {
+				continue
+			}
+			res = append(res, SubsetResolution{
+				Destination: destination,
+				Subset:      subsetToPorts.Subset,
+				Labels:      subsetLabels,
+				Ports:       subsetToPorts.Ports,
+			})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Destination != res[j].Destination {
+			return res[i].Destination < res[j].Destination
+		}
+		return res[i].Subset < res[j].Subset
+	})
+	return res
+}
+
+func labelsMatch(subsetLabels map[string]string, labels map[string]string) bool {
+	if len(subsetLabels) == 0 {
+		return false
+	}
+	for name, value := range subsetLabels {
+		if labels[name] != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,36 @@
+package client_test
+
+import (
+	"testing"
+
+	"github.com/magneticio/vampkubistcli/client"
+	"github.com/magneticio/vampkubistcli/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSubsetMapKey(t *testing.T) {
+	assert.Equal(t, map[string]string{"app": "shop", "version": "v2"}, client.ParseSubsetMapKey("app=shop,version=v2", nil))
+	assert.Equal(t, map[string]string{"app": "shop", "version": "v2"}, client.ParseSubsetMapKey("shop,v2", []string{"app", "version"}))
+	assert.Equal(t, map[string]string{}, client.ParseSubsetMapKey("", nil))
+}
+
+func TestResolveSubsets(t *testing.T) {
+	subsetMap := testSubsetMap()
+	subsetMap.DestinationsMap["dest-2"] = models.LabelsToPortMap{
+		DestinationName: "dest-2",
+		Map: map[string]models.SubsetToPorts{
+			"version=v2": models.SubsetToPorts{Subset: "subset1", Ports: []models.DestinationPortSpecification{{Port: 8080}}},
+		},
+	}
+
+	resolutions := client.ResolveSubsets(subsetMap, map[string]string{"version": "v2", "app": "shop"})
+	assert.Equal(t, 2, len(resolutions))
+	assert.Equal(t, "dest-1", resolutions[0].Destination)
+	assert.Equal(t, "subset2", resolutions[0].Subset)
+	assert.Equal(t, "dest-2", resolutions[1].Destination)
+	assert.Equal(t, "subset1", resolutions[1].Subset)
+	assert.Equal(t, 8080, resolutions[1].Ports[0].Port)
+
+	assert.Equal(t, 0, len(client.ResolveSubsets(subsetMap, map[string]string{"app": "shop"})))
+	assert.Equal(t, 4, len(client.ListSubsets(subsetMap)))
+}
@@ -0,0 +1,260 @@
+// Copyright © 2019 Developer developer@vamp.io
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/magneticio/vampkubistcli/models"
+)
+
+// TotalWeight is the value that weights of a route should sum up to
+const TotalWeight int64 = 100
+
+/*
+Weights in a route are referred by subset name.
+If the same subset name is used for multiple destinations,
+destination/subset format can be used to select one of them.
+*/
+func splitWeightKey(key string) (string, string) {
+	if i := strings.Index(key, "/"); i >= 0 {
+		return key[:i], key[i+1:]
+	}
+	return "", key
+}
+
+func findWeightIndex(route *models.Route, key string) (int, error) {
+	destination, subset := splitWeightKey(key)
+	found := -1
+	for i, weight := range route.Weights {
+		if weight.Version != subset {
+			continue
+		}
+		if destination != "" && weight.Destination != destination {
+			continue
+		}
+		if found >= 0 {
+			return -1, fmt.Errorf("Subset %v is ambiguous, use destination/subset format", key)
+		}
+		found = i
+	}
+	return found, nil
+}
+
+func getRoute(vampService *models.VampService, routeIndex int) (*models.Route, error) {
+	if routeIndex < 0 || routeIndex >= len(vampService.Routes) {
+		return nil, fmt.Errorf("Route %v does not exist, vamp service has %v routes", routeIndex, len(vampService.Routes))
+	}
+	return &vampService.Routes[routeIndex], nil
+}
+
+/*
+newWeight creates a weight for a subset which is not in the route yet.
+Destination and port are looked up from subset map
+so it is only possible when the subset is unique.
+*/
+func newWeight(key string, subsetMap *models.DestinationsSubsetsMap) (*models.Weight, error) {
+	destination, subset := splitWeightKey(key)
+	var candidates []models.Weight
+	if subsetMap != nil {
+		for _, labelsToPortMap := range subsetMap.DestinationsMap {
+			if destination != "" && labelsToPortMap.DestinationName != destination {
+				continue
+			}
+			for _, subsetToPorts := range labelsToPortMap.Map {
+				if subsetToPorts.Subset != subset {
+					continue
+				}
+				for _, port := range subsetToPorts.Ports {
+					candidates = append(candidates, models.Weight{
+						Destination: labelsToPortMap.DestinationName,
+						Port:        int64(port.Port),
+						Version:     subset,
+					})
+				}
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("Subset %v does not exist", key)
+	}
+	if len(candidates) > 1 {
+		return nil, fmt.Errorf("Subset %v is ambiguous, it is available on multiple destinations or ports", key)
+	}
+	return &candidates[0], nil
+}
+
+// SetRouteWeights sets weights of the given subsets in a route and leaves the others untouched
+func SetRouteWeights(vampService *models.VampService, routeIndex int, weights map[string]int64, subsetMap *models.DestinationsSubsetsMap) error {
+	route, routeError := getRoute(vampService, routeIndex)
+	if routeError != nil {
+		return routeError
+	}
+	// keys are sorted so that new weights are always appended in the same order
+	keys := make([]string, 0, len(weights))
+	for key := range weights {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if weights[key] < 0 || weights[key] > TotalWeight {
+			return fmt.Errorf("Weight of %v should be between 0 and %v", key, TotalWeight)
+		}
+		index, findError := findWeightIndex(route, key)
+		if findError != nil {
+			return findError
+		}
+		if index < 0 {
+			weight, newWeightError := newWeight(key, subsetMap)
+			if newWeightError != nil {
+				return newWeightError
+			}
+			route.Weights = append(route.Weights, *weight)
+			index = len(route.Weights) - 1
+		}
+		route.Weights[index].Weight = weights[key]
+	}
+	return ValidateRouteWeights(route, subsetMap)
+}
+
+/*
+ShiftRouteWeight moves the given amount of traffic to the target subset.
+Traffic is taken from the other subsets starting with the one that has the highest weight.
+*/
+func ShiftRouteWeight(vampService *models.VampService, routeIndex int, target string, by int64, subsetMap *models.DestinationsSubsetsMap) error {
+	route, routeError := getRoute(vampService, routeIndex)
+	if routeError != nil {
+		return routeError
+	}
+	if by <= 0 || by > TotalWeight {
+		return fmt.Errorf("Shift amount should be between 1 and %v", TotalWeight)
+	}
+	targetIndex, findError := findWeightIndex(route, target)
+	if findError != nil {
+		return findError
+	}
+	if targetIndex < 0 {
+		weight, newWeightError := newWeight(target, subsetMap)
+		if newWeightError != nil {
+			return newWeightError
+		}
+		route.Weights = append(route.Weights, *weight)
+		targetIndex = len(route.Weights) - 1
+	}
+	others := make([]int, 0, len(route.Weights))
+	available := int64(0)
+	for i := range route.Weights {
+		if i != targetIndex {
+			others = append(others, i)
+			available += route.Weights[i].Weight
+		}
+	}
+	if available < by {
+		return fmt.Errorf("Can not shift %v, only %v is available on other subsets", by, available)
+	}
+	sort.SliceStable(others, func(i, j int) bool {
+		return route.Weights[others[i]].Weight > route.Weights[others[j]].Weight
+	})
+	remaining := by
+	for _, i := range others {
+		if remaining == 0 {
+			break
+		}
+		taken := route.Weights[i].Weight
+		if taken > remaining {
+			taken = remaining
+		}
+		route.Weights[i].Weight -= taken
+		remaining -= taken
+	}
+	route.Weights[targetIndex].Weight += by
+	return ValidateRouteWeights(route, subsetMap)
+}
+
+/*
+RouteAllTrafficTo sets weight of the target subset to 100 and others to 0
+in every route that contains the target.
+It returns the number of updated routes.
+*/
+func RouteAllTrafficTo(vampService *models.VampService, target string, subsetMap *models.DestinationsSubsetsMap) (int, error) {
+	updated := 0
+	for i := range vampService.Routes {
+		route := &vampService.Routes[i]
+		index, findError := findWeightIndex(route, target)
+		if findError != nil {
+			return updated, findError
+		}
+		if index < 0 {
+			continue
+		}
+		for j := range route.Weights {
+			route.Weights[j].Weight = 0
+		}
+		route.Weights[index].Weight = TotalWeight
+		if validationError := ValidateRouteWeights(route, subsetMap); validationError != nil {
+			return updated, validationError
+		}
+		updated++
+	}
+	if updated == 0 {
+		return 0, fmt.Errorf("Subset %v is not used in any route", target)
+	}
+	return updated, nil
+}
+
+/*
+ValidateRouteWeights checks that weights of a route sum up to 100
+and every destination, port and subset exists in the subset map.
+Existence check is skipped if subset map is nil.
+*/
+func ValidateRouteWeights(route *models.Route, subsetMap *models.DestinationsSubsetsMap) error {
+	sum := int64(0)
+	for _, weight := range route.Weights {
+		sum += weight.Weight
+	}
+	if sum != TotalWeight {
+		return fmt.Errorf("Weights should sum up to %v but sum is %v", TotalWeight, sum)
+	}
+	if subsetMap == nil {
+		return nil
+	}
+	for _, weight := range route.Weights {
+		if !subsetExists(subsetMap, weight.Destination, weight.Port, weight.Version) {
+			return fmt.Errorf("Destination %v with port %v and subset %v does not exist", weight.Destination, weight.Port, weight.Version)
+		}
+	}
+	return nil
+}
+
+func subsetExists(subsetMap *models.DestinationsSubsetsMap, destination string, port int64, subset string) bool {
+	for _, labelsToPortMap := range subsetMap.DestinationsMap {
+		if labelsToPortMap.DestinationName != destination {
+			continue
+		}
+		for _, subsetToPorts := range labelsToPortMap.Map {
+			if subsetToPorts.Subset != subset {
+				continue
+			}
+			for _, p := range subsetToPorts.Ports {
+				if int64(p.Port) == port {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package client_test
+
+import (
+	"testing"
+
+	"github.com/magneticio/vampkubistcli/client"
+	"github.com/magneticio/vampkubistcli/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func testSubsetMap() *models.DestinationsSubsetsMap {
+	return &models.DestinationsSubsetsMap{
+		DestinationsMap: map[string]models.LabelsToPortMap{
+			"dest-1": models.LabelsToPortMap{
+				DestinationName: "dest-1",
+				Map: map[string]models.SubsetToPorts{
+					"version=v1": models.SubsetToPorts{Subset: "subset1", Ports: []models.DestinationPortSpecification{{Port: 9090}}},
+					"version=v2": models.SubsetToPorts{Subset: "subset2", Ports: []models.DestinationPortSpecification{{Port: 9090}}},
+					"version=v3": models.SubsetToPorts{Subset: "subset3", Ports: []models.DestinationPortSpecification{{Port: 9090}}},
+				},
+			},
+		},
+		Labels: []string{"version"},
+	}
+}
+
+func testVampService() *models.VampService {
+	return &models.VampService{
+		Routes: []models.Route{
+			{
+				Protocol: "http",
+				Weights: []models.Weight{
+					{Destination: "dest-1", Port: 9090, Version: "subset1", Weight: 50},
+					{Destination: "dest-1", Port: 9090, Version: "subset2", Weight: 50},
+				},
+			},
+		},
+	}
+}
+
+func TestSetRouteWeights(t *testing.T) {
+	vampService := testVampService()
+	err := client.SetRouteWeights(vampService, 0, map[string]int64{"subset1": 80, "dest-1/subset2": 20}, testSubsetMap())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(80), vampService.Routes[0].Weights[0].Weight)
+	assert.Equal(t, int64(20), vampService.Routes[0].Weights[1].Weight)
+}
+
+func TestSetRouteWeightsNewSubset(t *testing.T) {
+	vampService := testVampService()
+	err := client.SetRouteWeights(vampService, 0, map[string]int64{"subset2": 30, "subset3": 20}, testSubsetMap())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(vampService.Routes[0].Weights))
+	assert.Equal(t, models.Weight{Destination: "dest-1", Port: 9090, Version: "subset3", Weight: 20}, vampService.Routes[0].Weights[2])
+}
+
+func TestSetRouteWeightsInvalid(t *testing.T) {
+	err := client.SetRouteWeights(testVampService(), 0, map[string]int64{"subset1": 80}, testSubsetMap())
+	assert.EqualError(t, err, "Weights should sum up to 100 but sum is 130")
+
+	err = client.SetRouteWeights(testVampService(), 0, map[string]int64{"subset4": 0}, testSubsetMap())
+	assert.EqualError(t, err, "Subset subset4 does not exist")
+
+	err = client.SetRouteWeights(testVampService(), 1, map[string]int64{"subset1": 100}, testSubsetMap())
+	assert.Error(t, err)
+}
+
+func TestShiftRouteWeight(t *testing.T) {
+	vampService := testVampService()
+	vampService.Routes[0].Weights[0].Weight = 90
+	vampService.Routes[0].Weights[1].Weight = 10
+	err := client.ShiftRouteWeight(vampService, 0, "subset2", 10, testSubsetMap())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(80), vampService.Routes[0].Weights[0].Weight)
+	assert.Equal(t, int64(20), vampService.Routes[0].Weights[1].Weight)
+
+	err = client.ShiftRouteWeight(vampService, 0, "subset2", 90, testSubsetMap())
+	assert.EqualError(t, err, "Can not shift 90, only 80 is available on other subsets")
+}
+
+func TestValidateRouteWeightsUnknownPort(t *testing.T) {
+	vampService := testVampService()
+	vampService.Routes[0].Weights[1].Port = 8080
+	err := client.ValidateRouteWeights(&vampService.Routes[0], testSubsetMap())
+	assert.EqualError(t, err, "Destination dest-1 with port 8080 and subset subset2 does not exist")
+}
+
+func TestRouteAllTrafficTo(t *testing.T) {
+	vampService := testVampService()
+	updated, err := client.RouteAllTrafficTo(vampService, "subset2", testSubsetMap())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, updated)
+	assert.Equal(t, int64(0), vampService.Routes[0].Weights[0].Weight)
+	assert.Equal(t, int64(100), vampService.Routes[0].Weights[1].Weight)
+
+	_, err = client.RouteAllTrafficTo(vampService, "subset3", testSubsetMap())
+	assert.EqualError(t, err, "Subset subset3 is not used in any route")
+}
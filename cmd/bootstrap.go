@@ -28,6 +28,10 @@ import (
 	"github.com/spf13/viper"
 )
 
+// rbacMode selects the permissions of the service account of vamp
+var rbacMode string
+var bootstrapOptions kubeclient.BootstrapOptions
+
 // bootstrapCmd represents the bootstrap command
 var bootstrapCmd = &cobra.Command{
 	Use:   "bootstrap",
@@ -39,6 +43,17 @@ var bootstrapCmd = &cobra.Command{
     $AppName bootstrap cluster mycluster
     This will automacially read configuration and create vamp user in your cluster and
     make required set up in vamp. You can access the cluster with name mycluster.
+    The default service account of the namespace is bound to cluster-admin, with
+    $AppName bootstrap cluster mycluster --rbac=minimal
+    a dedicated service account is bound to a cluster role with only the permissions vamp needs.
+    Permissions of the service account are compared with the minimal role by
+    $AppName bootstrap audit --rbac=minimal
+    The token of the service account is read from a token secret that is created if the cluster
+    doesn't create them, or it is requested with an expiry with
+    $AppName bootstrap cluster mycluster --token-duration 720h
+    Token secrets of the service account are deleted so their tokens are revoked,
+    a new token is created and the cluster in vamp is updated with the RBAC mode of the previous bootstrap by
+    $AppName bootstrap cluster mycluster --refresh-credentials
   `),
 	SilenceUsage:  true,
 	SilenceErrors: true,
@@ -54,7 +69,12 @@ var bootstrapCmd = &cobra.Command{
 		}
 
 		if Type == "cluster" {
-			url, crt, token, err := kubeclient.BootstrapVampService(kubeConfigPath)
+			bootstrapOptions.RBAC = rbacMode
+			if bootstrapOptions.RefreshCredentials && !cmd.Flags().Changed("rbac") {
+				// the RBAC mode of the previous bootstrap is used
+				bootstrapOptions.RBAC = ""
+			}
+			url, crt, token, err := kubeclient.BootstrapVampServiceWithOptions(kubeConfigPath, namespace, bootstrapOptions)
 			if err != nil {
 				// fmt.Printf("Error: %v\n", err)
 				return err
@@ -76,6 +96,13 @@ var bootstrapCmd = &cobra.Command{
 			values["cluster"] = Config.Cluster
 			values["virtual_cluster"] = Config.VirtualCluster
 			values["application"] = Application
+			if bootstrapOptions.RefreshCredentials {
+				isUpdated, err_update := restClient.Update(Type, Name, Source, SourceFileType, values)
+				if !isUpdated {
+					return err_update
+				}
+				return nil
+			}
 			isCreated, err_create := restClient.Create(Type, Name, Source, SourceFileType, values)
 			if !isCreated {
 				return err_create
@@ -90,5 +117,9 @@ func init() {
 	rootCmd.AddCommand(bootstrapCmd)
 
 	bootstrapCmd.Flags().StringVarP(&kubeConfigPath, "kubeconfig", "", "", "Kube Config path")
+	bootstrapCmd.Flags().StringVarP(&namespace, "namespace", "", kubeclient.InstallationNamespace, "Namespace of the service account of vamp")
+	bootstrapCmd.Flags().StringVarP(&rbacMode, "rbac", "", kubeclient.RBACClusterAdmin, "Permissions of the service account of vamp, cluster-admin or minimal")
+	bootstrapCmd.Flags().DurationVarP(&bootstrapOptions.TokenDuration, "token-duration", "", 0, "Request a token that expires after the duration instead of using a token secret")
+	bootstrapCmd.Flags().BoolVarP(&bootstrapOptions.RefreshCredentials, "refresh-credentials", "", false, "Replace the token of the service account and update the cluster")
 	viper.BindEnv("kubeconfig", "KUBECONFIG")
 }
@@ -0,0 +1,96 @@
+// Copyright © 2019 Developer developer@vamp.io
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/magneticio/vampkubistcli/kubernetes"
+	"github.com/magneticio/vampkubistcli/util"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var auditOutputType string
+
+var bootstrapAuditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Compare the permissions of the vamp service account with the minimal role",
+	Long: AddAppName(`Compare the permissions of the vamp service account with the minimal role
+The cluster role bindings and role bindings of the service account of the RBAC mode are resolved
+into its effective permissions. Permissions that vamp needs but aren't granted cluster wide are missing,
+permissions that the minimal role doesn't have are excess.
+
+Example:
+    $AppName bootstrap audit
+    $AppName bootstrap audit --rbac=minimal --namespace vamp-system -o yaml`),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if kubeConfigPath == "" {
+			kubeConfigPath = viper.GetString("kubeconfig")
+		}
+		audit, err := kubeclient.AuditVampServiceAccount(kubeConfigPath, namespace, rbacMode)
+		if err != nil {
+			return err
+		}
+		if auditOutputType == "text" {
+			printAudit(audit)
+			return nil
+		}
+		SourceRaw, marshalError := json.Marshal(audit)
+		if marshalError != nil {
+			return marshalError
+		}
+		output, convertError := util.Convert("json", auditOutputType, string(SourceRaw))
+		if convertError != nil {
+			return convertError
+		}
+		fmt.Printf("%v", output)
+		return nil
+	},
+}
+
+func printAudit(audit *kubeclient.RBACAudit) {
+	fmt.Printf("Service account %v in namespace %v\n", audit.ServiceAccount, audit.Namespace)
+	fmt.Printf("Bindings:\n")
+	for _, binding := range audit.Bindings {
+		fmt.Printf("  %v\n", binding)
+	}
+	fmt.Printf("Effective permissions:\n")
+	for _, permission := range audit.Effective {
+		fmt.Printf("  %v\n", permission)
+	}
+	fmt.Printf("Diff with the minimal role:\n")
+	for _, permission := range audit.Missing {
+		fmt.Printf("- %v\n", permission)
+	}
+	for _, permission := range audit.Excess {
+		fmt.Printf("+ %v\n", permission)
+	}
+	if len(audit.Missing) == 0 && len(audit.Excess) == 0 {
+		fmt.Printf("  permissions are the same as the minimal role\n")
+	}
+}
+
+func init() {
+	bootstrapCmd.AddCommand(bootstrapAuditCmd)
+
+	bootstrapAuditCmd.Flags().StringVarP(&kubeConfigPath, "kubeconfig", "", "", "Kube Config path")
+	bootstrapAuditCmd.Flags().StringVarP(&namespace, "namespace", "", kubeclient.InstallationNamespace, "Namespace of the service account of vamp")
+	bootstrapAuditCmd.Flags().StringVarP(&rbacMode, "rbac", "", kubeclient.RBACClusterAdmin, "RBAC mode of the service account to audit, cluster-admin or minimal")
+	bootstrapAuditCmd.Flags().StringVarP(&auditOutputType, "output", "o", "text", "Output format text, yaml or json")
+}
@@ -0,0 +1,40 @@
+// Copyright © 2019 Developer developer@vamp.io
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// databaseArchiveFile is the gzipped archive that the database is backed up to and restored from
+var databaseArchiveFile string
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Back up and restore the database of the Vamp installation",
+	Long: AddAppName(`Back up and restore the database of the Vamp installation
+Backup and restore run mongodump and mongorestore as a job in the namespace of the installation
+with the database of the vamp deployment, the archive is streamed between the job and a local file.
+
+Example:
+    $AppName db backup --file vamp.archive.gz
+    $AppName db restore --file vamp.archive.gz`),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+func init() {
+	rootCmd.AddCommand(dbCmd)
+}
@@ -0,0 +1,70 @@
+// Copyright © 2019 Developer developer@vamp.io
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/magneticio/vampkubistcli/kubernetes"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var dbBackupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Back up the database of the Vamp installation to a file",
+	Long: AddAppName(`Back up the database of the Vamp installation to a file
+The database is dumped as a gzipped archive by a job that runs mongodump, the archive can be restored with db restore.
+
+Example:
+    $AppName db backup --file vamp.archive.gz
+    $AppName db backup --file staging.archive.gz --namespace vamp-staging --release-name staging`),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if databaseArchiveFile == "" {
+			return errors.New("Archive file is required")
+		}
+		if kubeConfigPath == "" {
+			kubeConfigPath = viper.GetString("kubeconfig")
+		}
+		file, err := os.OpenFile(databaseArchiveFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return err
+		}
+		backupErr := kubeclient.BackupDatabase(kubeConfigPath, namespace, releaseName, file)
+		if closeErr := file.Close(); backupErr == nil {
+			backupErr = closeErr
+		}
+		if backupErr != nil {
+			// an incomplete archive can't be restored
+			os.Remove(databaseArchiveFile)
+			return backupErr
+		}
+		fmt.Printf("Database is backed up to %v.\n", databaseArchiveFile)
+		return nil
+	},
+}
+
+func init() {
+	dbCmd.AddCommand(dbBackupCmd)
+
+	dbBackupCmd.Flags().StringVarP(&databaseArchiveFile, "file", "f", "", "Archive file to write the backup to")
+	dbBackupCmd.Flags().StringVarP(&kubeConfigPath, "kubeconfig", "", "", "Kube Config path")
+	dbBackupCmd.Flags().StringVarP(&namespace, "namespace", "", kubeclient.InstallationNamespace, "Namespace of the installation")
+	dbBackupCmd.Flags().StringVarP(&releaseName, "release-name", "", "", "Release name of the installation, the default release is used if it is empty")
+}
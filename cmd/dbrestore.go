@@ -0,0 +1,66 @@
+// Copyright © 2019 Developer developer@vamp.io
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/magneticio/vampkubistcli/kubernetes"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var dbRestoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "Restore the database of the Vamp installation from a file",
+	Long: AddAppName(`Restore the database of the Vamp installation from a file
+A gzipped archive of db backup is restored by a job that runs mongorestore,
+collections of the archive that exist in the database are dropped before they are restored.
+
+Example:
+    $AppName db restore --file vamp.archive.gz
+    $AppName db restore --file staging.archive.gz --namespace vamp-staging --release-name staging`),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if databaseArchiveFile == "" {
+			return errors.New("Archive file is required")
+		}
+		if kubeConfigPath == "" {
+			kubeConfigPath = viper.GetString("kubeconfig")
+		}
+		file, err := os.Open(databaseArchiveFile)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		if err := kubeclient.RestoreDatabase(kubeConfigPath, namespace, releaseName, file); err != nil {
+			return err
+		}
+		fmt.Printf("Database is restored from %v.\n", databaseArchiveFile)
+		return nil
+	},
+}
+
+func init() {
+	dbCmd.AddCommand(dbRestoreCmd)
+
+	dbRestoreCmd.Flags().StringVarP(&databaseArchiveFile, "file", "f", "", "Archive file to restore")
+	dbRestoreCmd.Flags().StringVarP(&kubeConfigPath, "kubeconfig", "", "", "Kube Config path")
+	dbRestoreCmd.Flags().StringVarP(&namespace, "namespace", "", kubeclient.InstallationNamespace, "Namespace of the installation")
+	dbRestoreCmd.Flags().StringVarP(&releaseName, "release-name", "", "", "Release name of the installation, the default release is used if it is empty")
+}
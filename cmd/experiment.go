@@ -0,0 +1,372 @@
+// Copyright © 2019 Developer developer@vamp.io
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/magneticio/vampkubistcli/client"
+	"github.com/magneticio/vampkubistcli/logging"
+	"github.com/magneticio/vampkubistcli/models"
+	"github.com/magneticio/vampkubistcli/stats"
+	"github.com/magneticio/vampkubistcli/util"
+	"github.com/spf13/cobra"
+)
+
+var winner string
+var confidenceLevel float64
+var experimentMetricName string
+var controlVariant string
+var statisticalPower float64
+var minimumDetectableEffect float64
+var lowerIsBetter bool
+var compareOutputType string
+
+// experimentCmd represents the experiment command
+var experimentCmd = &cobra.Command{
+	Use:   "experiment",
+	Short: "Manage the lifecycle of an experiment",
+	Long: AddAppName(`Manage the lifecycle of an experiment
+    Example:
+    $AppName experiment start myexperiment -f experiment.yaml
+    $AppName experiment status myexperiment
+    $AppName experiment results myexperiment
+    $AppName experiment compare myexperiment --metric conversion
+    $AppName experiment stop myexperiment --winner subset2`),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return errors.New("a sub command expected, use start, stop, status, results or compare")
+	},
+}
+
+var experimentStartCmd = &cobra.Command{
+	Use:   "start",
+	Short: "Start an experiment",
+	Long: AddAppName(`Start an experiment with the given specification
+
+Example:
+    $AppName experiment start myexperiment -f experiment.yaml`),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return errors.New("Not Enough Arguments")
+		}
+		Name = args[0]
+		Source := SourceString
+		if Source == "" {
+			b, err := util.UseSourceUrl(SourceFile) // just pass the file name
+			if err != nil {
+				return err
+			}
+			Source = string(b)
+		}
+		restClient := client.NewRestClient(Config.Url, Config.Token, Config.APIVersion, logging.Verbose, Config.Cert, &TokenStore)
+		isCreated, createError := restClient.Create("experiment", Name, Source, SourceFileType, experimentValues())
+		if !isCreated {
+			return createError
+		}
+		fmt.Println("experiment " + Name + " is started")
+		return nil
+	},
+}
+
+var experimentStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop an experiment",
+	Long: AddAppName(`Stop an experiment
+If a winner is given, all traffic of the vamp service is routed to the winning subset.
+If a subset is used in multiple destinations, destination/subset can be used.
+
+Example:
+    $AppName experiment stop myexperiment
+    $AppName experiment stop myexperiment --winner subset2`),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return errors.New("Not Enough Arguments")
+		}
+		Name = args[0]
+		restClient := client.NewRestClient(Config.Url, Config.Token, Config.APIVersion, logging.Verbose, Config.Cert, &TokenStore)
+		values := experimentValues()
+		experiment, getExperimentError := getExperiment(restClient, Name, values)
+		if getExperimentError != nil {
+			return getExperimentError
+		}
+		// traffic is routed first so the experiment keeps running if the winner can not be routed to
+		if winner != "" {
+			routeError := updateTraffic(experiment.VampServiceName, func(vampService *models.VampService, subsetMap *models.DestinationsSubsetsMap) error {
+				_, routeError := client.RouteAllTrafficTo(vampService, winner, subsetMap)
+				return routeError
+			})
+			if routeError != nil {
+				return routeError
+			}
+		}
+		isDeleted, deleteError := restClient.Delete("experiment", Name, values)
+		if !isDeleted {
+			return deleteError
+		}
+		fmt.Println("experiment " + Name + " is stopped")
+		return nil
+	},
+}
+
+var experimentStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show status of an experiment",
+	Long: AddAppName(`Show tags and collected metrics of each destination and subset in an experiment
+
+Example:
+    $AppName experiment status myexperiment`),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return errors.New("Not Enough Arguments")
+		}
+		Name = args[0]
+		restClient := client.NewRestClient(Config.Url, Config.Token, Config.APIVersion, logging.Verbose, Config.Cert, &TokenStore)
+		values := experimentValues()
+		experiment, getExperimentError := getExperiment(restClient, Name, values)
+		if getExperimentError != nil {
+			return getExperimentError
+		}
+		metrics, getMetricsError := restClient.GetExperimentMetrics(Name, values)
+		if getMetricsError != nil {
+			return getMetricsError
+		}
+		fmt.Printf("Vamp Service: %v\n", experiment.VampServiceName)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "DESTINATION\tPORT\tSUBSET\tTAGS\tMETRIC\tCOUNT\tAVERAGE\tSTDDEV")
+		for _, destination := range experiment.Destinations {
+			tags := strings.Join(destination.Tags, ",")
+			found := false
+			for _, metric := range metrics {
+				if metric.Destination != destination.Destination || metric.Subset != destination.Subset {
+					continue
+				}
+				found = true
+				fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%.4f\t%.4f\n",
+					destination.Destination, destination.Port, destination.Subset, tags,
+					metric.MetricName, metric.Metric.NumberOfElements, metric.Metric.Average, metric.Metric.StandardDeviation)
+			}
+			if !found {
+				fmt.Fprintf(w, "%v\t%v\t%v\t%v\t-\t0\t-\t-\n", destination.Destination, destination.Port, destination.Subset, tags)
+			}
+		}
+		return w.Flush()
+	},
+}
+
+var experimentResultsCmd = &cobra.Command{
+	Use:   "results",
+	Short: "Show results of an experiment",
+	Long: AddAppName(`Show per variant statistics with confidence intervals of an experiment
+
+Example:
+    $AppName experiment results myexperiment
+    $AppName experiment results myexperiment --metric conversion --confidence 0.99`),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return errors.New("Not Enough Arguments")
+		}
+		Name = args[0]
+		if confidenceLevel <= 0 || confidenceLevel >= 1 {
+			return errors.New("Confidence should be between 0 and 1")
+		}
+		restClient := client.NewRestClient(Config.Url, Config.Token, Config.APIVersion, logging.Verbose, Config.Cert, &TokenStore)
+		metrics, getMetricsError := restClient.GetExperimentMetrics(Name, experimentValues())
+		if getMetricsError != nil {
+			return getMetricsError
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "METRIC\tVARIANT\tCOUNT\tAVERAGE\tSTDDEV\tCI %v%%\n", confidenceLevel*100)
+		for _, metric := range metrics {
+			if experimentMetricName != "" && metric.MetricName != experimentMetricName {
+				continue
+			}
+			low, high, intervalError := stats.ConfidenceInterval(stats.FromExperimentMetric(metric.Metric), confidenceLevel)
+			if intervalError != nil {
+				low, high = math.NaN(), math.NaN()
+			}
+			fmt.Fprintf(w, "%v\t%v/%v\t%v\t%.4f\t%.4f\t[%.4f, %.4f]\n",
+				metric.MetricName, metric.Destination, metric.Subset,
+				metric.Metric.NumberOfElements, metric.Metric.Average, metric.Metric.StandardDeviation, low, high)
+		}
+		return w.Flush()
+	},
+}
+
+var experimentCompareCmd = &cobra.Command{
+	Use:   "compare",
+	Short: "Compare variants of an experiment",
+	Long: AddAppName(`Compare variants of an experiment with Welch's t-test
+Every variant is compared with the control, by default the first variant.
+It reports effect size, p-value, confidence intervals and the required sample size
+and recommends to keep running, to pick a winner or that there is no difference.
+Metrics can be read from a file instead of the experiment,
+file should contain a list of metrics in the format returned by the api.
+
+Example:
+    $AppName experiment compare myexperiment --metric conversion
+    $AppName experiment compare myexperiment --metric latency --lower-is-better --control dest-1/subset1
+    $AppName experiment compare myexperiment --metric conversion --mde 0.05 --power 0.9 -f metrics.yaml`),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return errors.New("Not Enough Arguments")
+		}
+		Name = args[0]
+		if experimentMetricName == "" {
+			return errors.New("Metric should be provided with metric flag")
+		}
+		var metrics []models.ExperimentSubsetMetric
+		if SourceFile != "" {
+			source, readError := util.UseSourceUrl(SourceFile)
+			if readError != nil {
+				return readError
+			}
+			sourceJson, convertError := util.Convert(SourceFileType, "json", source)
+			if convertError != nil {
+				return convertError
+			}
+			if unmarshalError := json.Unmarshal([]byte(sourceJson), &metrics); unmarshalError != nil {
+				return unmarshalError
+			}
+		} else {
+			restClient := client.NewRestClient(Config.Url, Config.Token, Config.APIVersion, logging.Verbose, Config.Cert, &TokenStore)
+			var getMetricsError error
+			metrics, getMetricsError = restClient.GetExperimentMetrics(Name, experimentValues())
+			if getMetricsError != nil {
+				return getMetricsError
+			}
+		}
+		var control *stats.Variant
+		variants := []stats.Variant{}
+		for _, metric := range metrics {
+			if metric.MetricName != experimentMetricName {
+				continue
+			}
+			variant := stats.Variant{
+				Name:   metric.Destination + "/" + metric.Subset,
+				Sample: stats.FromExperimentMetric(metric.Metric),
+			}
+			if control == nil && (controlVariant == "" || controlVariant == variant.Name || controlVariant == metric.Subset) {
+				control = &variant
+				continue
+			}
+			variants = append(variants, variant)
+		}
+		if control == nil {
+			return fmt.Errorf("Control %v does not have metric %v", controlVariant, experimentMetricName)
+		}
+		recommendation, compareError := stats.Compare(*control, variants, stats.CompareOptions{
+			Confidence:              confidenceLevel,
+			Power:                   statisticalPower,
+			MinimumDetectableEffect: minimumDetectableEffect,
+			LowerIsBetter:           lowerIsBetter,
+		})
+		if compareError != nil {
+			return compareError
+		}
+		if compareOutputType == "yaml" || compareOutputType == "json" {
+			SourceRaw, marshalError := json.Marshal(recommendation)
+			if marshalError != nil {
+				return marshalError
+			}
+			result, convertError := util.Convert("json", compareOutputType, string(SourceRaw))
+			if convertError != nil {
+				return convertError
+			}
+			fmt.Printf("%v", result)
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "VARIANT\tCOUNT\tMEAN\tCI %v%%\tDIFFERENCE\tEFFECT SIZE\tP-VALUE\tREQUIRED SAMPLE SIZE\n", confidenceLevel*100)
+		c := recommendation.Control
+		fmt.Fprintf(w, "%v (control)\t%v\t%.4f\t[%.4f, %.4f]\t-\t-\t-\t-\n", c.Name, c.Count, c.Mean, c.ConfidenceInterval[0], c.ConfidenceInterval[1])
+		for _, v := range recommendation.Variants {
+			fmt.Fprintf(w, "%v\t%v\t%.4f\t[%.4f, %.4f]\t%+.4f (%+.2f%%)\t%.4f\t%.4f\t%v\n",
+				v.Name, v.Count, v.Mean, v.ConfidenceInterval[0], v.ConfidenceInterval[1],
+				v.Comparison.Difference, v.Comparison.RelativeDifference*100, v.Comparison.EffectSize, v.Comparison.PValue, v.RequiredSampleSize)
+		}
+		if flushError := w.Flush(); flushError != nil {
+			return flushError
+		}
+		if recommendation.Decision == stats.Winner {
+			fmt.Printf("Recommendation: %v %v\n", recommendation.Decision, recommendation.Winner)
+		} else {
+			fmt.Printf("Recommendation: %v\n", recommendation.Decision)
+		}
+		return nil
+	},
+}
+
+func experimentValues() map[string]string {
+	values := make(map[string]string)
+	values["project"] = Config.Project
+	values["cluster"] = Config.Cluster
+	values["virtual_cluster"] = Config.VirtualCluster
+	return values
+}
+
+func getExperiment(restClient *client.RestClient, name string, values map[string]string) (*models.Experiment, error) {
+	spec, getSpecError := restClient.GetSpec("experiment", name, "json", values)
+	if getSpecError != nil {
+		return nil, getSpecError
+	}
+	var experiment models.Experiment
+	if unmarshalError := json.Unmarshal([]byte(spec), &experiment); unmarshalError != nil {
+		return nil, unmarshalError
+	}
+	return &experiment, nil
+}
+
+func init() {
+	rootCmd.AddCommand(experimentCmd)
+	experimentCmd.AddCommand(experimentStartCmd)
+	experimentCmd.AddCommand(experimentStopCmd)
+	experimentCmd.AddCommand(experimentStatusCmd)
+	experimentCmd.AddCommand(experimentResultsCmd)
+	experimentCmd.AddCommand(experimentCompareCmd)
+
+	experimentStartCmd.Flags().StringVarP(&SourceString, "string", "s", "", "Source from string")
+	experimentStartCmd.Flags().StringVarP(&SourceFile, "file", "f", "", "Source from file")
+	experimentStartCmd.Flags().StringVarP(&SourceFileType, "input", "i", "yaml", "Source file type yaml or json")
+	experimentStopCmd.Flags().StringVarP(&winner, "winner", "", "", "Subset to route all traffic to, destination/subset is also accepted")
+	experimentResultsCmd.Flags().Float64VarP(&confidenceLevel, "confidence", "", 0.95, "Confidence level of the intervals")
+	experimentResultsCmd.Flags().StringVarP(&experimentMetricName, "metric", "", "", "Only show results of this metric")
+	experimentCompareCmd.Flags().StringVarP(&experimentMetricName, "metric", "", "", "Metric to compare")
+	experimentCompareCmd.Flags().StringVarP(&controlVariant, "control", "", "", "Control variant as subset or destination/subset, default is the first variant")
+	experimentCompareCmd.Flags().Float64VarP(&confidenceLevel, "confidence", "", 0.95, "Confidence level of the test")
+	experimentCompareCmd.Flags().Float64VarP(&statisticalPower, "power", "", 0.8, "Statistical power used for the required sample size")
+	experimentCompareCmd.Flags().Float64VarP(&minimumDetectableEffect, "mde", "", 0, "Minimum detectable effect relative to the control mean, observed difference is used if it or the control mean is 0")
+	experimentCompareCmd.Flags().BoolVarP(&lowerIsBetter, "lower-is-better", "", false, "Lower metric values are better eg.: latency")
+	experimentCompareCmd.Flags().StringVarP(&SourceFile, "file", "f", "", "Read metrics from file instead of the experiment")
+	experimentCompareCmd.Flags().StringVarP(&SourceFileType, "input", "i", "yaml", "Metrics file type yaml or json")
+	experimentCompareCmd.Flags().StringVarP(&compareOutputType, "output", "o", "table", "Output format table, yaml or json")
+}
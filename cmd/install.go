@@ -16,8 +16,11 @@ package cmd
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 
 	"github.com/magneticio/vampkubistcli/kubernetes"
 	"github.com/magneticio/vampkubistcli/models"
@@ -29,6 +32,11 @@ import (
 var installConfigPath string
 var configFileType string
 var certFileName string
+var configPatches []string
+var renderManifests bool
+var renderOutputDir string
+var renderHost string
+var renderKustomize bool
 
 // installCmd represents the install command
 var installCmd = &cobra.Command{
@@ -47,11 +55,60 @@ repoUsername: dockerHubUsername
 repoPassword: dockerHubPassword
 imageTag: 0.7.7
 mode: IN_CLUSTER
+namespace: vamp-system
+releaseName: vamp
+labels:
+  team: platform
+annotations:
+  owner: platform@example.com
 
-Leave databaseUrl empty to deploy an internal one.
+Leave databaseUrl empty to deploy an internal one, it is configured with mongo:
+mongo:
+  replicas: 3
+  image: mongo:4.0
+  storageClass: standard
+  storageSize: 10Gi
+  limitCPU: "1"
+  limitMemory: 2Gi
+  username: vamp
+  password:
+A username enables authentication, the credentials are kept in a secret and the password is generated if it is empty.
+The storage of existing replicas can't be changed by reinstalling. The database is backed up with $AppName db backup.
+Installations with different namespaces and release names can run side by side in one cluster,
+labels and annotations are added to every installed object.
+
+Patches are merged over the configuration in order, e.g. resources recommended by
+$AppName install recommend > resources.yml
+$AppName install --configuration installconfig.yml --patch resources.yml
+
+Vamp is exposed with a LoadBalancer service by default, serviceType can also be NodePort or ClusterIP.
+An ingress routes its host to vamp, TLS is passed through to vamp unless the ingress has a secretName:
+serviceType: ClusterIP
+ingress:
+  host: vamp.example.com
+  className: nginx
+Vamp with a ClusterIP service and no ingress is reached with $AppName login --port-forward.
 
 Install will generate certificates for the cluster which will be written to the certificate output path.
+Certificates can also be brought along, either as files, as an existing kubernetes.io/tls secret in the namespace
+or issued by cert-manager. Only one of them can be configured, dnsNames are added to generated and issued certificates
+and the first one is used as the external host:
+tls:
+  dnsNames:
+  - vamp.example.com
+  secretName: vamp-tls-secret
+  # or
+  certificateFile: ./tls.crt
+  keyFile: ./tls.key
+  # or
+  issuer:
+    name: letsencrypt
+    kind: ClusterIssuer
 Install command is reentrant, it is possible to update the cluster with re-running the command.
+
+Render writes the manifests of the installation into a directory instead of applying them to the cluster,
+certificates are generated for the external host or they are placeholders if no host is given:
+$AppName install --configuration installconfig.yml --render -o ./vamp --host vamp.example.com --kustomize
   `),
 	SilenceUsage:  true,
 	SilenceErrors: true,
@@ -68,6 +125,22 @@ Install command is reentrant, it is possible to update the cluster with re-runni
 		if convertErr != nil {
 			return convertErr
 		}
+		for _, patchPath := range configPatches {
+			patch, patchReadErr := util.UseSourceUrl(patchPath)
+			if patchReadErr != nil {
+				return patchReadErr
+			}
+			// json is valid yaml so patches can be in either format
+			patchJson, patchConvertErr := util.Convert("yaml", "json", patch)
+			if patchConvertErr != nil {
+				return patchConvertErr
+			}
+			merged, mergeErr := util.Merge(configJson, patchJson, "json")
+			if mergeErr != nil {
+				return mergeErr
+			}
+			configJson = merged
+		}
 		var config models.VampConfig
 		unmarshallError := json.Unmarshal([]byte(configJson), &config)
 		if unmarshallError != nil {
@@ -78,6 +151,9 @@ Install command is reentrant, it is possible to update the cluster with re-runni
 			return configError
 		}
 		fmt.Printf("Vamp Configuration validated.\n")
+		if renderManifests {
+			return renderInstallation(validatedConfig)
+		}
 		url, cert, _, err := kubeclient.InstallVampService(validatedConfig, kubeConfigPath)
 		if err != nil {
 			return err
@@ -88,18 +164,73 @@ Install command is reentrant, it is possible to update the cluster with re-runni
 		}
 		fmt.Printf("Vamp Service Installed.\n")
 		fmt.Printf("Login with:\n")
+		if kubeclient.IsClusterInternal(validatedConfig) {
+			fmt.Printf("%v login --port-forward --namespace %v --release-name %v --user root\n", AppName, validatedConfig.Namespace, validatedConfig.ReleaseName)
+			return nil
+		}
 		fmt.Printf("%v login --url %v --user root --cert %v\n", AppName, url, certFileName)
 		return nil
 	},
 }
 
+func renderInstallation(config *models.VampConfig) error {
+	if renderOutputDir == "" {
+		return errors.New("Output directory is required to render manifests")
+	}
+	manifests, cert, err := kubeclient.RenderVampManifests(config, renderHost)
+	if err != nil {
+		return err
+	}
+	if renderKustomize {
+		kustomization, kustomizeErr := kubeclient.RenderKustomization(manifests)
+		if kustomizeErr != nil {
+			return kustomizeErr
+		}
+		manifests = append(manifests, kustomization)
+	}
+	if mkdirErr := os.MkdirAll(renderOutputDir, 0755); mkdirErr != nil {
+		return mkdirErr
+	}
+	for _, manifest := range manifests {
+		if writeErr := ioutil.WriteFile(filepath.Join(renderOutputDir, manifest.FileName), manifest.Data, 0644); writeErr != nil {
+			return writeErr
+		}
+	}
+	fmt.Printf("%v manifests are rendered into %v\n", len(manifests), renderOutputDir)
+	fmt.Printf("Warning: secrets are rendered as plain manifests, encrypt them before they are committed\n")
+	if renderHost == "" {
+		fmt.Printf("Warning: replace %v, %v and %v in the manifests before they are applied\n",
+			kubeclient.PlaceholderHost, kubeclient.PlaceholderCertificate, kubeclient.PlaceholderKey)
+		return nil
+	}
+	if cert == nil {
+		fmt.Printf("Warning: certificate is not known before installation, login with the certificate of the configured TLS secret\n")
+		return nil
+	}
+	if writeErr := ioutil.WriteFile(certFileName, cert, 0644); writeErr != nil {
+		return writeErr
+	}
+	fmt.Printf("Login after the manifests are applied with:\n")
+	endpoint := kubeclient.Endpoint{Host: renderHost, Port: kubeclient.VampPort}
+	if config.Ingress != nil {
+		endpoint = kubeclient.Endpoint{Host: config.Ingress.Host, Port: kubeclient.IngressPort}
+	}
+	fmt.Printf("%v login --url %v --user root --cert %v\n", AppName, endpoint.URL(), certFileName)
+	return nil
+}
+
 func init() {
 	rootCmd.AddCommand(installCmd)
 
 	installCmd.Flags().StringVarP(&installConfigPath, "configuration", "", "", "Installation configuration file path")
 	installCmd.MarkFlagRequired("configuration")
 	installCmd.Flags().StringVarP(&configFileType, "input", "i", "yaml", "Configuration file type yaml or json")
+	installCmd.Flags().StringSliceVarP(&configPatches, "patch", "", []string{}, "Configuration patch file path in yaml or json, can be repeated")
 	installCmd.Flags().StringVarP(&certFileName, "certificate-output-path", "", "certificate.crt", "Certificate file output path")
 	installCmd.Flags().StringVarP(&kubeConfigPath, "kubeconfig", "", "", "Kube Config path")
+	installCmd.Flags().BoolVarP(&renderManifests, "render", "", false, "Render manifests into the output directory instead of installing")
+	installCmd.Flags().StringVarP(&renderOutputDir, "output", "o", "", "Output directory of rendered manifests")
+	installCmd.Flags().StringVarP(&renderHost, "host", "", "", "External host of rendered manifests, placeholders are rendered if it is empty")
+	installCmd.Flags().BoolVarP(&renderKustomize, "kustomize", "", false, "Render a kustomization that makes the manifests a kustomize base")
 	viper.BindEnv("kubeconfig", "KUBECONFIG")
 }
@@ -0,0 +1,142 @@
+// Copyright © 2019 Developer developer@vamp.io
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/magneticio/vampkubistcli/kubernetes"
+	"github.com/magneticio/vampkubistcli/util"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var recommendOptions = kubeclient.DefaultRecommendOptions
+var recommendSamples int
+var recommendInterval time.Duration
+var recommendRecordFile string
+var recommendOutputType string
+
+var installRecommendCmd = &cobra.Command{
+	Use:   "recommend",
+	Short: "Recommend resources of the Vamp installation from observed usage",
+	Long: AddAppName(`Recommend resources of the Vamp installation from observed usage
+Usage of vamp pods is sampled, or read from a file recorded with k8smetrics record.
+Requests are the 95th percentile and limits are the maximum of usage with headroom,
+autoscaling targets are the target utilisation of requests.
+The output is a configuration patch for install, or the whole configuration if one is given.
+
+Example:
+    $AppName install recommend --samples 30 --interval 10s > resources.yml
+    $AppName install --configuration installconfig.yml --patch resources.yml
+    $AppName k8smetrics record --release-name vamp --duration 24h --out vamp.metrics
+    $AppName install recommend --record vamp.metrics --headroom 0.5 --configuration installconfig.yml`),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if kubeConfigPath == "" {
+			kubeConfigPath = viper.GetString("kubeconfig")
+		}
+		var cpu, memory []float64
+		if recommendRecordFile != "" {
+			file, openError := os.Open(recommendRecordFile)
+			if openError != nil {
+				return openError
+			}
+			defer file.Close()
+			records, readError := kubeclient.ReadRecords(file)
+			if readError != nil {
+				return readError
+			}
+			for _, summary := range kubeclient.SummariseRecords(records) {
+				cpu = append(cpu, summary.CPU.Values...)
+				memory = append(memory, summary.Memory.Values...)
+			}
+		} else {
+			if recommendSamples < 1 {
+				return errors.New("At least one sample is required")
+			}
+			options := kubeclient.MetricsOptions{Namespace: namespace, LabelSelector: labelSelector}
+			if options.LabelSelector == "" {
+				options.LabelSelector = kubeclient.NamesOf(releaseName).PodSelector()
+			}
+			for i := 0; i < recommendSamples; i++ {
+				if i > 0 {
+					time.Sleep(recommendInterval)
+				}
+				metrics, err := kubeclient.GetAverageMetricsWithOptions(kubeConfigPath, options)
+				if err != nil {
+					return err
+				}
+				for _, m := range metrics {
+					cpu = append(cpu, m.CPU)
+					memory = append(memory, m.Memory)
+				}
+			}
+		}
+
+		recommendation, err := kubeclient.RecommendVampConfig(cpu, memory, recommendOptions)
+		if err != nil {
+			return err
+		}
+		SourceRaw, marshalError := json.Marshal(recommendation)
+		if marshalError != nil {
+			return marshalError
+		}
+		result := string(SourceRaw)
+		if installConfigPath != "" {
+			configSource, readErr := util.UseSourceUrl(installConfigPath)
+			if readErr != nil {
+				return readErr
+			}
+			configJson, convertErr := util.Convert(configFileType, "json", configSource)
+			if convertErr != nil {
+				return convertErr
+			}
+			merged, mergeErr := util.Merge(configJson, result, "json")
+			if mergeErr != nil {
+				return mergeErr
+			}
+			result = merged
+		}
+		output, convertError := util.Convert("json", recommendOutputType, result)
+		if convertError != nil {
+			return convertError
+		}
+		fmt.Printf("%v", output)
+		return nil
+	},
+}
+
+func init() {
+	installCmd.AddCommand(installRecommendCmd)
+
+	installRecommendCmd.Flags().StringVarP(&kubeConfigPath, "kubeconfig", "", "", "Kube Config path")
+	installRecommendCmd.Flags().StringVarP(&namespace, "namespace", "", kubeclient.InstallationNamespace, "Namespace of the installation")
+	installRecommendCmd.Flags().StringVarP(&labelSelector, "selector", "l", "", "Label selector of vamp pods, pods of the release are selected if it is empty")
+	installRecommendCmd.Flags().StringVarP(&releaseName, "release-name", "", "", "Release name of the installation, the default release is used if it is empty")
+	installRecommendCmd.Flags().IntVarP(&recommendSamples, "samples", "", 1, "Number of usage samples")
+	installRecommendCmd.Flags().DurationVarP(&recommendInterval, "interval", "", 10*time.Second, "Interval between usage samples")
+	installRecommendCmd.Flags().StringVarP(&recommendRecordFile, "record", "", "", "File recorded with k8smetrics record to use instead of sampling")
+	installRecommendCmd.Flags().Float64VarP(&recommendOptions.Headroom, "headroom", "", kubeclient.DefaultRecommendOptions.Headroom, "Headroom over observed usage, 0.3 is 30% more")
+	installRecommendCmd.Flags().Float64VarP(&recommendOptions.TargetUtilisation, "target-utilisation", "", kubeclient.DefaultRecommendOptions.TargetUtilisation, "Share of requests that autoscaling targets")
+	installRecommendCmd.Flags().StringVarP(&installConfigPath, "configuration", "", "", "Installation configuration to merge the recommendation into")
+	installRecommendCmd.Flags().StringVarP(&configFileType, "input", "i", "yaml", "Configuration file type yaml or json")
+	installRecommendCmd.Flags().StringVarP(&recommendOutputType, "output", "o", "yaml", "Output format yaml or json")
+}
@@ -0,0 +1,77 @@
+// Copyright © 2019 Developer developer@vamp.io
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/magneticio/vampkubistcli/kubernetes"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var rotateCertFileName string
+
+var installRotateCertsCmd = &cobra.Command{
+	Use:   "rotate-certs",
+	Short: "Rotate the certificate of the Vamp installation",
+	Long: AddAppName(`Rotate the certificate of the Vamp installation
+A new self-signed certificate is generated for the external host of vamp and stored in its secret,
+vamp is restarted and rotation waits until the new certificate is served, unless vamp is only reachable in the cluster.
+The certificate of the local configuration is updated if it is logged in to the installation.
+Certificates that are brought along or issued by cert-manager should be renewed where they are issued.
+
+Example:
+    $AppName install rotate-certs
+    $AppName install rotate-certs --namespace vamp-staging --release-name staging --certificate-output-path ./certificate.crt`),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if kubeConfigPath == "" {
+			kubeConfigPath = viper.GetString("kubeconfig")
+		}
+		url, cert, err := kubeclient.RotateVampCertificates(kubeConfigPath, namespace, releaseName)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Certificate of %v is rotated.\n", url)
+		if Config.Url == url {
+			Config.Cert = string(cert)
+			if writeConfigError := WriteConfigFile(); writeConfigError != nil {
+				return writeConfigError
+			}
+			fmt.Printf("Certificate of the configuration is updated.\n")
+		} else {
+			fmt.Printf("Warning: configuration is not logged in to %v, login with the new certificate\n", url)
+		}
+		if rotateCertFileName != "" {
+			if writeError := ioutil.WriteFile(rotateCertFileName, cert, 0644); writeError != nil {
+				return writeError
+			}
+			fmt.Printf("Certificate is written to %v\n", rotateCertFileName)
+		}
+		return nil
+	},
+}
+
+func init() {
+	installCmd.AddCommand(installRotateCertsCmd)
+
+	installRotateCertsCmd.Flags().StringVarP(&kubeConfigPath, "kubeconfig", "", "", "Kube Config path")
+	installRotateCertsCmd.Flags().StringVarP(&namespace, "namespace", "", kubeclient.InstallationNamespace, "Namespace of the installation")
+	installRotateCertsCmd.Flags().StringVarP(&releaseName, "release-name", "", "", "Release name of the installation, the default release is used if it is empty")
+	installRotateCertsCmd.Flags().StringVarP(&rotateCertFileName, "certificate-output-path", "", "", "Certificate file output path, the certificate is not written if it is empty")
+}
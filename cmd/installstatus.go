@@ -0,0 +1,95 @@
+// Copyright © 2019 Developer developer@vamp.io
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/magneticio/vampkubistcli/kubernetes"
+	"github.com/magneticio/vampkubistcli/util"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var statusOutputType string
+
+var installStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Report the health of the Vamp installation",
+	Long: AddAppName(`Report the health of the Vamp installation
+The vamp deployment, its autoscaler, the database, services, secrets and the cluster role binding are checked.
+The report includes the expiry of the certificate, whether the API answers and recent warning events.
+Status exits with an error if anything is unhealthy.
+
+Example:
+    $AppName install status
+    $AppName install status --namespace vamp-staging --release-name staging -o json`),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if kubeConfigPath == "" {
+			kubeConfigPath = viper.GetString("kubeconfig")
+		}
+		status, err := kubeclient.GetVampStatus(kubeConfigPath, namespace, releaseName)
+		if err != nil {
+			return err
+		}
+		if statusOutputType == "text" {
+			printStatus(status)
+		} else {
+			SourceRaw, marshalError := json.Marshal(status)
+			if marshalError != nil {
+				return marshalError
+			}
+			output, convertError := util.Convert("json", statusOutputType, string(SourceRaw))
+			if convertError != nil {
+				return convertError
+			}
+			fmt.Printf("%v", output)
+		}
+		if !status.Healthy() {
+			return errors.New("Installation is not healthy")
+		}
+		return nil
+	},
+}
+
+func printStatus(status *kubeclient.InstallationStatus) {
+	fmt.Printf("Release %v in namespace %v\n", status.ReleaseName, status.Namespace)
+	for _, check := range status.Checks {
+		state := "OK"
+		if !check.Healthy {
+			state = "FAIL"
+		}
+		fmt.Printf("%-4v %v: %v\n", state, check.Name, check.Message)
+	}
+	if len(status.Events) > 0 {
+		fmt.Printf("Recent warning events:\n")
+		for _, event := range status.Events {
+			fmt.Printf("  %v\n", event)
+		}
+	}
+}
+
+func init() {
+	installCmd.AddCommand(installStatusCmd)
+
+	installStatusCmd.Flags().StringVarP(&kubeConfigPath, "kubeconfig", "", "", "Kube Config path")
+	installStatusCmd.Flags().StringVarP(&namespace, "namespace", "", kubeclient.InstallationNamespace, "Namespace of the installation")
+	installStatusCmd.Flags().StringVarP(&releaseName, "release-name", "", "", "Release name of the installation, the default release is used if it is empty")
+	installStatusCmd.Flags().StringVarP(&statusOutputType, "output", "o", "text", "Output format text, yaml or json")
+}
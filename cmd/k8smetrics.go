@@ -17,15 +17,30 @@ package cmd
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"strconv"
+	"time"
+
 	"github.com/ghodss/yaml"
+	"github.com/magneticio/vampkubistcli/client"
 	"github.com/magneticio/vampkubistcli/kubernetes"
+	"github.com/magneticio/vampkubistcli/logging"
+	"github.com/magneticio/vampkubistcli/models"
+	"github.com/magneticio/vampkubistcli/stats"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 var namespace string
 var metricsKind string
+var pushK8sMetrics bool
+var labelSelector string
+var allNamespaces bool
+var groupByLabels []string
+var nearLimitPercentage float64
+var releaseName string
 
 // bootstrapCmd represents the bootstrap command
 var k8sMetricsCmd = &cobra.Command{
@@ -33,8 +48,24 @@ var k8sMetricsCmd = &cobra.Command{
 	Short: "get k8s metrics",
 	Long: AddAppName(`Get k8s pods metrics for a given namespace
 
+With --push, average metrics of pods are gathered on every interval,
+pods are grouped into destinations and subsets by their labels with the subset map
+and cpu and memory metrics across pods of each subset are pushed to vamp.
+
+Pods can be selected with a label selector in a namespace or in all namespaces,
+pods of a Vamp installation can be selected with its release name,
+kind group sums and averages cpu and memory of pods per values of the group by labels.
+Kind utilisation reports usage of containers as a percentage of their requests and limits
+and flags pods near their limits, kind nodes reports usage of nodes and their allocatable resources.
+
 Example:
     $AppName k8smetrics
+    $AppName k8smetrics --namespace shop --selector app=shop,version=v2 --kind average
+    $AppName k8smetrics --all-namespaces --group-by app,version
+    $AppName k8smetrics --kind utilisation --near-limit 80
+    $AppName k8smetrics --kind nodes
+    $AppName k8smetrics --namespace vamp-staging --release-name staging --kind utilisation
+    $AppName k8smetrics --namespace shop --push --interval 15s
   `),
 	SilenceUsage:  true,
 	SilenceErrors: true,
@@ -43,17 +74,43 @@ Example:
 			kubeConfigPath = viper.GetString("kubeconfig")
 		}
 
+		options := kubeclient.MetricsOptions{
+			Namespace:     namespace,
+			LabelSelector: podSelector(),
+			AllNamespaces: allNamespaces,
+		}
+
+		if pushK8sMetrics {
+			return pushPodMetrics(options)
+		}
+
+		if len(groupByLabels) > 0 && !cmd.Flags().Changed("kind") {
+			metricsKind = "group"
+		}
+
 		var pods kubeclient.PodMetricsList
 		var err error
 		var avgMetrics []kubeclient.PodAverageMetrics
 		var podMetrics []kubeclient.PodContainersMetrics
+		var groupMetrics []kubeclient.PodGroupMetrics
+		var utilisation []kubeclient.PodUtilisation
+		var nodeMetrics []kubeclient.NodeMetrics
 		switch metricsKind {
 		case "processed":
-			err = kubeclient.GetProcessedMetrics(kubeConfigPath, namespace, &pods)
+			err = kubeclient.GetProcessedMetricsWithOptions(kubeConfigPath, options, &pods)
 		case "average":
-			avgMetrics, err = kubeclient.GetAverageMetrics(kubeConfigPath, namespace)
+			avgMetrics, err = kubeclient.GetAverageMetricsWithOptions(kubeConfigPath, options)
 		case "simple":
-			podMetrics, err = kubeclient.GetSimpleMetrics(kubeConfigPath, namespace)
+			podMetrics, err = kubeclient.GetSimpleMetricsWithOptions(kubeConfigPath, options)
+		case "group":
+			if len(groupByLabels) == 0 {
+				return errors.New("Kind group requires group by labels")
+			}
+			groupMetrics, err = kubeclient.GetGroupMetrics(kubeConfigPath, options, groupByLabels)
+		case "utilisation":
+			utilisation, err = kubeclient.GetUtilisation(kubeConfigPath, options, nearLimitPercentage)
+		case "nodes":
+			nodeMetrics, err = kubeclient.GetNodeMetrics(kubeConfigPath, labelSelector)
 		default:
 			return fmt.Errorf(`Bad metrics kind "%v"`, metricsKind)
 		}
@@ -71,6 +128,12 @@ Example:
 			js, err = json.Marshal(avgMetrics)
 		case "simple":
 			js, err = json.Marshal(podMetrics)
+		case "group":
+			js, err = json.Marshal(groupMetrics)
+		case "utilisation":
+			js, err = json.Marshal(utilisation)
+		case "nodes":
+			js, err = json.Marshal(nodeMetrics)
 		default:
 			return fmt.Errorf(`Bad metrics kind "%v"`, metricsKind)
 		}
@@ -98,12 +161,93 @@ Example:
 	},
 }
 
+// pushPodMetrics gathers and pushes pod metrics on every interval, failures are reported and do not stop pushing
+func pushPodMetrics(options kubeclient.MetricsOptions) error {
+	if pushInterval <= 0 {
+		return errors.New("Interval should be positive")
+	}
+	restClient := client.NewRestClient(Config.Url, Config.Token, Config.APIVersion, logging.Verbose, Config.Cert, &TokenStore)
+	if restClient == nil {
+		return errors.New("Client can not be created, check your configuration")
+	}
+	values := make(map[string]string)
+	values["project"] = Config.Project
+	values["cluster"] = Config.Cluster
+	values["virtual_cluster"] = Config.VirtualCluster
+	push := func() {
+		pods, err := kubeclient.GetAverageMetricsWithOptions(kubeConfigPath, options)
+		if err != nil {
+			fmt.Printf("Warning: pod metrics can not be gathered - %v\n", err)
+			return
+		}
+		subsetMap, err := restClient.GetSubsetMap(values)
+		if err != nil {
+			fmt.Printf("Warning: subset map can not be retrieved - %v\n", err)
+			return
+		}
+		pushMetricValues(restClient, podMetricValues(pods, subsetMap, time.Now().UnixNano()/int64(time.Millisecond)))
+	}
+	push()
+	ticker := time.NewTicker(pushInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		push()
+	}
+	return nil
+}
+
+// podMetricValues groups pods into subsets by labels and describes cpu and memory across pods of each subset
+func podMetricValues(pods []kubeclient.PodAverageMetrics, subsetMap *models.DestinationsSubsetsMap, timestamp int64) map[metricKey]models.MetricValue {
+	cpu := make(map[metricKey][]float64)
+	memory := make(map[metricKey][]float64)
+	for _, pod := range pods {
+		for _, resolution := range client.ResolveSubsets(subsetMap, pod.Labels) {
+			key := metricKey{Destination: resolution.Destination, Subset: resolution.Subset}
+			if len(resolution.Ports) == 1 {
+				key.Port = strconv.Itoa(resolution.Ports[0].Port)
+			}
+			// pods without parsable container metrics have NaN averages
+			if !math.IsNaN(pod.CPU) {
+				cpu[key] = append(cpu[key], pod.CPU)
+			}
+			if !math.IsNaN(pod.Memory) {
+				memory[key] = append(memory[key], pod.Memory)
+			}
+		}
+	}
+	res := make(map[metricKey]models.MetricValue)
+	for key, podValues := range cpu {
+		key.Metric = "cpu"
+		res[key] = stats.Describe(podValues, timestamp)
+	}
+	for key, podValues := range memory {
+		key.Metric = "memory"
+		res[key] = stats.Describe(podValues, timestamp)
+	}
+	return res
+}
+
+// podSelector returns the label selector of pods, pods of the vamp deployment are selected by a release name
+func podSelector() string {
+	if labelSelector == "" && releaseName != "" {
+		return kubeclient.NamesOf(releaseName).PodSelector()
+	}
+	return labelSelector
+}
+
 func init() {
 	rootCmd.AddCommand(k8sMetricsCmd)
 
-	k8sMetricsCmd.Flags().StringVarP(&namespace, "namespace", "", "vamp-system", "Namespace")
+	k8sMetricsCmd.Flags().StringVarP(&namespace, "namespace", "", kubeclient.InstallationNamespace, "Namespace")
 	k8sMetricsCmd.Flags().StringVarP(&kubeConfigPath, "kubeconfig", "", "", "Kube Config path")
 	k8sMetricsCmd.Flags().StringVarP(&OutputType, "output", "o", "yaml", "Output format yaml or json")
-	k8sMetricsCmd.Flags().StringVarP(&metricsKind, "kind", "k", "simple", "Kind of metrics, simple, processed or average")
+	k8sMetricsCmd.Flags().StringVarP(&metricsKind, "kind", "k", "simple", "Kind of metrics, simple, processed, average, group, utilisation or nodes")
+	k8sMetricsCmd.Flags().StringVarP(&labelSelector, "selector", "l", "", "Label selector of pods, e.g. app=shop,version=v2")
+	k8sMetricsCmd.Flags().BoolVarP(&allNamespaces, "all-namespaces", "A", false, "Metrics of pods in all namespaces")
+	k8sMetricsCmd.Flags().StringVarP(&releaseName, "release-name", "", "", "Release name of a Vamp installation to select its pods if no selector is given")
+	k8sMetricsCmd.Flags().StringSliceVarP(&groupByLabels, "group-by", "", []string{}, "Labels to group pods by, e.g. app,version")
+	k8sMetricsCmd.Flags().Float64VarP(&nearLimitPercentage, "near-limit", "", kubeclient.DefaultNearLimitPercentage, "Usage as a percentage of a limit that pods are flagged as near their limits from")
+	k8sMetricsCmd.Flags().BoolVarP(&pushK8sMetrics, "push", "", false, "Continuously push cpu and memory metrics of subsets to vamp")
+	k8sMetricsCmd.Flags().DurationVarP(&pushInterval, "interval", "", 15*time.Second, "Interval to push metrics")
 	viper.BindEnv("kubeconfig", "KUBECONFIG")
 }
@@ -0,0 +1,195 @@
+// Copyright © 2019 Developer developer@vamp.io
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+	"time"
+
+	"github.com/magneticio/vampkubistcli/kubernetes"
+	"github.com/magneticio/vampkubistcli/util"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var recordInterval time.Duration
+var recordDuration time.Duration
+var recordFile string
+var reportOutputType string
+var sparklineWidth int
+var memoryHeadroom float64
+
+var k8sMetricsRecordCmd = &cobra.Command{
+	Use:   "record",
+	Short: "Record k8s pods metrics into a file",
+	Long: AddAppName(`Record average metrics of pods periodically into a compressed time series file
+Recording stops after the duration or when it is interrupted, completed samples are kept in both cases.
+
+Example:
+    $AppName k8smetrics record --namespace shop --interval 30s --duration 24h --out shop.metrics
+    $AppName k8smetrics report shop.metrics`),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if recordFile == "" {
+			return errors.New("Output file is required")
+		}
+		if recordInterval <= 0 {
+			return errors.New("Interval should be positive")
+		}
+		if kubeConfigPath == "" {
+			kubeConfigPath = viper.GetString("kubeconfig")
+		}
+		options := kubeclient.MetricsOptions{
+			Namespace:     namespace,
+			LabelSelector: podSelector(),
+			AllNamespaces: allNamespaces,
+		}
+		file, createError := os.Create(recordFile)
+		if createError != nil {
+			return createError
+		}
+		defer file.Close()
+		writer := kubeclient.NewRecordWriter(file)
+		defer writer.Close()
+
+		interrupt := make(chan os.Signal, 1)
+		signal.Notify(interrupt, os.Interrupt)
+		defer signal.Stop(interrupt)
+		var deadline <-chan time.Time
+		if recordDuration > 0 {
+			deadline = time.After(recordDuration)
+		}
+		ticker := time.NewTicker(recordInterval)
+		defer ticker.Stop()
+
+		samples := 0
+		for {
+			metrics, err := kubeclient.GetAverageMetricsWithOptions(kubeConfigPath, options)
+			if err != nil {
+				fmt.Printf("Warning: pod metrics can not be gathered - %v\n", err)
+			} else {
+				if err := writer.Write(kubeclient.NewMetricsRecord(time.Now().UnixNano()/int64(time.Millisecond), metrics)); err != nil {
+					return err
+				}
+				samples++
+			}
+			select {
+			case <-ticker.C:
+			case <-deadline:
+				fmt.Printf("%v samples are recorded into %v\n", samples, recordFile)
+				return nil
+			case <-interrupt:
+				fmt.Printf("%v samples are recorded into %v\n", samples, recordFile)
+				return nil
+			}
+		}
+	},
+}
+
+var k8sMetricsReportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Report recorded k8s pods metrics",
+	Long: AddAppName(`Report min, average, max and 95th percentile of cpu and memory per pod of a recorded file
+Suggested cpu request is the 95th percentile and suggested memory limit is the max with headroom,
+they can be used to right-size resources in the installation configuration.
+
+Example:
+    $AppName k8smetrics report shop.metrics
+    $AppName k8smetrics report shop.metrics --width 60 --headroom 0.5
+    $AppName k8smetrics report shop.metrics -o json`),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return errors.New("Not Enough Arguments")
+		}
+		file, openError := os.Open(args[0])
+		if openError != nil {
+			return openError
+		}
+		defer file.Close()
+		records, readError := kubeclient.ReadRecords(file)
+		if readError != nil {
+			return readError
+		}
+		summaries := kubeclient.SummariseRecords(records)
+
+		if reportOutputType == "yaml" || reportOutputType == "json" {
+			SourceRaw, marshalError := json.Marshal(summaries)
+			if marshalError != nil {
+				return marshalError
+			}
+			result, convertError := util.Convert("json", reportOutputType, string(SourceRaw))
+			if convertError != nil {
+				return convertError
+			}
+			fmt.Printf("%v", result)
+			return nil
+		}
+
+		if len(records) > 0 {
+			from := time.Unix(0, records[0].Timestamp*int64(time.Millisecond))
+			to := time.Unix(0, records[len(records)-1].Timestamp*int64(time.Millisecond))
+			fmt.Printf("%v samples from %v to %v\n\n", len(records), from.Format(time.RFC3339), to.Format(time.RFC3339))
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAMESPACE\tPOD\tSAMPLES\tCPU MIN/AVG/MAX/P95\tCPU\tMEMORY MIN/AVG/MAX/P95\tMEMORY\tREQUEST CPU\tLIMIT MEMORY")
+		for _, s := range summaries {
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v/%v/%v/%v\t%v\t%v/%v/%v/%v\t%v\t%v\t%v\n",
+				s.Namespace, s.Name, s.Samples,
+				formatCPU(s.CPU.Min), formatCPU(s.CPU.Average), formatCPU(s.CPU.Max), formatCPU(s.CPU.P95),
+				util.Sparkline(s.CPU.Values, sparklineWidth),
+				formatMemory(s.Memory.Min), formatMemory(s.Memory.Average), formatMemory(s.Memory.Max), formatMemory(s.Memory.P95),
+				util.Sparkline(s.Memory.Values, sparklineWidth),
+				formatCPU(s.CPU.P95), formatMemory(s.Memory.Max*(1+memoryHeadroom)))
+		}
+		return w.Flush()
+	},
+}
+
+// formatCPU formats cores as millicores rounded up
+func formatCPU(cores float64) string {
+	return fmt.Sprintf("%vm", int64(math.Ceil(kubeclient.Millicores.FromBase(cores))))
+}
+
+// formatMemory formats bytes as mebibytes rounded up
+func formatMemory(bytes float64) string {
+	return fmt.Sprintf("%vMi", int64(math.Ceil(kubeclient.Mebibytes.FromBase(bytes))))
+}
+
+func init() {
+	k8sMetricsCmd.AddCommand(k8sMetricsRecordCmd)
+	k8sMetricsCmd.AddCommand(k8sMetricsReportCmd)
+
+	k8sMetricsRecordCmd.Flags().StringVarP(&namespace, "namespace", "", kubeclient.InstallationNamespace, "Namespace")
+	k8sMetricsRecordCmd.Flags().StringVarP(&kubeConfigPath, "kubeconfig", "", "", "Kube Config path")
+	k8sMetricsRecordCmd.Flags().StringVarP(&labelSelector, "selector", "l", "", "Label selector of pods, e.g. app=shop,version=v2")
+	k8sMetricsRecordCmd.Flags().BoolVarP(&allNamespaces, "all-namespaces", "A", false, "Metrics of pods in all namespaces")
+	k8sMetricsRecordCmd.Flags().StringVarP(&releaseName, "release-name", "", "", "Release name of a Vamp installation to select its pods if no selector is given")
+	k8sMetricsRecordCmd.Flags().DurationVarP(&recordInterval, "interval", "", 15*time.Second, "Interval between samples")
+	k8sMetricsRecordCmd.Flags().DurationVarP(&recordDuration, "duration", "", time.Hour, "Duration of the recording, 0 records until interrupted")
+	k8sMetricsRecordCmd.Flags().StringVarP(&recordFile, "out", "", "", "File to record samples into")
+
+	k8sMetricsReportCmd.Flags().StringVarP(&reportOutputType, "output", "o", "table", "Output format table, yaml or json")
+	k8sMetricsReportCmd.Flags().IntVarP(&sparklineWidth, "width", "", 30, "Maximum width of sparklines")
+	k8sMetricsReportCmd.Flags().Float64VarP(&memoryHeadroom, "headroom", "", 0.2, "Headroom over max memory for the suggested memory limit")
+}
@@ -19,10 +19,14 @@ import (
 	"fmt"
 	"syscall"
 	"io/ioutil"
+	"os"
+	"os/signal"
 	"github.com/magneticio/vampkubistcli/client"
+	"github.com/magneticio/vampkubistcli/kubernetes"
 	"github.com/magneticio/vampkubistcli/logging"
 	"github.com/magneticio/vampkubistcli/util"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 	"golang.org/x/crypto/ssh/terminal"
 )
 
@@ -31,6 +35,9 @@ var Username string
 var Password string
 var Cert string
 
+var portForward bool
+var localPort int
+
 var initial bool
 
 var WelcomeText = AddAppName(`
@@ -54,6 +61,13 @@ Example:
   It is also possible to pass certificate with cert parameter
   $AppName login --url https://1.2.3.4:8888 --user username --password password --cert file-or-string
 
+  Vamp that is not exposed outside of the cluster can be reached by port forwarding,
+  the certificate of the installation is used if no certificate is passed.
+  It should be valid for localhost, like certificates that are generated by installation are,
+  certificates that are brought along or issued by cert-manager need localhost in their DNS names.
+  Forwarding is kept open until it is interrupted:
+  $AppName login --port-forward --local-port 8888 --namespace vamp-system --user username
+
   Interactive password input is enabled if username is entered
   but password is not passed for security:
 
@@ -68,6 +82,13 @@ Example:
 	SilenceUsage:  true,
 	SilenceErrors: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if portForward {
+			stopPortForward, portForwardErr := startPortForward()
+			if portForwardErr != nil {
+				return portForwardErr
+			}
+			defer close(stopPortForward)
+		}
 		if Url != "" {
 			Config.Url = Url
 		}
@@ -143,10 +164,48 @@ Example:
 		if initial {
 			fmt.Println(WelcomeText)
 		}
+		if portForward {
+			fmt.Printf("Port forwarding to %v is open, interrupt to close it.\n", Config.Url)
+			signals := make(chan os.Signal, 1)
+			signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+			<-signals
+		}
 		return nil
 	},
 }
 
+// startPortForward forwards the local port to vamp and sets the url and certificate of login
+func startPortForward() (chan struct{}, error) {
+	if kubeConfigPath == "" {
+		kubeConfigPath = viper.GetString("kubeconfig")
+	}
+	if Cert == "" {
+		crt, certErr := kubeclient.GetVampCertificateOfRelease(kubeConfigPath, namespace, releaseName)
+		if certErr != nil {
+			return nil, certErr
+		}
+		// the forwarded port is reached as localhost
+		if verifyErr := util.VerifyCertForHost(fmt.Sprintf("https://localhost:%v", localPort), string(crt)); verifyErr != nil {
+			return nil, fmt.Errorf("Certificate of the installation is not valid for localhost so port forwarding can not be verified, "+
+				"generated certificates are valid for it, others need localhost in their DNS names - %v", verifyErr)
+		}
+		Cert = string(crt)
+	}
+	stopChan := make(chan struct{})
+	readyChan := make(chan struct{})
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- kubeclient.PortForwardVamp(kubeConfigPath, namespace, releaseName, localPort, stopChan, readyChan)
+	}()
+	select {
+	case <-readyChan:
+	case err := <-errChan:
+		return nil, err
+	}
+	Url = fmt.Sprintf("https://localhost:%v", localPort)
+	return stopChan, nil
+}
+
 func init() {
 	rootCmd.AddCommand(loginCmd)
 
@@ -155,5 +214,10 @@ func init() {
 	loginCmd.Flags().StringVarP(&Password, "password", "", "", "Password required")
 	loginCmd.Flags().StringVarP(&Cert, "cert", "", "", "Cert from file, url or string")
 	loginCmd.Flags().BoolVarP(&initial, "initial", "", false, "Prints welcome string for new users.")
+	loginCmd.Flags().BoolVarP(&portForward, "port-forward", "", false, "Login through port forwarding to a vamp pod of the cluster")
+	loginCmd.Flags().IntVarP(&localPort, "local-port", "", kubeclient.VampPort, "Local port of port forwarding")
+	loginCmd.Flags().StringVarP(&kubeConfigPath, "kubeconfig", "", "", "Kube Config path")
+	loginCmd.Flags().StringVarP(&namespace, "namespace", "", kubeclient.InstallationNamespace, "Namespace of the installation to forward to")
+	loginCmd.Flags().StringVarP(&releaseName, "release-name", "", "", "Release name of the installation to forward to, the default release is used if it is empty")
 
 }
@@ -0,0 +1,329 @@
+// Copyright © 2019 Developer developer@vamp.io
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/magneticio/vampkubistcli/client"
+	"github.com/magneticio/vampkubistcli/logging"
+	"github.com/magneticio/vampkubistcli/models"
+	"github.com/magneticio/vampkubistcli/stats"
+	"github.com/spf13/cobra"
+)
+
+var samplesFormat string
+var samplesListen string
+var metricName string
+var slidingWindow time.Duration
+var pushInterval time.Duration
+var quantileAccuracy float64
+
+// rawSample is a single observation of a metric, timestamp is in milliseconds and 0 if it is not given
+type rawSample struct {
+	Metric      string  `json:"metric"`
+	Destination string  `json:"destination"`
+	Port        string  `json:"port"`
+	Subset      string  `json:"subset"`
+	Value       float64 `json:"value"`
+	Timestamp   int64   `json:"timestamp"`
+}
+
+type metricKey struct {
+	Metric      string
+	Destination string
+	Port        string
+	Subset      string
+}
+
+// metricAggregator keeps a sliding window per metric, destination, port and subset
+type metricAggregator struct {
+	mutex    sync.Mutex
+	size     int64
+	accuracy float64
+	windows  map[metricKey]*stats.SlidingWindow
+	latest   int64
+}
+
+func newMetricAggregator(window time.Duration, accuracy float64) *metricAggregator {
+	return &metricAggregator{
+		size:     int64(window / time.Millisecond),
+		accuracy: accuracy,
+		windows:  make(map[metricKey]*stats.SlidingWindow),
+	}
+}
+
+func (a *metricAggregator) Add(sample rawSample) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	key := metricKey{Metric: sample.Metric, Destination: sample.Destination, Port: sample.Port, Subset: sample.Subset}
+	window, ok := a.windows[key]
+	if !ok {
+		window = stats.NewSlidingWindow(a.size, stats.DefaultSlidingWindowBuckets, a.accuracy)
+		a.windows[key] = window
+	}
+	window.Add(sample.Timestamp, sample.Value)
+	if sample.Timestamp > a.latest {
+		a.latest = sample.Timestamp
+	}
+}
+
+// Latest returns the timestamp of the latest sample
+func (a *metricAggregator) Latest() int64 {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.latest
+}
+
+// Values returns metric values of windows that have observations, windows without observations are removed
+func (a *metricAggregator) Values(now int64) map[metricKey]models.MetricValue {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	res := make(map[metricKey]models.MetricValue)
+	for key, window := range a.windows {
+		window.Expire(now)
+		if window.Empty() {
+			delete(a.windows, key)
+			continue
+		}
+		res[key] = window.MetricValue(now)
+	}
+	return res
+}
+
+var metricsAggregateCmd = &cobra.Command{
+	Use:   "aggregate",
+	Short: "Aggregate raw samples into metric values and push them",
+	Long: AddAppName(`Aggregate raw samples into metric values and push them
+Samples are read from the file, standard input or an http listener.
+A sliding window is kept for every metric, destination, port and subset,
+count, average, standard deviation, sum, min, max, rate, median and percentiles
+are computed from the window and pushed on every interval.
+
+Samples are csv lines as value, metric,destination,subset,value or
+metric,destination,subset,value,timestamp, or json lines as
+{"metric": "latency", "destination": "dest-1", "port": "9191", "subset": "subset1", "value": 12.5, "timestamp": 1560000000000}.
+Missing fields are taken from the flags, timestamps are unix milliseconds and arrival time is used if it is omitted.
+Windows of samples that are read from a file end at the latest sample instead of the current time.
+The http listener accepts samples in the request body of POST /samples.
+
+Example:
+    tail -f latency.log | $AppName metrics aggregate --metric latency --destination dest-1 --subset subset1
+    $AppName metrics aggregate -f samples.jsonl --format jsonl --window 5m --interval 30s
+    $AppName metrics aggregate --listen :9090 --format jsonl`),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if samplesFormat != "csv" && samplesFormat != "jsonl" {
+			return fmt.Errorf("Samples format %v is not supported, use csv or jsonl", samplesFormat)
+		}
+		if slidingWindow < time.Second {
+			return errors.New("Window should be at least 1s")
+		}
+		if pushInterval <= 0 {
+			return errors.New("Interval should be positive")
+		}
+		defaults := rawSample{
+			Metric:      metricName,
+			Destination: Destination,
+			Port:        Port,
+			Subset:      Subset,
+		}
+		aggregator := newMetricAggregator(slidingWindow, quantileAccuracy)
+		restClient := client.NewRestClient(Config.Url, Config.Token, Config.APIVersion, logging.Verbose, Config.Cert, &TokenStore)
+		if restClient == nil {
+			return errors.New("Client can not be created, check your configuration")
+		}
+
+		inputDone := make(chan error, 1)
+		if samplesListen == "" || SourceFile != "" {
+			var reader io.Reader = os.Stdin
+			if SourceFile != "" && SourceFile != "-" {
+				file, openError := os.Open(SourceFile)
+				if openError != nil {
+					return openError
+				}
+				defer file.Close()
+				reader = file
+			}
+			go func() {
+				inputDone <- readSamples(reader, samplesFormat, defaults, aggregator.Add)
+			}()
+		}
+		listenerDone := make(chan error, 1)
+		if samplesListen != "" {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/samples", func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost {
+					http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+					return
+				}
+				if err := readSamples(r.Body, samplesFormat, defaults, aggregator.Add); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				w.WriteHeader(http.StatusAccepted)
+			})
+			go func() {
+				listenerDone <- http.ListenAndServe(samplesListen, mux)
+			}()
+			fmt.Printf("Listening for samples on %v\n", samplesListen)
+		}
+
+		now := func() int64 {
+			return time.Now().UnixNano() / int64(time.Millisecond)
+		}
+		if SourceFile != "" && SourceFile != "-" {
+			// recorded samples would have expired at the current time
+			now = aggregator.Latest
+		}
+		push := func() {
+			pushMetricValues(restClient, aggregator.Values(now()))
+		}
+		ticker := time.NewTicker(pushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				push()
+			case err := <-inputDone:
+				if err != nil {
+					return err
+				}
+				if samplesListen == "" {
+					push()
+					return nil
+				}
+			case err := <-listenerDone:
+				return err
+			}
+		}
+	},
+}
+
+// pushMetricValues pushes every value, failures are reported and do not stop the aggregation
+func pushMetricValues(restClient *client.RestClient, metricValues map[metricKey]models.MetricValue) {
+	keys := make([]metricKey, 0, len(metricValues))
+	for key := range metricValues {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+	})
+	for _, key := range keys {
+		metricValue := metricValues[key]
+		values := make(map[string]string)
+		values["project"] = Config.Project
+		values["cluster"] = Config.Cluster
+		values["virtual_cluster"] = Config.VirtualCluster
+		values["destination"] = key.Destination
+		values["port"] = key.Port
+		values["subset"] = key.Subset
+		if _, err := restClient.PushMetricValue(key.Metric, &metricValue, values); err != nil {
+			fmt.Printf("Warning: metric %v of %v %v can not be pushed - %v\n", key.Metric, key.Destination, key.Subset, err)
+			continue
+		}
+		logging.Info("metric %v of %v %v is pushed with %v elements\n", key.Metric, key.Destination, key.Subset, metricValue.NumberOfElements)
+	}
+}
+
+// readSamples parses samples line by line until the end of input, lines that can not be parsed are skipped
+func readSamples(reader io.Reader, format string, defaults rawSample, add func(rawSample)) error {
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sample, err := parseRawSample(line, format, defaults)
+		if err != nil {
+			fmt.Printf("Warning: skipping line %q - %v\n", line, err)
+			continue
+		}
+		if sample.Metric == "" || sample.Destination == "" {
+			fmt.Printf("Warning: skipping line %q - metric and destination are required\n", line)
+			continue
+		}
+		if sample.Timestamp == 0 {
+			sample.Timestamp = time.Now().UnixNano() / int64(time.Millisecond)
+		}
+		add(*sample)
+	}
+	return scanner.Err()
+}
+
+func parseRawSample(line string, format string, defaults rawSample) (*rawSample, error) {
+	sample := defaults
+	if format == "jsonl" {
+		if err := json.Unmarshal([]byte(line), &sample); err != nil {
+			return nil, err
+		}
+		return &sample, nil
+	}
+	fields := strings.Split(line, ",")
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	valueField := fields[0]
+	switch len(fields) {
+	case 1:
+	case 4, 5:
+		sample.Metric = fields[0]
+		sample.Destination = fields[1]
+		sample.Subset = fields[2]
+		valueField = fields[3]
+		if len(fields) == 5 {
+			timestamp, err := strconv.ParseInt(fields[4], 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			sample.Timestamp = timestamp
+		}
+	default:
+		return nil, errors.New("csv line should be value, metric,destination,subset,value or metric,destination,subset,value,timestamp")
+	}
+	value, err := strconv.ParseFloat(valueField, 64)
+	if err != nil {
+		return nil, err
+	}
+	sample.Value = value
+	return &sample, nil
+}
+
+func init() {
+	metricsCmd.AddCommand(metricsAggregateCmd)
+
+	metricsAggregateCmd.Flags().StringVarP(&SourceFile, "file", "f", "", "Samples file, standard input is used if it is - or if there is no listener")
+	metricsAggregateCmd.Flags().StringVarP(&samplesFormat, "format", "", "csv", "Samples format csv or jsonl")
+	metricsAggregateCmd.Flags().StringVarP(&samplesListen, "listen", "", "", "Address to listen for samples on, e.g. :9090")
+	metricsAggregateCmd.Flags().DurationVarP(&slidingWindow, "window", "", time.Minute, "Sliding window of samples that metric values are computed from")
+	metricsAggregateCmd.Flags().DurationVarP(&pushInterval, "interval", "", 15*time.Second, "Interval to push metric values")
+	metricsAggregateCmd.Flags().Float64VarP(&quantileAccuracy, "accuracy", "", stats.DefaultRelativeAccuracy, "Relative accuracy of estimated percentiles")
+	metricsAggregateCmd.Flags().StringVarP(&metricName, "metric", "", "", "Default metric name of samples")
+	metricsAggregateCmd.Flags().StringVarP(&Destination, "destination", "", "", "Default destination name of samples")
+	metricsAggregateCmd.Flags().StringVarP(&Port, "port", "", "", "Default port number of samples")
+	metricsAggregateCmd.Flags().StringVarP(&Subset, "subset", "", "", "Default subset name of samples")
+}
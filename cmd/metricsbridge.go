@@ -0,0 +1,223 @@
+// Copyright © 2019 Developer developer@vamp.io
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/magneticio/vampkubistcli/client"
+	"github.com/magneticio/vampkubistcli/logging"
+	"github.com/magneticio/vampkubistcli/models"
+	"github.com/magneticio/vampkubistcli/prometheus"
+	"github.com/spf13/cobra"
+)
+
+var scrapeTargets []string
+var scrapeTargetsFile string
+var mappingFile string
+var scrapeTimeout time.Duration
+var bridgeOnce bool
+
+var metricsBridgeCmd = &cobra.Command{
+	Use:   "bridge",
+	Short: "Bridge metrics of other systems to vamp",
+	Long: AddAppName(`Bridge metrics of other systems to vamp
+    Example:
+    $AppName metrics bridge prometheus --target http://shop:8080/metrics --mapping mapping.yaml`),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return errors.New("a metrics system expected")
+	},
+}
+
+var metricsBridgePrometheusCmd = &cobra.Command{
+	Use:   "prometheus",
+	Short: "Scrape prometheus endpoints and push mapped metrics",
+	Long: AddAppName(`Scrape prometheus endpoints and push mapped metrics
+Targets are prometheus text format endpoints or files, a targets file lists one target per line.
+Series are mapped to metrics with a mapping file, histograms and summaries are converted
+to percentiles and rates are derived from counts between scrapes.
+Histogram percentiles describe the observations since the previous scrape, summary quantiles
+are computed by the application and are pushed as they are exposed.
+
+Mapping file example:
+    mappings:
+    - series: http_request_duration_seconds
+      metric: latency
+      matchLabels:
+        method: GET
+      resolveSubset: true
+      scale: 1000
+    - series: http_requests_total
+      metric: requests
+      destination: dest-1
+      subsetLabel: version
+
+resolveSubset resolves destination, subset and port from series labels with the subset map,
+otherwise they are fixed values or taken from destinationLabel, subsetLabel and portLabel.
+Series get an instance label with their target, series of several targets or series with
+different labels that map to the same metric are merged into one value.
+
+Example:
+    $AppName metrics bridge prometheus --target http://shop:8080/metrics --mapping mapping.yaml --interval 15s
+    $AppName metrics bridge prometheus --target metrics.txt --mapping mapping.yaml --once`),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		targets := append([]string{}, scrapeTargets...)
+		if scrapeTargetsFile != "" {
+			fileTargets, err := readTargets(scrapeTargetsFile)
+			if err != nil {
+				return err
+			}
+			targets = append(targets, fileTargets...)
+		}
+		if len(targets) == 0 {
+			return errors.New("At least one target is required")
+		}
+		if mappingFile == "" {
+			return errors.New("Mapping file is required")
+		}
+		source, readError := ioutil.ReadFile(mappingFile)
+		if readError != nil {
+			return readError
+		}
+		mappingConfig, parseError := prometheus.ParseMappingConfig(source)
+		if parseError != nil {
+			return parseError
+		}
+		if pushInterval <= 0 {
+			return errors.New("Interval should be positive")
+		}
+		restClient := client.NewRestClient(Config.Url, Config.Token, Config.APIVersion, logging.Verbose, Config.Cert, &TokenStore)
+		if restClient == nil {
+			return errors.New("Client can not be created, check your configuration")
+		}
+		resolveSubsets := false
+		for _, mapping := range mappingConfig.Mappings {
+			resolveSubsets = resolveSubsets || mapping.ResolveSubset
+		}
+		bridge := prometheus.NewBridge(mappingConfig.Mappings)
+		httpClient := &http.Client{Timeout: scrapeTimeout}
+
+		bridgeTargets := func() {
+			var subsetMap *models.DestinationsSubsetsMap
+			if resolveSubsets {
+				values := make(map[string]string)
+				values["project"] = Config.Project
+				values["cluster"] = Config.Cluster
+				values["virtual_cluster"] = Config.VirtualCluster
+				var err error
+				subsetMap, err = restClient.GetSubsetMap(values)
+				if err != nil {
+					fmt.Printf("Warning: subset map can not be retrieved - %v\n", err)
+				}
+			}
+			families := []*prometheus.MetricFamily{}
+			for _, target := range targets {
+				scraped, err := scrape(httpClient, target)
+				if err != nil {
+					fmt.Printf("Warning: %v can not be scraped - %v\n", target, err)
+					continue
+				}
+				families = append(families, prometheus.WithInstance(scraped, target)...)
+			}
+			mapped, errs := bridge.Convert(families, subsetMap, time.Now().UnixNano()/int64(time.Millisecond))
+			for _, err := range errs {
+				fmt.Printf("Warning: %v\n", err)
+			}
+			metricValues := make(map[metricKey]models.MetricValue)
+			for _, m := range mapped {
+				metricValues[metricKey{Metric: m.Target.Metric, Destination: m.Target.Destination, Port: m.Target.Port, Subset: m.Target.Subset}] = m.Value
+			}
+			pushMetricValues(restClient, metricValues)
+		}
+
+		bridgeTargets()
+		if bridgeOnce {
+			return nil
+		}
+		ticker := time.NewTicker(pushInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			bridgeTargets()
+		}
+		return nil
+	},
+}
+
+// scrape reads metric families from an http endpoint or a file
+func scrape(httpClient *http.Client, target string) ([]*prometheus.MetricFamily, error) {
+	if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+		file, err := os.Open(target)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		return prometheus.Parse(file)
+	}
+	request, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", "text/plain;version=0.0.4")
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %v", response.Status)
+	}
+	return prometheus.Parse(response.Body)
+}
+
+// readTargets reads one target per line, empty lines and comments are skipped
+func readTargets(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	targets := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			targets = append(targets, line)
+		}
+	}
+	return targets, scanner.Err()
+}
+
+func init() {
+	metricsCmd.AddCommand(metricsBridgeCmd)
+	metricsBridgeCmd.AddCommand(metricsBridgePrometheusCmd)
+
+	metricsBridgePrometheusCmd.Flags().StringSliceVarP(&scrapeTargets, "target", "", []string{}, "Prometheus endpoint url or file, can be repeated")
+	metricsBridgePrometheusCmd.Flags().StringVarP(&scrapeTargetsFile, "targets-file", "", "", "File that lists one target per line")
+	metricsBridgePrometheusCmd.Flags().StringVarP(&mappingFile, "mapping", "m", "", "Mapping file in yaml or json")
+	metricsBridgePrometheusCmd.Flags().DurationVarP(&pushInterval, "interval", "", 15*time.Second, "Interval to scrape and push metrics")
+	metricsBridgePrometheusCmd.Flags().DurationVarP(&scrapeTimeout, "timeout", "", 10*time.Second, "Timeout of a scrape")
+	metricsBridgePrometheusCmd.Flags().BoolVarP(&bridgeOnce, "once", "", false, "Scrape and push once and exit")
+}
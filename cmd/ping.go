@@ -16,9 +16,12 @@ package cmd
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/magneticio/vampkubistcli/client"
+	"github.com/magneticio/vampkubistcli/kubernetes"
 	"github.com/magneticio/vampkubistcli/logging"
+	"github.com/magneticio/vampkubistcli/util"
 	"github.com/spf13/cobra"
 )
 
@@ -30,6 +33,8 @@ var pingCmd = &cobra.Command{
 	SilenceUsage:  true,
 	SilenceErrors: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		// an expired certificate fails the ping so expiry is reported first
+		warnCertExpiry(Config.Cert)
 		restClient := client.NewRestClient(Config.Url, Config.Token, Config.APIVersion, logging.Verbose, Config.Cert, &TokenStore)
 		isPong, err := restClient.Ping()
 		if !isPong {
@@ -40,6 +45,29 @@ var pingCmd = &cobra.Command{
 	},
 }
 
+func warnCertExpiry(cert string) {
+	if cert == "" {
+		return
+	}
+	expiry, err := util.CertificateExpiry(cert)
+	if err != nil {
+		fmt.Printf("Warning: certificate can not be read - %v\n", err)
+		return
+	}
+	advice := fmt.Sprintf("rotate it with %v install rotate-certs", AppName)
+	if !kubeclient.IsGeneratedCertificate([]byte(cert)) {
+		// certificates that are brought along or issued by cert-manager can't be rotated by installation
+		advice = "renew it where it is issued and login with the renewed certificate"
+	}
+	remaining := time.Until(expiry)
+	if remaining <= 0 {
+		fmt.Printf("Warning: certificate expired at %v, %v\n", expiry.Format(time.RFC3339), advice)
+	} else if remaining < kubeclient.CertExpiryWarningPeriod {
+		fmt.Printf("Warning: certificate expires in %v days at %v, %v\n",
+			int(remaining.Hours()/24), expiry.Format(time.RFC3339), advice)
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(pingCmd)
 }
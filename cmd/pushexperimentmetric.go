@@ -0,0 +1,241 @@
+// Copyright © 2019 Developer <developer@vamp.io>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/magneticio/vampkubistcli/client"
+	"github.com/magneticio/vampkubistcli/logging"
+	"github.com/magneticio/vampkubistcli/models"
+	"github.com/magneticio/vampkubistcli/stats"
+	"github.com/spf13/cobra"
+)
+
+var observationsFormat string
+var aggregationWindow time.Duration
+var metricAverage float64
+var metricStandardDeviation float64
+var metricCount int64
+
+// observation is a raw value, timestamp is in milliseconds and 0 if it is not given
+type observation struct {
+	Timestamp int64   `json:"timestamp"`
+	Value     float64 `json:"value"`
+}
+
+var pushExperimentMetricCmd = &cobra.Command{
+	Use:   "experiment-metric",
+	Short: "Push a metric of an experiment",
+	Long: AddAppName(`Push a metric of an experiment
+A single aggregated value can be pushed with average, stddev and count flags.
+Otherwise raw observations are read from the file or standard input,
+aggregated into count, average and standard deviation over time windows
+and every completed window is pushed.
+
+Observations are either csv lines as value or timestamp,value
+or json lines as {"timestamp": 1560000000000, "value": 1.5}.
+Timestamps are unix milliseconds, arrival time is used if it is omitted.
+
+Example:
+    $AppName push experiment-metric myexperiment conversion --destination dest-1 --subset subset1 --average 0.12 --stddev 0.32 --count 1000
+    $AppName push experiment-metric myexperiment latency --destination dest-1 --subset subset1 -f observations.csv
+    tail -f observations.jsonl | $AppName push experiment-metric myexperiment latency --destination dest-1 --subset subset1 --format jsonl --window 30s`),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 2 {
+			return errors.New("Not Enough Arguments")
+		}
+		experimentName := args[0]
+		metricName := args[1]
+		if observationsFormat != "csv" && observationsFormat != "jsonl" {
+			return fmt.Errorf("Observations format %v is not supported, use csv or jsonl", observationsFormat)
+		}
+		if aggregationWindow < time.Millisecond {
+			return errors.New("Window should be at least 1ms")
+		}
+		restClient := client.NewRestClient(Config.Url, Config.Token, Config.APIVersion, logging.Verbose, Config.Cert, &TokenStore)
+		values := make(map[string]string)
+		values["project"] = Config.Project
+		values["cluster"] = Config.Cluster
+		values["virtual_cluster"] = Config.VirtualCluster
+		values["destination"] = Destination
+		values["port"] = Port
+		values["subset"] = Subset
+		send := func(experimentMetric *models.ExperimentMetric) error {
+			if err := restClient.SendExperimentMetric(experimentName, metricName, experimentMetric, values); err != nil {
+				return err
+			}
+			fmt.Printf("experiment metric %v is pushed with %v elements, average %v, standard deviation %v\n",
+				metricName, experimentMetric.NumberOfElements, experimentMetric.Average, experimentMetric.StandardDeviation)
+			return nil
+		}
+
+		if cmd.Flags().Changed("average") {
+			return send(&models.ExperimentMetric{
+				Timestamp:         time.Now().UnixNano() / int64(time.Millisecond),
+				NumberOfElements:  metricCount,
+				StandardDeviation: metricStandardDeviation,
+				Average:           metricAverage,
+			})
+		}
+
+		var reader io.Reader = os.Stdin
+		if SourceFile != "" && SourceFile != "-" {
+			file, openError := os.Open(SourceFile)
+			if openError != nil {
+				return openError
+			}
+			defer file.Close()
+			reader = file
+		}
+		return aggregateObservations(reader, observationsFormat, aggregationWindow, send)
+	},
+}
+
+/*
+aggregateObservations reads observations line by line and sends a metric for every completed window.
+A window is completed when an observation of a later window arrives,
+for observations without a timestamp also when the window has passed.
+Remaining windows are sent at the end of the input.
+*/
+func aggregateObservations(reader io.Reader, format string, window time.Duration, send func(*models.ExperimentMetric) error) error {
+	size := int64(window / time.Millisecond)
+	windows := stats.NewWindows(size)
+	nowMillis := func() int64 {
+		return time.Now().UnixNano() / int64(time.Millisecond)
+	}
+	flush := func(completed []stats.Window) error {
+		for _, w := range completed {
+			experimentMetric := w.Accumulator.ExperimentMetric(w.End)
+			if err := send(&experimentMetric); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	observations := make(chan observation)
+	readErrors := make(chan error, 1)
+	go func() {
+		defer close(observations)
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			o, parseError := parseObservation(line, format)
+			if parseError != nil {
+				fmt.Printf("Warning: skipping line %q - %v\n", line, parseError)
+				continue
+			}
+			observations <- *o
+		}
+		readErrors <- scanner.Err()
+	}()
+
+	ticker := time.NewTicker(window)
+	defer ticker.Stop()
+	arrivalTime := false
+	latest := int64(0)
+	for {
+		select {
+		case o, ok := <-observations:
+			if !ok {
+				if err := flush(windows.FlushAll()); err != nil {
+					return err
+				}
+				return <-readErrors
+			}
+			if o.Timestamp == 0 {
+				o.Timestamp = nowMillis()
+				arrivalTime = true
+			}
+			windows.Add(o.Timestamp, o.Value)
+			if o.Timestamp > latest {
+				latest = o.Timestamp
+			}
+			if err := flush(windows.Flush(latest - latest%size)); err != nil {
+				return err
+			}
+		case <-ticker.C:
+			if arrivalTime {
+				if err := flush(windows.Flush(nowMillis())); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+func parseObservation(line string, format string) (*observation, error) {
+	if format == "jsonl" {
+		var o observation
+		if err := json.Unmarshal([]byte(line), &o); err != nil {
+			return nil, err
+		}
+		return &o, nil
+	}
+	fields := strings.Split(line, ",")
+	switch len(fields) {
+	case 1:
+		value, err := strconv.ParseFloat(strings.TrimSpace(fields[0]), 64)
+		if err != nil {
+			return nil, err
+		}
+		return &observation{Value: value}, nil
+	case 2:
+		timestamp, err := strconv.ParseInt(strings.TrimSpace(fields[0]), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+		if err != nil {
+			return nil, err
+		}
+		return &observation{Timestamp: timestamp, Value: value}, nil
+	}
+	return nil, errors.New("csv line should be value or timestamp,value")
+}
+
+func init() {
+	pushCmd.AddCommand(pushExperimentMetricCmd)
+
+	pushExperimentMetricCmd.Flags().StringVarP(&SourceFile, "file", "f", "", "Observations file, standard input is used if it is empty or -")
+	pushExperimentMetricCmd.Flags().StringVarP(&observationsFormat, "format", "", "csv", "Observations format csv or jsonl")
+	pushExperimentMetricCmd.Flags().DurationVarP(&aggregationWindow, "window", "", time.Minute, "Time window to aggregate observations")
+	pushExperimentMetricCmd.Flags().Float64VarP(&metricAverage, "average", "", 0, "Average of a single aggregated value")
+	pushExperimentMetricCmd.Flags().Float64VarP(&metricStandardDeviation, "stddev", "", 0, "Standard deviation of a single aggregated value")
+	pushExperimentMetricCmd.Flags().Int64VarP(&metricCount, "count", "", 1, "Number of elements of a single aggregated value")
+	pushExperimentMetricCmd.Flags().StringVarP(&Destination, "destination", "", "", "destination name for metrics")
+	pushExperimentMetricCmd.Flags().StringVarP(&Port, "port", "", "", "port number for metrics")
+	pushExperimentMetricCmd.Flags().StringVarP(&Subset, "subset", "", "", "subset name for metrics")
+}
@@ -0,0 +1,155 @@
+// Copyright © 2019 Developer developer@vamp.io
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/magneticio/vampkubistcli/client"
+	"github.com/magneticio/vampkubistcli/logging"
+	"github.com/magneticio/vampkubistcli/models"
+	"github.com/magneticio/vampkubistcli/util"
+	"github.com/spf13/cobra"
+)
+
+var resolveLabels map[string]string
+var subsetsOutputType string
+
+// subsetsCmd represents the subsets command
+var subsetsCmd = &cobra.Command{
+	Use:   "subsets",
+	Short: "Show how labels are mapped to destination subsets",
+	Long: AddAppName(`Show how labels are mapped to destination subsets
+    Example:
+    $AppName subsets list
+    $AppName subsets resolve --labels version=v2,app=shop`),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return errors.New("a sub command expected, use list or resolve")
+	},
+}
+
+var subsetsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all destination subsets with their labels and ports",
+	Long: AddAppName(`List all destination subsets with their labels and ports
+
+Example:
+    $AppName subsets list
+    $AppName subsets list -o yaml`),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		subsetMap, err := getSubsetMap()
+		if err != nil {
+			return err
+		}
+		return printSubsets(client.ListSubsets(subsetMap), subsetsOutputType)
+	},
+}
+
+var subsetsResolveCmd = &cobra.Command{
+	Use:   "resolve",
+	Short: "Resolve labels to destination, subset and ports",
+	Long: AddAppName(`Resolve labels to destination, subset and ports
+A subset matches if all of its labels exist in the given labels.
+
+Example:
+    $AppName subsets resolve --labels version=v2,app=shop
+    $AppName subsets resolve -l version=v2 -l app=shop -o json`),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(resolveLabels) == 0 {
+			return errors.New("Labels should be provided with labels flag")
+		}
+		subsetMap, err := getSubsetMap()
+		if err != nil {
+			return err
+		}
+		resolutions := client.ResolveSubsets(subsetMap, resolveLabels)
+		if len(resolutions) == 0 {
+			return errors.New("Labels are not mapped to any subset")
+		}
+		return printSubsets(resolutions, subsetsOutputType)
+	},
+}
+
+func getSubsetMap() (*models.DestinationsSubsetsMap, error) {
+	restClient := client.NewRestClient(Config.Url, Config.Token, Config.APIVersion, logging.Verbose, Config.Cert, &TokenStore)
+	values := make(map[string]string)
+	values["project"] = Config.Project
+	values["cluster"] = Config.Cluster
+	values["virtual_cluster"] = Config.VirtualCluster
+	return restClient.GetSubsetMap(values)
+}
+
+func printSubsets(resolutions []client.SubsetResolution, outputType string) error {
+	if outputType == "yaml" || outputType == "json" {
+		SourceRaw, marshalError := json.Marshal(resolutions)
+		if marshalError != nil {
+			return marshalError
+		}
+		result, convertError := util.Convert("json", outputType, string(SourceRaw))
+		if convertError != nil {
+			return convertError
+		}
+		fmt.Printf("%v", result)
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DESTINATION\tSUBSET\tLABELS\tPORTS")
+	for _, resolution := range resolutions {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", resolution.Destination, resolution.Subset, formatLabels(resolution.Labels), formatPorts(resolution.Ports))
+	}
+	return w.Flush()
+}
+
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for name, value := range labels {
+		pairs = append(pairs, name+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func formatPorts(ports []models.DestinationPortSpecification) string {
+	formatted := make([]string, len(ports))
+	for i, port := range ports {
+		formatted[i] = strconv.Itoa(port.Port)
+		if port.Protocol != "" {
+			formatted[i] += "/" + port.Protocol
+		}
+	}
+	return strings.Join(formatted, ",")
+}
+
+func init() {
+	rootCmd.AddCommand(subsetsCmd)
+	subsetsCmd.AddCommand(subsetsListCmd)
+	subsetsCmd.AddCommand(subsetsResolveCmd)
+
+	subsetsCmd.PersistentFlags().StringVarP(&subsetsOutputType, "output", "o", "table", "Output format table, yaml or json")
+	subsetsResolveCmd.Flags().StringToStringVarP(&resolveLabels, "labels", "l", map[string]string{}, "Labels to resolve, multiple labels are allowed")
+}
@@ -0,0 +1,161 @@
+// Copyright © 2019 Developer developer@vamp.io
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/magneticio/vampkubistcli/client"
+	"github.com/magneticio/vampkubistcli/logging"
+	"github.com/magneticio/vampkubistcli/models"
+	"github.com/spf13/cobra"
+)
+
+var routeIndex int
+var shiftTo string
+var shiftBy int64
+
+// trafficCmd represents the traffic command
+var trafficCmd = &cobra.Command{
+	Use:   "traffic",
+	Short: "Change traffic weights of a vamp service",
+	Long: AddAppName(`Change traffic weights of a vamp service
+    Example:
+    $AppName traffic set shop-vamp-service --route 0 subset1=80 subset2=20
+    $AppName traffic shift shop-vamp-service --to subset2 --by 10`),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return errors.New("a sub command expected, use set or shift")
+	},
+}
+
+var trafficSetCmd = &cobra.Command{
+	Use:   "set",
+	Short: "Set traffic weights of subsets in a route",
+	Long: AddAppName(`Set traffic weights of subsets in a route
+Weights that are not given are not changed, all weights should sum up to 100.
+If a subset is used in multiple destinations, destination/subset can be used.
+
+Example:
+    $AppName traffic set shop-vamp-service --route 0 subset1=80 subset2=20
+    $AppName traffic set shop-vamp-service shop-destination/subset1=100 shop-destination/subset2=0`),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 2 {
+			return errors.New("Not Enough Arguments")
+		}
+		Name = args[0]
+		weights := make(map[string]int64)
+		for _, arg := range args[1:] {
+			pair := strings.SplitN(arg, "=", 2)
+			if len(pair) != 2 {
+				return fmt.Errorf("Weight %v should be in subset=weight format", arg)
+			}
+			weight, parseError := strconv.ParseInt(pair[1], 10, 64)
+			if parseError != nil {
+				return fmt.Errorf("Weight %v is not a number", pair[1])
+			}
+			weights[pair[0]] = weight
+		}
+		return updateTraffic(Name, func(vampService *models.VampService, subsetMap *models.DestinationsSubsetsMap) error {
+			return client.SetRouteWeights(vampService, routeIndex, weights, subsetMap)
+		})
+	},
+}
+
+var trafficShiftCmd = &cobra.Command{
+	Use:   "shift",
+	Short: "Shift traffic to a subset in a route",
+	Long: AddAppName(`Shift traffic to a subset in a route
+Traffic is taken from the other subsets starting with the highest weight.
+
+Example:
+    $AppName traffic shift shop-vamp-service --to subset2 --by 10
+    $AppName traffic shift shop-vamp-service --route 1 --to subset2 --by 10`),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return errors.New("Not Enough Arguments")
+		}
+		Name = args[0]
+		if shiftTo == "" {
+			return errors.New("Target subset should be provided with to flag")
+		}
+		return updateTraffic(Name, func(vampService *models.VampService, subsetMap *models.DestinationsSubsetsMap) error {
+			return client.ShiftRouteWeight(vampService, routeIndex, shiftTo, shiftBy, subsetMap)
+		})
+	},
+}
+
+/*
+updateTraffic reads the vamp service, applies the given change to its weights
+and updates the vamp service if weights are valid
+*/
+func updateTraffic(name string, change func(*models.VampService, *models.DestinationsSubsetsMap) error) error {
+	Type := "vamp_service"
+	restClient := client.NewRestClient(Config.Url, Config.Token, Config.APIVersion, logging.Verbose, Config.Cert, &TokenStore)
+	values := make(map[string]string)
+	values["project"] = Config.Project
+	values["cluster"] = Config.Cluster
+	values["virtual_cluster"] = Config.VirtualCluster
+	values["application"] = Application
+	spec, getSpecError := restClient.GetSpec(Type, name, "json", values)
+	if getSpecError != nil {
+		return getSpecError
+	}
+	var vampService models.VampService
+	if unmarshalError := json.Unmarshal([]byte(spec), &vampService); unmarshalError != nil {
+		return unmarshalError
+	}
+	subsetMap, subsetMapError := restClient.GetSubsetMap(values)
+	if subsetMapError != nil {
+		return subsetMapError
+	}
+	if changeError := change(&vampService, subsetMap); changeError != nil {
+		return changeError
+	}
+	SourceRaw, marshalError := json.Marshal(vampService)
+	if marshalError != nil {
+		return marshalError
+	}
+	isUpdated, updateError := restClient.Update(Type, name, string(SourceRaw), "json", values)
+	if !isUpdated {
+		return updateError
+	}
+	for i, route := range vampService.Routes {
+		for _, weight := range route.Weights {
+			fmt.Printf("route %v: %v:%v %v %v\n", i, weight.Destination, weight.Port, weight.Version, weight.Weight)
+		}
+	}
+	fmt.Println(Type + " " + name + " is updated")
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(trafficCmd)
+	trafficCmd.AddCommand(trafficSetCmd)
+	trafficCmd.AddCommand(trafficShiftCmd)
+
+	trafficCmd.PersistentFlags().IntVarP(&routeIndex, "route", "", 0, "Index of the route in the vamp service")
+	trafficShiftCmd.Flags().StringVarP(&shiftTo, "to", "", "", "Subset to shift traffic to, destination/subset is also accepted")
+	trafficShiftCmd.Flags().Int64VarP(&shiftBy, "by", "", 10, "Percentage of traffic to shift")
+}
@@ -15,20 +15,39 @@
 package cmd
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/magneticio/vampkubistcli/kubernetes"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 )
 
+var uninstallOptions kubeclient.UninstallOptions
+var uninstallConfirmed bool
+
 // uninstallCmd represents the install command
 var uninstallCmd = &cobra.Command{
 	Use:   "uninstall",
 	Short: "Uninstall Vamp Management in your cluster",
-	Long: AddAppName(`
+	Long: AddAppName(`Uninstall Vamp Management in your cluster
+The namespace of the installation and its cluster role binding are deleted, including the volumes of the database.
+Uninstall lists what is removed and asks for confirmation, dry run only lists it.
+Keep data deletes the objects of the release but keeps the namespace with the volumes of the database
+and the certificate secrets. If other releases are installed in the namespace, only the objects
+and the data of the release are deleted. Backup exports the objects and secrets as manifests to a directory before they are deleted.
+Uninstall continues if objects can not be listed or deleted and reports every failure at the end.
+
 Example:
 $AppName uninstall --kubeconfig kube-config.yaml
+$AppName uninstall --namespace vamp-staging
+$AppName uninstall --dry-run
+$AppName uninstall --keep-data --release-name staging --namespace vamp-staging
+$AppName uninstall --backup-to vamp-backup/ --yes
   `),
 	SilenceUsage:  true,
 	SilenceErrors: true,
@@ -36,7 +55,24 @@ $AppName uninstall --kubeconfig kube-config.yaml
 		if kubeConfigPath == "" {
 			kubeConfigPath = viper.GetString("kubeconfig")
 		}
-		if err := kubeclient.UninstallVampService(kubeConfigPath); err != nil {
+		uninstallOptions.ReleaseName = releaseName
+		clientset, err := kubeclient.K8sClient.Get(kubeConfigPath)
+		if err != nil {
+			return err
+		}
+		// objects that can not be listed are reported with the failed deletions
+		plan, planErr := kubeclient.PlanUninstall(clientset, namespace, uninstallOptions)
+		printUninstallPlan(plan)
+		if planErr != nil {
+			fmt.Printf("Warning: %v\n", planErr)
+		}
+		if uninstallOptions.DryRun {
+			return nil
+		}
+		if !uninstallConfirmed && !confirm("Do you want to uninstall Vamp?") {
+			return errors.New("Uninstall is cancelled")
+		}
+		if err := utilerrors.NewAggregate([]error{planErr, kubeclient.Uninstall(clientset, plan, uninstallOptions)}); err != nil {
 			return err
 		}
 		fmt.Printf("Vamp Service Uninstalled.\n")
@@ -44,8 +80,38 @@ $AppName uninstall --kubeconfig kube-config.yaml
 	},
 }
 
+func printUninstallPlan(plan *kubeclient.UninstallPlan) {
+	fmt.Printf("Namespace %v\n", plan.Namespace)
+	for _, object := range plan.Delete {
+		fmt.Printf("delete %v\n", kubeclient.ObjectName(object))
+	}
+	for _, object := range plan.InDeleted {
+		fmt.Printf("delete %v with the namespace\n", kubeclient.ObjectName(object))
+	}
+	for _, object := range plan.Keep {
+		fmt.Printf("keep   %v\n", kubeclient.ObjectName(object))
+	}
+}
+
+// confirm asks a yes or no question on the terminal, no is the default
+func confirm(question string) bool {
+	fmt.Printf("%v [y/N]: ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
 func init() {
 	rootCmd.AddCommand(uninstallCmd)
 	uninstallCmd.Flags().StringVarP(&kubeConfigPath, "kubeconfig", "", "", "Kube Config path")
+	uninstallCmd.Flags().StringVarP(&namespace, "namespace", "", kubeclient.InstallationNamespace, "Namespace of the installation")
+	uninstallCmd.Flags().StringVarP(&releaseName, "release-name", "", "", "Release name of the installation, the default release is used if it is empty")
+	uninstallCmd.Flags().BoolVarP(&uninstallOptions.DryRun, "dry-run", "", false, "List what would be removed without deleting anything")
+	uninstallCmd.Flags().BoolVarP(&uninstallOptions.KeepData, "keep-data", "", false, "Keep the namespace with the database volumes and certificate secrets")
+	uninstallCmd.Flags().StringVarP(&uninstallOptions.BackupDir, "backup-to", "", "", "Directory to export the objects and secrets to before they are deleted")
+	uninstallCmd.Flags().BoolVarP(&uninstallConfirmed, "yes", "y", false, "Uninstall without confirmation")
 	viper.BindEnv("kubeconfig", "KUBECONFIG")
 }
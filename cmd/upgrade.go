@@ -0,0 +1,66 @@
+// Copyright © 2019 Developer developer@vamp.io
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/magneticio/vampkubistcli/kubernetes"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var upgradeOptions = kubeclient.UpgradeOptions{BackendVersion: BackendVersion}
+
+var upgradeCmd = &cobra.Command{
+	Use:   "upgrade",
+	Short: "Upgrade the Vamp installation to another image tag",
+	Long: AddAppName(`Upgrade the Vamp installation to another image tag
+The image tag should be compatible with the backend version of this client and newer than the current one,
+force skips these checks. The deployment, autoscaler and secrets are kept before the rolling update,
+if the new version isn't ready and doesn't answer within the timeout they are rolled back.
+
+Example:
+    $AppName upgrade --image-tag 0.8.17
+    $AppName upgrade --image-tag 0.8.17 --namespace vamp-staging --release-name staging --timeout 15m`),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if upgradeOptions.ImageTag == "" {
+			return errors.New("Image tag is required")
+		}
+		if kubeConfigPath == "" {
+			kubeConfigPath = viper.GetString("kubeconfig")
+		}
+		url, err := kubeclient.UpgradeVamp(kubeConfigPath, namespace, releaseName, upgradeOptions)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Vamp at %v is upgraded to %v.\n", url, upgradeOptions.ImageTag)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(upgradeCmd)
+
+	upgradeCmd.Flags().StringVarP(&upgradeOptions.ImageTag, "image-tag", "", "", "Image tag to upgrade to")
+	upgradeCmd.Flags().BoolVarP(&upgradeOptions.Force, "force", "", false, "Skip compatibility checks of the image tag")
+	upgradeCmd.Flags().DurationVarP(&upgradeOptions.Timeout, "timeout", "", kubeclient.DefaultUpgradeTimeout, "Time to wait for the new version before it is rolled back")
+	upgradeCmd.Flags().StringVarP(&kubeConfigPath, "kubeconfig", "", "", "Kube Config path")
+	upgradeCmd.Flags().StringVarP(&namespace, "namespace", "", kubeclient.InstallationNamespace, "Namespace of the installation")
+	upgradeCmd.Flags().StringVarP(&releaseName, "release-name", "", "", "Release name of the installation, the default release is used if it is empty")
+}
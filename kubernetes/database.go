@@ -0,0 +1,199 @@
+package kubeclient
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// DefaultDatabaseJobTimeout is how long a backup or restore job waits for its pod to start and for completion
+const DefaultDatabaseJobTimeout = 5 * time.Minute
+
+// databaseJobContainer is the name of the container of backup and restore jobs
+const databaseJobContainer = "mongo"
+
+/*
+DatabaseJob returns a job that runs the command with the database url and name of the vamp deployment.
+The container reads stdin so the archive can be streamed by attaching to it, it is started with
+the image of the internal MongoDB.
+*/
+func DatabaseJob(clientset kubernetes.Interface, ns string, releaseName string, name string, command string) (*batchv1.Job, error) {
+	names := NamesOf(releaseName)
+	deployment, err := clientset.AppsV1().Deployments(ns).Get(names.Release, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	env := []corev1.EnvVar{}
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		for _, e := range container.Env {
+			if e.Name == "DBURL" || e.Name == "DBNAME" {
+				env = append(env, e)
+			}
+		}
+	}
+	if len(env) != 2 {
+		return nil, fmt.Errorf("Deployment %v doesn't have a database url and name", names.Release)
+	}
+	image := DefaultMongoConfig.Image
+	if statefulSet, err := clientset.AppsV1().StatefulSets(ns).Get(names.MongoStatefulSet, metav1.GetOptions{}); err == nil {
+		image = statefulSet.Spec.Template.Spec.Containers[0].Image
+	}
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   names.Release + "-" + name,
+			Labels: map[string]string{"app": names.Release + "-" + name},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: int32Ptr(0),
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"app": names.Release + "-" + name},
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers: []corev1.Container{
+						{
+							Name:      databaseJobContainer,
+							Image:     image,
+							Command:   []string{"sh", "-c", command},
+							Env:       env,
+							Stdin:     true,
+							StdinOnce: true,
+						},
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+// BackupDatabase dumps the database of the release as a gzipped archive to out
+func BackupDatabase(configPath string, ns string, releaseName string, out io.Writer) error {
+	// the dump starts after a line is read so output isn't written before it is attached
+	return runDatabaseJob(configPath, ns, releaseName, "db-backup",
+		`read -r start && mongodump --uri="$DBURL" --db="$DBNAME" --archive --gzip`,
+		strings.NewReader("start\n"), out)
+}
+
+// RestoreDatabase restores a gzipped archive of BackupDatabase from in, collections that exist are dropped first
+func RestoreDatabase(configPath string, ns string, releaseName string, in io.Reader) error {
+	return runDatabaseJob(configPath, ns, releaseName, "db-restore",
+		`mongorestore --uri="$DBURL" --archive --gzip --drop`,
+		in, nil)
+}
+
+/*
+runDatabaseJob runs a database job and attaches to its pod to stream stdin and stdout.
+The job is deleted when it is completed or it fails.
+*/
+func runDatabaseJob(configPath string, ns string, releaseName string, name string, command string, stdin io.Reader, stdout io.Writer) error {
+	config, err := getLocalKubeConfig(configPath)
+	if err != nil {
+		return err
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+	job, err := DatabaseJob(clientset, ns, releaseName, name, command)
+	if err != nil {
+		return err
+	}
+	jobs := clientset.BatchV1().Jobs(ns)
+	if _, err := jobs.Get(job.Name, metav1.GetOptions{}); err == nil {
+		return fmt.Errorf("Job %v exists, it should complete or be deleted first", job.Name)
+	}
+	if _, err := jobs.Create(job); err != nil {
+		return err
+	}
+	propagation := metav1.DeletePropagationBackground
+	defer func() {
+		if err := jobs.Delete(job.Name, &metav1.DeleteOptions{PropagationPolicy: &propagation}); err != nil && !k8serrors.IsNotFound(err) {
+			fmt.Printf("Warning: job %v can not be deleted - %v\n", job.Name, err)
+		}
+	}()
+
+	podName, err := waitForJobPod(clientset, ns, job.Name, DefaultDatabaseJobTimeout)
+	if err != nil {
+		return err
+	}
+	if err := attachToPod(config, clientset, ns, podName, stdin, stdout); err != nil {
+		return err
+	}
+	return waitForJobCompletion(clientset, ns, job.Name, DefaultDatabaseJobTimeout)
+}
+
+// waitForJobPod waits until the pod of a job is running and returns its name
+func waitForJobPod(clientset kubernetes.Interface, ns string, jobName string, timeout time.Duration) (string, error) {
+	podName := ""
+	operation := func() error {
+		pods, err := clientset.CoreV1().Pods(ns).List(metav1.ListOptions{LabelSelector: "job-name=" + jobName})
+		if err != nil {
+			return err
+		}
+		for _, pod := range pods.Items {
+			switch pod.Status.Phase {
+			case corev1.PodRunning:
+				podName = pod.Name
+				return nil
+			case corev1.PodFailed, corev1.PodSucceeded:
+				return backoff.Permanent(fmt.Errorf("Pod %v of job %v is %v before it is attached", pod.Name, jobName, pod.Status.Phase))
+			}
+		}
+		fmt.Printf("Waiting for pod of job %v\n", jobName)
+		return errors.New("Pod is not running yet")
+	}
+	err := backoff.Retry(operation, timeoutBackOff(timeout))
+	return podName, err
+}
+
+// waitForJobCompletion waits until a job succeeds, it returns an error if the job fails
+func waitForJobCompletion(clientset kubernetes.Interface, ns string, jobName string, timeout time.Duration) error {
+	operation := func() error {
+		job, err := clientset.BatchV1().Jobs(ns).Get(jobName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if job.Status.Failed > 0 {
+			return backoff.Permanent(fmt.Errorf("Job %v failed", jobName))
+		}
+		if job.Status.Succeeded == 0 {
+			return errors.New("Job is not completed yet")
+		}
+		return nil
+	}
+	return backoff.Retry(operation, timeoutBackOff(timeout))
+}
+
+// attachToPod streams stdin to the database job container and its output to stdout, logs are written to stderr
+func attachToPod(config *rest.Config, clientset kubernetes.Interface, ns string, podName string, stdin io.Reader, stdout io.Writer) error {
+	req := clientset.CoreV1().RESTClient().Post().Resource("pods").Namespace(ns).Name(podName).SubResource("attach").
+		VersionedParams(&corev1.PodAttachOptions{
+			Container: databaseJobContainer,
+			Stdin:     stdin != nil,
+			Stdout:    stdout != nil,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+	executor, err := remotecommand.NewSPDYExecutor(config, "POST", req.URL())
+	if err != nil {
+		return err
+	}
+	return executor.Stream(remotecommand.StreamOptions{
+		Stdin:  stdin,
+		Stdout: stdout,
+		Stderr: os.Stderr,
+	})
+}
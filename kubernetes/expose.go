@@ -0,0 +1,270 @@
+package kubeclient
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/magneticio/vampkubistcli/models"
+	corev1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// Types of the vamp service
+const (
+	ServiceTypeLoadBalancer = "LoadBalancer"
+	ServiceTypeNodePort     = "NodePort"
+	ServiceTypeClusterIP    = "ClusterIP"
+)
+
+// VampPort is the port of the vamp API
+const VampPort = 8888
+
+// IngressPort is the port of https on ingress controllers
+const IngressPort = 443
+
+// Endpoint is the address that clients use to connect to the vamp API
+type Endpoint struct {
+	Host string
+	Port int32
+}
+
+// Address is the host and port of the endpoint
+func (endpoint Endpoint) Address() string {
+	return net.JoinHostPort(endpoint.Host, strconv.Itoa(int(endpoint.Port)))
+}
+
+// URL is the url of the vamp API at the endpoint
+func (endpoint Endpoint) URL() string {
+	return "https://" + endpoint.Address()
+}
+
+// serviceType returns the type of the vamp service, services are load balancers if it is not configured
+func serviceType(config *models.VampConfig) string {
+	if config.ServiceType == "" {
+		return ServiceTypeLoadBalancer
+	}
+	return config.ServiceType
+}
+
+// IsClusterInternal is true if vamp can only be reached from the cluster or through port forwarding
+func IsClusterInternal(config *models.VampConfig) bool {
+	return serviceType(config) == ServiceTypeClusterIP && config.Ingress == nil
+}
+
+// ValidateExposure checks the service type and the ingress of the config
+func ValidateExposure(config *models.VampConfig) error {
+	switch serviceType(config) {
+	case ServiceTypeLoadBalancer, ServiceTypeNodePort, ServiceTypeClusterIP:
+	default:
+		return fmt.Errorf("Service type %v is not supported, use %v, %v or %v",
+			config.ServiceType, ServiceTypeLoadBalancer, ServiceTypeNodePort, ServiceTypeClusterIP)
+	}
+	if config.Ingress == nil {
+		return nil
+	}
+	if config.Ingress.Host == "" {
+		return errors.New("Ingress host is required")
+	}
+	if errs := validation.IsDNS1123Subdomain(config.Ingress.Host); len(errs) > 0 {
+		return fmt.Errorf("Ingress host %v is not valid - %v", config.Ingress.Host, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// serviceHost is the name of the vamp service in the cluster
+func serviceHost(releaseName string, ns string) string {
+	return NamesOf(releaseName).Release + "." + ns + ".svc"
+}
+
+// serviceDNSNames are the names of the vamp service in the cluster
+func serviceDNSNames(config *models.VampConfig, ns string) []string {
+	service := serviceHost(config.ReleaseName, ns)
+	return []string{service, service + ".cluster.local"}
+}
+
+// publicDNSNames are the DNS names of the certificate and the ingress host
+func publicDNSNames(config *models.VampConfig) []string {
+	names := dnsNames(config)
+	if config.Ingress != nil {
+		names = append(names, config.Ingress.Host)
+	}
+	return uniqueNames(names, "")
+}
+
+// alternateDNSNames are the names that generated certificates are valid for besides the endpoint host
+func alternateDNSNames(config *models.VampConfig, ns string, host string) []string {
+	// localhost is used by port forwarding and the service names by clients in the cluster
+	names := append([]string{"localhost"}, serviceDNSNames(config, ns)...)
+	return uniqueNames(append(names, publicDNSNames(config)...), host)
+}
+
+// uniqueNames returns names without duplicates and without the excluded name
+func uniqueNames(names []string, exclude string) []string {
+	res := make([]string, 0, len(names))
+	seen := map[string]bool{exclude: true}
+	for _, name := range names {
+		if !seen[name] {
+			seen[name] = true
+			res = append(res, name)
+		}
+	}
+	return res
+}
+
+/*
+ServiceEndpoint waits until the vamp service is exposed and returns its endpoint.
+Ingresses are reached at their host, load balancers at their external IP and node ports at the address of a node.
+Services of type ClusterIP are reached at their name in the cluster.
+*/
+func ServiceEndpoint(clientset kubernetes.Interface, ns string, config *models.VampConfig) (Endpoint, error) {
+	if config.Ingress != nil {
+		return Endpoint{Host: config.Ingress.Host, Port: IngressPort}, nil
+	}
+	name := NamesOf(config.ReleaseName).Release
+	switch serviceType(config) {
+	case ServiceTypeNodePort:
+		service, err := clientset.CoreV1().Services(ns).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return Endpoint{}, err
+		}
+		var nodePort int32
+		for _, port := range service.Spec.Ports {
+			if port.Port == VampPort {
+				nodePort = port.NodePort
+			}
+		}
+		if nodePort == 0 {
+			return Endpoint{}, fmt.Errorf("Node port of service %v is not assigned", name)
+		}
+		address, err := nodeAddress(clientset)
+		if err != nil {
+			return Endpoint{}, err
+		}
+		return Endpoint{Host: address, Port: nodePort}, nil
+	case ServiceTypeClusterIP:
+		return Endpoint{Host: serviceDNSNames(config, ns)[0], Port: VampPort}, nil
+	}
+	ip, err := GetServiceExternalIP(clientset, ns, name)
+	if err != nil {
+		return Endpoint{}, err
+	}
+	return Endpoint{Host: ip, Port: VampPort}, nil
+}
+
+// nodeAddress returns the external address of a node, the internal address is used if nodes don't have external addresses
+func nodeAddress(clientset kubernetes.Interface) (string, error) {
+	nodes, err := clientset.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return "", err
+	}
+	for _, addressType := range []corev1.NodeAddressType{corev1.NodeExternalIP, corev1.NodeInternalIP} {
+		for _, node := range nodes.Items {
+			for _, address := range node.Status.Addresses {
+				if address.Type == addressType && address.Address != "" {
+					return address.Address, nil
+				}
+			}
+		}
+	}
+	return "", errors.New("No node has an address")
+}
+
+// vampIngress routes the ingress host to the vamp service, TLS is passed through to vamp if the ingress has no secret
+func vampIngress(config *models.VampConfig) *extensionsv1beta1.Ingress {
+	names := NamesOf(config.ReleaseName)
+	annotations := map[string]string{
+		"nginx.ingress.kubernetes.io/backend-protocol": "HTTPS",
+	}
+	if config.Ingress.SecretName == "" {
+		annotations["nginx.ingress.kubernetes.io/ssl-passthrough"] = "true"
+	}
+	if config.Ingress.ClassName != "" {
+		annotations["kubernetes.io/ingress.class"] = config.Ingress.ClassName
+	}
+	meta := objectMeta(config, names.Release, nil)
+	meta.Annotations = mergeLabels(mergeLabels(meta.Annotations, annotations), config.Ingress.Annotations)
+	return &extensionsv1beta1.Ingress{
+		ObjectMeta: meta,
+		Spec: extensionsv1beta1.IngressSpec{
+			TLS: []extensionsv1beta1.IngressTLS{
+				{
+					Hosts:      []string{config.Ingress.Host},
+					SecretName: config.Ingress.SecretName,
+				},
+			},
+			Rules: []extensionsv1beta1.IngressRule{
+				{
+					Host: config.Ingress.Host,
+					IngressRuleValue: extensionsv1beta1.IngressRuleValue{
+						HTTP: &extensionsv1beta1.HTTPIngressRuleValue{
+							Paths: []extensionsv1beta1.HTTPIngressPath{
+								{
+									Path: "/",
+									Backend: extensionsv1beta1.IngressBackend{
+										ServiceName: names.Release,
+										ServicePort: intstr.FromInt(VampPort),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func CreateOrUpdateIngress(clientset kubernetes.Interface, ns string, ingress *extensionsv1beta1.Ingress) error {
+	fmt.Printf("CreateOrUpdateIngress: %v\n", ingress.GetObjectMeta().GetName())
+	ingressesClient := clientset.ExtensionsV1beta1().Ingresses(ns)
+	_, errIngress := ingressesClient.Create(ingress)
+	if errIngress != nil {
+		fmt.Printf("Warning: %v\n", errIngress.Error())
+		return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			currentIngress, getErr := ingressesClient.Get(ingress.GetObjectMeta().GetName(), metav1.GetOptions{})
+			if getErr != nil {
+				return fmt.Errorf("Failed to get latest version of Ingress: %v", getErr)
+			}
+			ingress.ObjectMeta.ResourceVersion = currentIngress.ObjectMeta.ResourceVersion
+			_, updateErr := ingressesClient.Update(ingress)
+			return updateErr
+		})
+	}
+	return nil
+}
+
+// GetVampPodName returns the name of a running pod of the vamp deployment of the release
+func GetVampPodName(clientset kubernetes.Interface, ns string, releaseName string) (string, error) {
+	pods, err := clientset.CoreV1().Pods(ns).List(metav1.ListOptions{LabelSelector: NamesOf(releaseName).PodSelector()})
+	if err != nil {
+		return "", err
+	}
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodRunning && pod.DeletionTimestamp == nil {
+			return pod.Name, nil
+		}
+	}
+	return "", fmt.Errorf("No running pod of %v in namespace %v", NamesOf(releaseName).Release, ns)
+}
+
+// GetVampCertificate returns the certificate that the vamp deployment of the release serves
+func GetVampCertificate(clientset kubernetes.Interface, ns string, releaseName string) ([]byte, error) {
+	deployment, err := clientset.AppsV1().Deployments(ns).Get(NamesOf(releaseName).Release, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	secret, _, err := deploymentCertificate(deployment)
+	if err != nil {
+		return nil, err
+	}
+	crt, _, err := readCertificateSecret(clientset, ns, secret)
+	return crt, err
+}
@@ -0,0 +1,179 @@
+package kubeclient_test
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"strings"
+	"testing"
+
+	kubeclient "github.com/magneticio/vampkubistcli/kubernetes"
+	"github.com/magneticio/vampkubistcli/models"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func externalHostEnv(t *testing.T, container corev1.Container) string {
+	for _, env := range container.Env {
+		if env.Name == "API_EXTERNAL_HOST" {
+			return env.Value
+		}
+	}
+	t.Fatalf("API_EXTERNAL_HOST is missing in %v", container.Env)
+	return ""
+}
+
+func certificateNames(t *testing.T, crt []byte) ([]string, []net.IP) {
+	block, _ := pem.Decode(crt)
+	if block == nil {
+		t.Fatal("Certificate can not be decoded")
+	}
+	parsed, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("Cannot parse certificate: %v", err)
+	}
+	return parsed.DNSNames, parsed.IPAddresses
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func TestInstallVampWithNodePort(t *testing.T) {
+	ns := kubeclient.InstallationNamespace
+	clientset := newFakeClientset(&corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Status: corev1.NodeStatus{Addresses: []corev1.NodeAddress{
+			{Type: corev1.NodeInternalIP, Address: "192.168.0.2"},
+			{Type: corev1.NodeHostName, Address: "node-1"},
+		}},
+	})
+	config := tlsTestConfig(nil)
+	config.ServiceType = kubeclient.ServiceTypeNodePort
+
+	url, crt, _, err := kubeclient.InstallVamp(clientset, ns, &config)
+	if err != nil {
+		t.Fatalf("InstallVamp returned error: %v", err)
+	}
+	if *url != "https://192.168.0.2:30888" {
+		t.Errorf("Expected url of the node port, got %v", *url)
+	}
+	service, _ := clientset.CoreV1().Services(ns).Get("vamp", metav1.GetOptions{})
+	if service.Spec.Type != corev1.ServiceTypeNodePort {
+		t.Errorf("Expected node port service, got %v", service.Spec.Type)
+	}
+	names, ips := certificateNames(t, crt)
+	if len(ips) != 1 || ips[0].String() != "192.168.0.2" || !contains(names, "localhost") {
+		t.Errorf("Certificate should be valid for the node and localhost, got %v %v", names, ips)
+	}
+	deployment, _ := clientset.AppsV1().Deployments(ns).Get("vamp", metav1.GetOptions{})
+	if host := externalHostEnv(t, deployment.Spec.Template.Spec.Containers[0]); host != "192.168.0.2:30888" {
+		t.Errorf("Unexpected external host %v", host)
+	}
+
+	// node ports are kept on updates
+	secondURL, _, _, err := kubeclient.InstallVamp(clientset, ns, &config)
+	if err != nil {
+		t.Fatalf("InstallVamp over an existing installation returned error: %v", err)
+	}
+	if *secondURL != *url {
+		t.Errorf("Url should be kept on updates, got %v", *secondURL)
+	}
+}
+
+func TestInstallVampWithClusterIPAndIngress(t *testing.T) {
+	ns := kubeclient.InstallationNamespace
+	clientset := newFakeClientset()
+	config := tlsTestConfig(nil)
+	config.ServiceType = kubeclient.ServiceTypeClusterIP
+	config.Ingress = &models.IngressConfig{Host: "vamp.example.com", ClassName: "nginx"}
+
+	url, crt, _, err := kubeclient.InstallVamp(clientset, ns, &config)
+	if err != nil {
+		t.Fatalf("InstallVamp returned error: %v", err)
+	}
+	if *url != "https://vamp.example.com:443" {
+		t.Errorf("Expected url of the ingress, got %v", *url)
+	}
+	if kubeclient.IsClusterInternal(&config) {
+		t.Error("Vamp with an ingress is reachable from outside of the cluster")
+	}
+	names, _ := certificateNames(t, crt)
+	if !contains(names, "vamp.example.com") || !contains(names, "vamp.vamp-system.svc") {
+		t.Errorf("Certificate should be valid for the ingress host and the service, got %v", names)
+	}
+	ingress, err := clientset.ExtensionsV1beta1().Ingresses(ns).Get("vamp", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Ingress is not created - %v", err)
+	}
+	if ingress.Spec.Rules[0].Host != "vamp.example.com" || ingress.Spec.Rules[0].HTTP.Paths[0].Backend.ServicePort.IntVal != kubeclient.VampPort {
+		t.Errorf("Unexpected ingress rules %+v", ingress.Spec.Rules)
+	}
+	if ingress.Annotations["nginx.ingress.kubernetes.io/ssl-passthrough"] != "true" || ingress.Annotations["kubernetes.io/ingress.class"] != "nginx" {
+		t.Errorf("Unexpected ingress annotations %v", ingress.Annotations)
+	}
+}
+
+func TestInstallVampWithClusterIP(t *testing.T) {
+	ns := kubeclient.InstallationNamespace
+	clientset := newFakeClientset()
+	config := tlsTestConfig(nil)
+	config.ServiceType = kubeclient.ServiceTypeClusterIP
+
+	url, _, _, err := kubeclient.InstallVamp(clientset, ns, &config)
+	if err != nil {
+		t.Fatalf("InstallVamp returned error: %v", err)
+	}
+	if *url != "https://vamp.vamp-system.svc:8888" || !kubeclient.IsClusterInternal(&config) {
+		t.Errorf("Expected url of the service in the cluster, got %v", *url)
+	}
+
+	if _, err := kubeclient.GetVampPodName(clientset, ns, config.ReleaseName); err == nil {
+		t.Error("No pod should be found without running pods")
+	}
+	for _, pod := range []*corev1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Name: "vamp-pending", Namespace: ns, Labels: map[string]string{"app": "vamp", "deployment": "vamp"}}, Status: corev1.PodStatus{Phase: corev1.PodPending}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "vamp-running", Namespace: ns, Labels: map[string]string{"app": "vamp", "deployment": "vamp"}}, Status: corev1.PodStatus{Phase: corev1.PodRunning}},
+	} {
+		if _, err := clientset.CoreV1().Pods(ns).Create(pod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	podName, err := kubeclient.GetVampPodName(clientset, ns, config.ReleaseName)
+	if err != nil || podName != "vamp-running" {
+		t.Errorf("Expected running pod, got %v %v", podName, err)
+	}
+	crt, err := kubeclient.GetVampCertificate(clientset, ns, config.ReleaseName)
+	if err != nil || !strings.Contains(string(crt), "CERTIFICATE") {
+		t.Errorf("Certificate of the deployment should be returned, got %v", err)
+	}
+}
+
+func TestValidateExposure(t *testing.T) {
+	tests := []struct {
+		name   string
+		config models.VampConfig
+		valid  bool
+	}{
+		{"default", models.VampConfig{}, true},
+		{"node port", models.VampConfig{ServiceType: "NodePort"}, true},
+		{"unknown type", models.VampConfig{ServiceType: "ExternalName"}, false},
+		{"ingress", models.VampConfig{ServiceType: "ClusterIP", Ingress: &models.IngressConfig{Host: "vamp.example.com"}}, true},
+		{"ingress without host", models.VampConfig{Ingress: &models.IngressConfig{}}, false},
+		{"invalid ingress host", models.VampConfig{Ingress: &models.IngressConfig{Host: "Vamp_Example"}}, false},
+	}
+	for _, test := range tests {
+		err := kubeclient.ValidateExposure(&test.config)
+		if test.valid && err != nil {
+			t.Errorf("%v: unexpected error %v", test.name, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%v: expected an error", test.name)
+		}
+	}
+}
@@ -0,0 +1,371 @@
+package kubeclient_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	kubeclient "github.com/magneticio/vampkubistcli/kubernetes"
+	"github.com/magneticio/vampkubistcli/models"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	k8stesting "k8s.io/client-go/testing"
+	metricsclient "k8s.io/metrics/pkg/client/clientset/versioned"
+	metricsfake "k8s.io/metrics/pkg/client/clientset/versioned/fake"
+)
+
+type fakeK8sClientProvider struct {
+	clientset *fake.Clientset
+	metrics   *metricsfake.Clientset
+}
+
+func (provider fakeK8sClientProvider) Get(configPath string) (kubernetes.Interface, error) {
+	return provider.clientset, nil
+}
+
+func (provider fakeK8sClientProvider) Metrics(configPath string) (metricsclient.Interface, error) {
+	if provider.metrics == nil {
+		return nil, errors.New("metrics API is not served")
+	}
+	return provider.metrics, nil
+}
+
+func (provider fakeK8sClientProvider) Host(configPath string) (string, error) {
+	return "https://k8s.test", nil
+}
+
+/*
+newFakeClientset returns a clientset that assigns an external IP to load balancer services and node ports to node port services.
+Objects are kept in a tracker of the test because the fake doesn't keep status on updates like the API server does
+and reactors only get copies of actions.
+*/
+func newFakeClientset(objects ...runtime.Object) *fake.Clientset {
+	tracker := k8stesting.NewObjectTracker(scheme.Scheme, scheme.Codecs.UniversalDecoder())
+	for _, object := range objects {
+		if err := tracker.Add(object); err != nil {
+			panic(err)
+		}
+	}
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("*", "*", k8stesting.ObjectReaction(tracker))
+	assignIP := func(action k8stesting.Action) (bool, runtime.Object, error) {
+		service := action.(interface{ GetObject() runtime.Object }).GetObject().(*corev1.Service)
+		if service.Spec.Type == corev1.ServiceTypeLoadBalancer {
+			service.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: "10.0.0.1"}}
+		}
+		if service.Spec.Type == corev1.ServiceTypeNodePort {
+			for i := range service.Spec.Ports {
+				if service.Spec.Ports[i].NodePort == 0 {
+					service.Spec.Ports[i].NodePort = 30000 + service.Spec.Ports[i].Port%1000
+				}
+			}
+		}
+		return k8stesting.ObjectReaction(tracker)(action)
+	}
+	clientset.PrependReactor("create", "services", assignIP)
+	clientset.PrependReactor("update", "services", assignIP)
+	// token secrets are populated like the token controller does
+	clientset.PrependReactor("create", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		secret := action.(interface{ GetObject() runtime.Object }).GetObject().(*corev1.Secret)
+		if secret.Type == corev1.SecretTypeServiceAccountToken {
+			secret.Data = map[string][]byte{"ca.crt": []byte("certificate"), "token": []byte("token-" + secret.Name)}
+		}
+		return k8stesting.ObjectReaction(tracker)(action)
+	})
+	return clientset
+}
+
+func TestInstallMongoDB(t *testing.T) {
+	clientset := newFakeClientset()
+	if err := kubeclient.InstallMongoDB(clientset, kubeclient.InstallationNamespace, &kubeclient.DefaultVampConfig); err != nil {
+		t.Fatalf("InstallMongoDB returned error: %v", err)
+	}
+	service, err := clientset.CoreV1().Services(kubeclient.InstallationNamespace).Get("vamp-mongodb", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Mongo service is not created - %v", err)
+	}
+	if service.Spec.ClusterIP != "None" || service.Spec.Ports[0].Port != 27017 {
+		t.Errorf("Mongo service should be headless on port 27017 in %+v", service.Spec)
+	}
+	statefulSet, err := clientset.AppsV1().StatefulSets(kubeclient.InstallationNamespace).Get("mongo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Mongo stateful set is not created - %v", err)
+	}
+	if *statefulSet.Spec.Replicas != 3 || statefulSet.Spec.ServiceName != "vamp-mongodb" {
+		t.Errorf("Unexpected stateful set spec %+v", statefulSet.Spec)
+	}
+
+	// installing again updates the existing objects
+	if err := kubeclient.InstallMongoDB(clientset, kubeclient.InstallationNamespace, &kubeclient.DefaultVampConfig); err != nil {
+		t.Fatalf("InstallMongoDB over an existing installation returned error: %v", err)
+	}
+}
+
+func TestInstallVamp(t *testing.T) {
+	clientset := newFakeClientset()
+	config := kubeclient.DefaultVampConfig
+	config.RootPassword = "root"
+	config.RepoUsername = "user"
+	config.RepoPassword = "pass"
+	config.DatabaseUrl = "mongodb://mongo-0.vamp-mongodb:27017"
+	ns := kubeclient.InstallationNamespace
+
+	url, cert, key, err := kubeclient.InstallVamp(clientset, ns, &config)
+	if err != nil {
+		t.Fatalf("InstallVamp returned error: %v", err)
+	}
+	if *url != "https://10.0.0.1:8888" {
+		t.Errorf("Expected url of the load balancer, got %v", *url)
+	}
+	if len(cert) == 0 || len(key) == 0 {
+		t.Error("Certificate and key should be generated")
+	}
+
+	for _, name := range []string{"vampkubistimagepull", "certificates-for-10.0.0.1", "vamprootpassword"} {
+		if _, err := clientset.CoreV1().Secrets(ns).Get(name, metav1.GetOptions{}); err != nil {
+			t.Errorf("Secret %v is not created - %v", name, err)
+		}
+	}
+	password, _ := clientset.CoreV1().Secrets(ns).Get("vamprootpassword", metav1.GetOptions{})
+	if password != nil && string(password.Data["password"]) != "root" {
+		t.Errorf("Root password isn't stored in %v", password.Data)
+	}
+
+	deployment, err := clientset.AppsV1().Deployments(ns).Get("vamp", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Vamp deployment is not created - %v", err)
+	}
+	container := deployment.Spec.Template.Spec.Containers[0]
+	if container.Image != config.ImageName+":"+config.ImageTag {
+		t.Errorf("Unexpected image %v", container.Image)
+	}
+	if cpu := container.Resources.Requests[corev1.ResourceCPU]; cpu.String() != config.RequestCPU {
+		t.Errorf("Expected cpu request %v, got %v", config.RequestCPU, cpu.String())
+	}
+	if memory := container.Resources.Limits[corev1.ResourceMemory]; memory.String() != config.LimitMemory {
+		t.Errorf("Expected memory limit %v, got %v", config.LimitMemory, memory.String())
+	}
+	env := map[string]string{}
+	for _, e := range container.Env {
+		env[e.Name] = e.Value
+	}
+	if env["DBURL"] != config.DatabaseUrl || env["API_EXTERNAL_HOST"] != "10.0.0.1:8888" {
+		t.Errorf("Unexpected environment %v", env)
+	}
+
+	hpa, err := clientset.AutoscalingV2beta1().HorizontalPodAutoscalers(ns).Get("vamp", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Vamp HPA is not created - %v", err)
+	}
+	if hpa.Spec.MaxReplicas != config.MaxReplicas || hpa.Spec.Metrics[0].Resource.TargetAverageValue.String() != config.TargetCPUAverageValue {
+		t.Errorf("Unexpected HPA spec %+v", hpa.Spec)
+	}
+
+	// installing again keeps the certificate of the external IP
+	_, secondCert, _, err := kubeclient.InstallVamp(clientset, ns, &config)
+	if err != nil {
+		t.Fatalf("InstallVamp over an existing installation returned error: %v", err)
+	}
+	if !bytes.Equal(cert, secondCert) {
+		t.Error("Certificate should be reused by a second installation")
+	}
+}
+
+func TestCreateOrUpdateHPA(t *testing.T) {
+	clientset := newFakeClientset()
+	config := models.VampConfig{
+		MinReplicas:                    int32Ptr(2),
+		MaxReplicas:                    4,
+		TargetCPUUtilizationPercentage: int32Ptr(70),
+	}
+	if err := kubeclient.CreateOrUpdateHPA(clientset, &config); err != nil {
+		t.Fatalf("CreateOrUpdateHPA returned error: %v", err)
+	}
+	config.MaxReplicas = 8
+	if err := kubeclient.CreateOrUpdateHPA(clientset, &config); err != nil {
+		t.Fatalf("CreateOrUpdateHPA of an existing HPA returned error: %v", err)
+	}
+	hpa, err := clientset.AutoscalingV2beta1().HorizontalPodAutoscalers(kubeclient.InstallationNamespace).Get("vamp", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("HPA is not created - %v", err)
+	}
+	if *hpa.Spec.MinReplicas != 2 || hpa.Spec.MaxReplicas != 8 {
+		t.Errorf("HPA should be updated in %+v", hpa.Spec)
+	}
+	cpu := hpa.Spec.Metrics[0].Resource
+	if cpu.TargetAverageValue != nil || *cpu.TargetAverageUtilization != 70 {
+		t.Errorf("Only cpu utilisation should be set in %+v", cpu)
+	}
+}
+
+func TestBootstrapAndUninstallVampService(t *testing.T) {
+	ns := kubeclient.InstallationNamespace
+	clientset := newFakeClientset(
+		&corev1.ServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: ns},
+			Secrets:    []corev1.ObjectReference{{Name: "default-token-abcde"}},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "default-token-abcde", Namespace: ns},
+			Data: map[string][]byte{
+				"ca.crt": []byte("certificate"),
+				"token":  []byte("token"),
+			},
+		},
+	)
+	kubeclient.K8sClient = fakeK8sClientProvider{clientset: clientset}
+
+	host, crt, token, err := kubeclient.BootstrapVampService("", ns)
+	if err != nil {
+		t.Fatalf("BootstrapVampService returned error: %v", err)
+	}
+	if host != "https://k8s.test" || crt != "certificate" || token != "token" {
+		t.Errorf("Unexpected credentials %v %v %v", host, crt, token)
+	}
+	if _, err := clientset.CoreV1().Namespaces().Get(ns, metav1.GetOptions{}); err != nil {
+		t.Errorf("Namespace is not created - %v", err)
+	}
+	binding, err := clientset.RbacV1().ClusterRoleBindings().Get(kubeclient.ClusterRoleBindingName(ns), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Cluster role binding is not created - %v", err)
+	}
+	if binding.Subjects[0].Name != "system:serviceaccount:"+ns+":default" || binding.RoleRef.Name != "cluster-admin" {
+		t.Errorf("Unexpected cluster role binding %+v", binding)
+	}
+
+	if err := kubeclient.UninstallVampService("", ns); err != nil {
+		t.Fatalf("UninstallVampService returned error: %v", err)
+	}
+	if _, err := clientset.CoreV1().Namespaces().Get(ns, metav1.GetOptions{}); err == nil {
+		t.Error("Namespace should be deleted")
+	}
+	if _, err := clientset.RbacV1().ClusterRoleBindings().Get(kubeclient.ClusterRoleBindingName(ns), metav1.GetOptions{}); err == nil {
+		t.Error("Cluster role binding should be deleted")
+	}
+	if err := kubeclient.UninstallVampService("", ns); err == nil {
+		t.Error("Uninstall of a missing installation should fail")
+	}
+}
+
+func TestGetLabelsWithFakeClientset(t *testing.T) {
+	clientset := newFakeClientset(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "vamp-1", Namespace: "vamp-system", Labels: map[string]string{"app": "vamp"}},
+	})
+	kubeclient.K8sClient = fakeK8sClientProvider{clientset: clientset}
+
+	var pods kubeclient.PodMetricsList
+	metrics := `{"items": [{"metadata": {"name": "vamp-1", "namespace": "vamp-system"}, "containers": [{"name": "vamp", "usage": {"cpu": "250m", "memory": "1Gi"}}]}]}`
+	if err := json.Unmarshal([]byte(metrics), &pods); err != nil {
+		t.Fatalf("Cannot parse metrics: %v", err)
+	}
+	if err := kubeclient.GetLabelsWithOptions("", kubeclient.MetricsOptions{Namespace: "vamp-system"}, &pods); err != nil {
+		t.Fatalf("GetLabelsWithOptions returned error: %v", err)
+	}
+	if pods.Items[0].Metadata.Labels["app"] != "vamp" {
+		t.Errorf("Labels aren't populated in %v", pods.Items[0].Metadata)
+	}
+
+	// the metrics API isn't served by the fake clientset
+	if _, err := kubeclient.GetAverageMetrics("", "vamp-system"); err == nil {
+		t.Error("GetAverageMetrics should fail without the metrics API")
+	}
+}
+
+func int32Ptr(i int32) *int32 { return &i }
+
+func TestInstallReleasesSideBySide(t *testing.T) {
+	clientset := newFakeClientset()
+	for _, release := range []string{"vamp", "staging"} {
+		config := kubeclient.DefaultVampConfig
+		config.RootPassword = "root"
+		config.Namespace = "vamp-" + release
+		config.ReleaseName = release
+		config.Labels = map[string]string{"team": "platform", "app": "overridden"}
+		config.Annotations = map[string]string{"owner": "platform@example.com"}
+		if err := kubeclient.InstallMongoDB(clientset, config.Namespace, &config); err != nil {
+			t.Fatalf("InstallMongoDB of %v returned error: %v", release, err)
+		}
+		config.DatabaseUrl = "mongodb://" + kubeclient.NamesOf(release).MongoDBHosts(3)
+		if _, _, _, err := kubeclient.InstallVamp(clientset, config.Namespace, &config); err != nil {
+			t.Fatalf("InstallVamp of %v returned error: %v", release, err)
+		}
+	}
+
+	names := kubeclient.NamesOf("staging")
+	ns := "vamp-staging"
+	deployment, err := clientset.AppsV1().Deployments(ns).Get("staging", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Deployment of the release is not created - %v", err)
+	}
+	if deployment.Labels["app"] != "staging" || deployment.Labels["team"] != "platform" || deployment.Annotations["owner"] != "platform@example.com" {
+		t.Errorf("Unexpected labels %v and annotations %v", deployment.Labels, deployment.Annotations)
+	}
+	if deployment.Spec.Selector.MatchLabels["deployment"] != "staging" || deployment.Spec.Template.Labels["team"] != "platform" {
+		t.Errorf("Unexpected selector %v", deployment.Spec.Selector)
+	}
+	env := map[string]string{}
+	for _, e := range deployment.Spec.Template.Spec.Containers[0].Env {
+		env[e.Name] = e.Value
+		if e.Name == "ROOT_PASSWORD" && e.ValueFrom.SecretKeyRef.Name != names.RootPassword {
+			t.Errorf("Root password should be read from %v", names.RootPassword)
+		}
+	}
+	if env["DBURL"] != "mongodb://staging-mongo-0.staging-mongodb:27017,staging-mongo-1.staging-mongodb:27017,staging-mongo-2.staging-mongodb:27017" {
+		t.Errorf("Unexpected database url %v", env["DBURL"])
+	}
+	for _, name := range []string{names.ImagePull, names.RootPassword} {
+		if _, err := clientset.CoreV1().Secrets(ns).Get(name, metav1.GetOptions{}); err != nil {
+			t.Errorf("Secret %v is not created - %v", name, err)
+		}
+	}
+	for _, name := range []string{names.Release, names.Hazelcast, names.MongoDB} {
+		if _, err := clientset.CoreV1().Services(ns).Get(name, metav1.GetOptions{}); err != nil {
+			t.Errorf("Service %v is not created - %v", name, err)
+		}
+	}
+	if _, err := clientset.AppsV1().StatefulSets(ns).Get("staging-mongo", metav1.GetOptions{}); err != nil {
+		t.Errorf("Stateful set of the release is not created - %v", err)
+	}
+	if _, err := clientset.AutoscalingV2beta1().HorizontalPodAutoscalers(ns).Get("staging", metav1.GetOptions{}); err != nil {
+		t.Errorf("HPA of the release is not created - %v", err)
+	}
+	// the default release keeps the names of earlier installations
+	if _, err := clientset.AppsV1().StatefulSets("vamp-vamp").Get("mongo", metav1.GetOptions{}); err != nil {
+		t.Errorf("Stateful set of the default release is not created - %v", err)
+	}
+	if selector := names.PodSelector(); selector != "app=staging,deployment=staging" {
+		t.Errorf("Unexpected pod selector %v", selector)
+	}
+}
+
+func TestVampConfigNamespaceAndReleaseName(t *testing.T) {
+	tests := []struct {
+		namespace   string
+		releaseName string
+		valid       bool
+	}{
+		{"", "", true},
+		{"vamp-staging", "staging", true},
+		{"Vamp", "", false},
+		{"", "staging_1", false},
+		{"", "a-release-name-that-is-too-long-for-names-of-the-image-pull-secret", false},
+	}
+	for _, test := range tests {
+		config := models.VampConfig{RootPassword: "root", RepoUsername: "user", RepoPassword: "pass", Namespace: test.namespace, ReleaseName: test.releaseName}
+		validated, err := kubeclient.VampConfigValidateAndSetupDefaults(&config)
+		if test.valid && err != nil {
+			t.Errorf("Namespace %q and release %q should be valid - %v", test.namespace, test.releaseName, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("Namespace %q and release %q should not be valid", test.namespace, test.releaseName)
+		}
+		if test.valid && (validated.Namespace == "" || validated.ReleaseName == "") {
+			t.Errorf("Defaults should be set in %+v", validated)
+		}
+	}
+}
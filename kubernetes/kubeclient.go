@@ -4,12 +4,12 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
-	"net"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 
 	"github.com/cenkalti/backoff"
-	"github.com/magneticio/vampkubistcli/client"
 	"github.com/magneticio/vampkubistcli/logging"
 	"github.com/magneticio/vampkubistcli/models"
 	appsv1 "k8s.io/api/apps/v1"
@@ -21,10 +21,10 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	apiutil "k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/util/cert"
 	"k8s.io/client-go/util/retry"
 
 	// Initialize all known client auth plugins.
@@ -55,17 +55,16 @@ var DefaultVampConfig = models.VampConfig{
 	TargetCPUAverageValue:    "900m",  // resource.NewScaledQuantity(900, resource.Milli),
 	TargetMemoryAverageValue: "768Mi", // resource.NewQuantity(768*1024*1024, resource.BinarySI),
 	EnableLogstash:           "1",
+	Namespace:                InstallationNamespace,
+	ReleaseName:              DefaultReleaseName,
+	ServiceType:              ServiceTypeLoadBalancer,
 }
 
-// This is shared between installation and credentials, it is currently not configurable
-// TODO: add it to VampConfig when it is configurable
+// InstallationNamespace is the namespace of installation and credentials if no namespace is configured
 const InstallationNamespace = "vamp-system"
 
 var IsKubeClientInCluster = false
 
-// VampClusterRoleBindingName contains name of ClusterRoleBinding for Vamp
-const VampClusterRoleBindingName = InstallationNamespace + "-sa-cluster-admin-binding"
-
 func VampConfigValidateAndSetupDefaults(config *models.VampConfig) (*models.VampConfig, error) {
 	if config.RootPassword == "" {
 		// This is enforced
@@ -111,6 +110,33 @@ func VampConfigValidateAndSetupDefaults(config *models.VampConfig) (*models.Vamp
 		config.EnableLogstash = DefaultVampConfig.EnableLogstash
 		fmt.Printf("EnableLogstash set to default value: %v\n", config.EnableLogstash)
 	}
+	if config.Namespace == "" {
+		config.Namespace = DefaultVampConfig.Namespace
+		fmt.Printf("Namespace set to default value: %v\n", config.Namespace)
+	}
+	if errs := validation.IsDNS1123Label(config.Namespace); len(errs) > 0 {
+		return config, fmt.Errorf("Namespace %v is not valid - %v", config.Namespace, strings.Join(errs, ", "))
+	}
+	if config.ReleaseName == "" {
+		config.ReleaseName = DefaultVampConfig.ReleaseName
+		fmt.Printf("Release Name set to default value: %v\n", config.ReleaseName)
+	}
+	if err := NamesOf(config.ReleaseName).Validate(); err != nil {
+		return config, err
+	}
+	if err := ValidateTLSConfig(config.TLS); err != nil {
+		return config, err
+	}
+	if config.ServiceType == "" {
+		config.ServiceType = DefaultVampConfig.ServiceType
+		fmt.Printf("Service Type set to default value: %v\n", config.ServiceType)
+	}
+	if err := ValidateExposure(config); err != nil {
+		return config, err
+	}
+	if err := ValidateMongoConfig(config.Mongo); err != nil {
+		return config, err
+	}
 	k8sCfg := &K8sVampConfig{Config: config}
 	k8sCfg.ValidateMinMaxReplicas()
 	k8sCfg.ValidateTargetCPU()
@@ -297,8 +323,8 @@ func (cfg *K8sVampConfig) ValidateLimitCPU() {
 			fmt.Printf("LimitCPU %v is wrongly formatted - %v\n", val, err)
 			return false
 		}
-		if q.Cmp(*resource.NewScaledQuantity(1, resource.Milli)) < 0 || q.Cmp(*resource.NewScaledQuantity(100, resource.Milli)) > 0 {
-			fmt.Printf("LimitCPU %v shouldn't be less than 1m or greater than 100m\n", val)
+		if q.Cmp(*resource.NewScaledQuantity(1, resource.Milli)) < 0 || q.Cmp(*resource.NewQuantity(100, resource.DecimalSI)) > 0 {
+			fmt.Printf("LimitCPU %v shouldn't be less than 1m or greater than 100\n", val)
 			return false
 		}
 		return true
@@ -348,28 +374,34 @@ func GetKubeConfigPath(configPath string) *string {
 }
 
 /*
-Builds and returns ClientSet by using local KubeConfig
-It also returns hostname since it is needed.
+Builds and returns rest config by using local KubeConfig or in cluster config
 */
-func getLocalKubeClient(configPath string) (*kubernetes.Clientset, string, error) {
+func getLocalKubeConfig(configPath string) (*rest.Config, error) {
 	if IsKubeClientInCluster {
 		// creates the in-cluster config
 		config, err := rest.InClusterConfig()
 		if err != nil {
-			return nil, "", fmt.Errorf("Kube Client can not be created due to %v", err)
+			return nil, fmt.Errorf("Kube Client can not be created due to %v", err)
 		}
-		// create the clientset
-		clientset, err := kubernetes.NewForConfig(config)
-		if err != nil {
-			return nil, "", err
-		}
-		return clientset, config.Host, nil
+		return config, nil
 	}
 	kubeconfigpath := GetKubeConfigPath(configPath)
 	// use the current context in kubeconfig
 	config, err := clientcmd.BuildConfigFromFlags("", *kubeconfigpath)
 	if err != nil {
-		return nil, "", fmt.Errorf("Kube Client can not be created due to %v", err)
+		return nil, fmt.Errorf("Kube Client can not be created due to %v", err)
+	}
+	return config, nil
+}
+
+/*
+Builds and returns ClientSet by using local KubeConfig
+It also returns hostname since it is needed.
+*/
+func getLocalKubeClient(configPath string) (kubernetes.Interface, string, error) {
+	config, err := getLocalKubeConfig(configPath)
+	if err != nil {
+		return nil, "", err
 	}
 	// create the clientset
 	clientset, err := kubernetes.NewForConfig(config)
@@ -383,21 +415,14 @@ func getLocalKubeClient(configPath string) (*kubernetes.Clientset, string, error
 This method installs namespace, cluster role binding and image pull secret
 TODO: differenciate between already exists and other error types
 */
-func SetupVampCredentials(clientset *kubernetes.Clientset, ns string, rbName string) error {
-	nsSpec := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: ns}}
-	_, namespaceCreationError := clientset.CoreV1().Namespaces().Create(nsSpec)
+func SetupVampCredentials(clientset kubernetes.Interface, ns string, rbName string) error {
+	_, namespaceCreationError := clientset.CoreV1().Namespaces().Create(vampNamespace(ns))
 	if namespaceCreationError != nil {
 		// TODO: handle already exists
 		fmt.Printf("Warning: %v\n", namespaceCreationError.Error())
 	}
 	// Create Cluster Role Binding Vamp Default Service Account
-
-	clusterRoleBindingSpec := &rbacv1.ClusterRoleBinding{
-		ObjectMeta: metav1.ObjectMeta{Name: rbName},
-		Subjects:   []rbacv1.Subject{rbacv1.Subject{Kind: "User", Name: "system:serviceaccount:" + ns + ":default", APIGroup: "rbac.authorization.k8s.io"}},
-		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "cluster-admin", APIGroup: "rbac.authorization.k8s.io"},
-	}
-	_, roleBindingCreationError := clientset.RbacV1().ClusterRoleBindings().Create(clusterRoleBindingSpec)
+	_, roleBindingCreationError := clientset.RbacV1().ClusterRoleBindings().Create(vampClusterRoleBinding(ns, rbName))
 	if roleBindingCreationError != nil {
 		// TODO: handle already exists
 		fmt.Printf("Warning: %v\n", roleBindingCreationError.Error())
@@ -406,7 +431,19 @@ func SetupVampCredentials(clientset *kubernetes.Clientset, ns string, rbName str
 	return nil
 }
 
-func RemoveVampCredentials(clientset *kubernetes.Clientset, ns string, rbName string) error {
+func vampNamespace(ns string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: ns}}
+}
+
+func vampClusterRoleBinding(ns string, rbName string) *rbacv1.ClusterRoleBinding {
+	return &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: rbName},
+		Subjects:   []rbacv1.Subject{rbacv1.Subject{Kind: "User", Name: "system:serviceaccount:" + ns + ":default", APIGroup: "rbac.authorization.k8s.io"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "cluster-admin", APIGroup: "rbac.authorization.k8s.io"},
+	}
+}
+
+func RemoveVampCredentials(clientset kubernetes.Interface, ns string, rbName string) error {
 	if err := clientset.CoreV1().Namespaces().Delete(ns, nil); err != nil {
 		fmt.Printf("Canot delete Vamp namespace %v - %v", ns, err)
 		return err
@@ -418,117 +455,164 @@ func RemoveVampCredentials(clientset *kubernetes.Clientset, ns string, rbName st
 	return nil
 }
 
-func BootstrapVampService(configPath string) (string, string, string, error) {
+func BootstrapVampService(configPath string, ns string) (string, string, string, error) {
+	return BootstrapVampServiceWithOptions(configPath, ns, BootstrapOptions{RBAC: RBACClusterAdmin})
+}
+
+/*
+BootstrapVampServiceWithOptions sets up the credentials of vamp with the RBAC mode and returns the host of the cluster
+with the certificate and token of the service account. The default service account is bound to cluster-admin,
+minimal binds a dedicated service account to a cluster role with the permissions that vamp needs.
+Refreshing credentials only replaces the token of a service account that is set up already,
+the RBAC mode is found by the binding of bootstrap if it is empty.
+*/
+func BootstrapVampServiceWithOptions(configPath string, ns string, options BootstrapOptions) (string, string, string, error) {
+	if _, err := ServiceAccountOfRBAC(options.RBAC); err != nil {
+		return "", "", "", err
+	}
 	// create the clientset
-	clientset, host, err := getLocalKubeClient(configPath)
+	clientset, err := K8sClient.Get(configPath)
 	if err != nil {
 		// panic(err.Error())
 		return "", "", "", err
 	}
-	ns := InstallationNamespace
-	errSetup := SetupVampCredentials(clientset, ns, VampClusterRoleBindingName)
-	if errSetup != nil {
-		fmt.Printf("Warning: %v\n", errSetup.Error())
-		return host, "", "", errSetup
+	host, err := K8sClient.Host(configPath)
+	if err != nil {
+		return "", "", "", err
+	}
+	if options.RefreshCredentials {
+		bootstrapped, err := BootstrappedRBAC(clientset, ns)
+		if err != nil {
+			return host, "", "", err
+		}
+		if options.RBAC != "" && options.RBAC != bootstrapped {
+			return host, "", "", fmt.Errorf("Namespace %v is bootstrapped with %v RBAC, credentials of %v RBAC can not be refreshed", ns, bootstrapped, options.RBAC)
+		}
+		options.RBAC = bootstrapped
+	}
+	serviceAccount, err := ServiceAccountOfRBAC(options.RBAC)
+	if err != nil {
+		return "", "", "", err
+	}
+	if !options.RefreshCredentials {
+		var errSetup error
+		if options.RBAC == RBACMinimal {
+			errSetup = SetupMinimalVampCredentials(clientset, ns)
+		} else {
+			errSetup = SetupVampCredentials(clientset, ns, ClusterRoleBindingName(ns))
+		}
+		if errSetup != nil {
+			fmt.Printf("Warning: %v\n", errSetup.Error())
+			return host, "", "", errSetup
+		}
 	}
 
 	// This is end of setting up remote vamp set up
 	// now we need to get information to connect to the cluster
-
-	getOptions := metav1.GetOptions{}
-	sa, err_sa := clientset.CoreV1().ServiceAccounts(ns).Get("default", getOptions)
-	if err_sa != nil {
-		fmt.Printf("Warning: %v\n", err_sa.Error())
-		return host, "", "", err_sa
-	}
-
-	saSecret, err_sa_secret := clientset.CoreV1().Secrets(ns).Get(sa.Secrets[0].Name, getOptions)
-	if err_sa_secret != nil {
-		fmt.Printf("Warning: %v\n", err_sa_secret.Error())
+	crt, token, err := ServiceAccountCredentials(clientset, ns, serviceAccount, options)
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err.Error())
 		// This is a problem command should be re-tried by user
-		return host, "", "", err_sa_secret
+		return host, "", "", err
 	}
-	crt := string(saSecret.Data["ca.crt"])
-	token := string(saSecret.Data["token"])
-
 	return host, crt, token, nil
 }
 
 func InstallVampService(config *models.VampConfig, configPath string) (string, []byte, []byte, error) {
-	host, _, _, errBootstap := BootstrapVampService(configPath)
+	ns := installationNamespace(config)
+	host, _, _, errBootstap := BootstrapVampService(configPath, ns)
 	if errBootstap != nil {
 		fmt.Printf("Warning: %v\n", errBootstap.Error())
 		// This is a problem command should be re-tried by user
 		return host, nil, nil, errBootstap
 	}
 	// create the clientset
-	clientset, host, err := getLocalKubeClient(configPath)
+	clientset, err := K8sClient.Get(configPath)
 	if err != nil {
 		// panic(err.Error())
 		return "", nil, nil, err
 	}
-	ns := InstallationNamespace
 	// Install Database or skip it
 	// Deploy Db
 	if config.DatabaseUrl == "" {
-		installMongoErr := InstallMongoDB(clientset, ns)
+		installMongoErr := InstallMongoDB(clientset, ns, config)
 		if installMongoErr != nil {
 			return "", nil, nil, installMongoErr
 		}
-		config.DatabaseUrl = "mongodb://mongo-0.vamp-mongodb:27017,mongo-1.vamp-mongodb:27017,mongo-2.vamp-mongodb:27017"
+		config.DatabaseUrl = mongoDBUrl(config)
 	}
 	// Deploy vamp
 	url, cert, key, installVampErr := InstallVamp(clientset, ns, config)
 	if installVampErr != nil {
 		return "", nil, nil, installVampErr
 	}
+	if IsClusterInternal(config) {
+		// the service can't be reached from outside of the cluster
+		return *url, cert, key, nil
+	}
 	// this waits until service is accessible and cerficate is valid
 	CheckAndWaitForService(*url, cert)
 	return *url, cert, key, nil
 }
 
-func UninstallVampService(configPath string) error {
-	clientset, _, err := getLocalKubeClient(configPath)
-	if err != nil {
-		return err
-	}
-	return RemoveVampCredentials(clientset, InstallationNamespace, VampClusterRoleBindingName)
+func UninstallVampService(configPath string, ns string) error {
+	_, err := UninstallVampServiceWithOptions(configPath, ns, UninstallOptions{})
+	return err
 }
 
 func CheckAndWaitForService(url string, cert []byte) error {
-	vampClient := client.NewRestClient(url, "", "", false, string(cert), nil)
+	return waitForService(url, cert, PingVamp, backoff.NewExponentialBackOff())
+}
+
+func waitForService(url string, cert []byte, ping PingFunc, b backoff.BackOff) error {
 	count := 1
 	operation := func() error {
 		fmt.Printf("Pinging the service trial %v\n", count)
 		count += 1
-		pong, pingErr := vampClient.Ping()
+		pingErr := ping(url, cert)
 		if pingErr != nil {
 			fmt.Printf("Failed to ping the service: %v\n", pingErr)
 			return pingErr
 		}
-		if pong {
-			fmt.Printf("Connection is available\n")
-			return nil
-		}
-		return errors.New("Service is not available yet.")
+		fmt.Printf("Connection is available\n")
+		return nil
 	}
 
-	err := backoff.Retry(operation, backoff.NewExponentialBackOff())
+	err := backoff.Retry(operation, b)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func InstallMongoDB(clientset *kubernetes.Clientset, ns string) error {
-	service := &apiv1.Service{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: "vamp-mongodb",
-		},
+func InstallMongoDB(clientset kubernetes.Interface, ns string, config *models.VampConfig) error {
+	if mongoAuthentication(config) {
+		if errCredentials := InstallMongoDBCredentials(clientset, ns, config); errCredentials != nil {
+			fmt.Printf("Warning: %v\n", errCredentials.Error())
+			return errCredentials
+		}
+	}
+	errService := CreateOrUpdateService(clientset, ns, mongoDBService(config))
+	if errService != nil {
+		fmt.Printf("Warning: %v\n", errService.Error())
+		return errService
+	}
+	errStatefulSet := CreateOrUpdateStatefulSet(clientset, ns, mongoDBStatefulSet(config))
+	if errStatefulSet != nil {
+		fmt.Printf("Warning: %v\n", errStatefulSet.Error())
+		return errStatefulSet
+	}
+	return nil
+}
+
+func mongoDBService(config *models.VampConfig) *apiv1.Service {
+	names := NamesOf(config.ReleaseName)
+	return &apiv1.Service{
+		ObjectMeta: objectMeta(config, names.MongoDB, nil),
 		Spec: apiv1.ServiceSpec{
 			ClusterIP: "None",
 			Selector: map[string]string{
-				"app": "vamp-mongodb",
+				"app": names.MongoDB,
 			},
 			Ports: []apiv1.ServicePort{
 				{
@@ -538,35 +622,33 @@ func InstallMongoDB(clientset *kubernetes.Clientset, ns string) error {
 			},
 		},
 	}
-	errService := CreateOrUpdateService(clientset, ns, service)
-	if errService != nil {
-		fmt.Printf("Warning: %v\n", errService.Error())
-		return errService
-	}
+}
+
+func mongoDBStatefulSet(config *models.VampConfig) *appsv1.StatefulSet {
+	names := NamesOf(config.ReleaseName)
+	mongo := mongoConfig(config)
 	statefulSet := &appsv1.StatefulSet{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: "mongo",
-		},
+		ObjectMeta: objectMeta(config, names.MongoStatefulSet, nil),
 		Spec: appsv1.StatefulSetSpec{
-			ServiceName: "vamp-mongodb",
-			Replicas:    int32Ptr(3),
+			ServiceName: names.MongoDB,
+			Replicas:    mongo.Replicas,
 			Selector: &metav1.LabelSelector{
 				MatchLabels: map[string]string{
-					"app": "vamp-mongodb",
+					"app": names.MongoDB,
 				},
 			},
 			Template: apiv1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: map[string]string{
-						"app": "vamp-mongodb",
-					},
+					Labels: mergeLabels(config.Labels, map[string]string{
+						"app": names.MongoDB,
+					}),
 				},
 				Spec: apiv1.PodSpec{
 					TerminationGracePeriodSeconds: int64Ptr(10),
 					Containers: []apiv1.Container{
 						{
 							Name:  "mongo",
-							Image: "mongo:4.0",
+							Image: mongo.Image,
 							Command: []string{
 								"mongod",
 								"--replSet",
@@ -587,18 +669,22 @@ func InstallMongoDB(clientset *kubernetes.Clientset, ns string) error {
 									MountPath: "/data/db",
 								},
 							},
+							Resources: apiv1.ResourceRequirements{
+								Limits:   resourceList(mongo.LimitCPU, mongo.LimitMemory),
+								Requests: resourceList(mongo.RequestCPU, mongo.RequestMemory),
+							},
 						},
 						{
 							Name:  "mongo-sidecar",
-							Image: "cvallance/mongo-k8s-sidecar",
+							Image: mongo.SidecarImage,
 							Env: []apiv1.EnvVar{
 								{
 									Name:  "MONGO_SIDECAR_POD_LABELS",
-									Value: "app=vamp-mongodb",
+									Value: "app=" + names.MongoDB,
 								},
 								{
 									Name:  "KUBERNETES_MONGO_SERVICE_NAME",
-									Value: "vamp-mongodb",
+									Value: names.MongoDB,
 								},
 							},
 						},
@@ -610,7 +696,7 @@ func InstallMongoDB(clientset *kubernetes.Clientset, ns string) error {
 					ObjectMeta: metav1.ObjectMeta{
 						Name: "mongo-persistent-storage",
 						Annotations: map[string]string{
-							"volume.beta.kubernetes.io/storage-class": "standard",
+							"volume.beta.kubernetes.io/storage-class": mongo.StorageClass,
 						},
 					},
 					Spec: apiv1.PersistentVolumeClaimSpec{
@@ -619,7 +705,7 @@ func InstallMongoDB(clientset *kubernetes.Clientset, ns string) error {
 						},
 						Resources: apiv1.ResourceRequirements{
 							Requests: apiv1.ResourceList{
-								"storage": resource.MustParse("1Gi"),
+								"storage": resource.MustParse(mongo.StorageSize),
 							},
 						},
 					},
@@ -627,38 +713,173 @@ func InstallMongoDB(clientset *kubernetes.Clientset, ns string) error {
 			},
 		},
 	}
-	errStatefulSet := CreateOrUpdateStatefulSet(clientset, ns, statefulSet)
-	if errStatefulSet != nil {
-		fmt.Printf("Warning: %v\n", errStatefulSet.Error())
-		return errStatefulSet
+	if mongoAuthentication(config) {
+		enableMongoAuthentication(&statefulSet.Spec.Template.Spec, names, mongo.Image)
+	}
+	return statefulSet
+}
+
+/*
+enableMongoAuthentication makes mongo create the root user of the credentials secret when its volume is initialized
+and replicas authenticate each other with the key file. The entrypoint of the image creates the user, so mongod is
+passed as arguments. The key file is copied by an init container since mongod only reads keys that only its user can read.
+*/
+func enableMongoAuthentication(podSpec *apiv1.PodSpec, names ReleaseNames, image string) {
+	mongod := &podSpec.Containers[0]
+	mongod.Args = append(mongod.Command, "--keyFile", mongoKeyFilePath)
+	mongod.Command = nil
+	mongod.Env = append(mongod.Env,
+		secretEnv("MONGO_INITDB_ROOT_USERNAME", names.MongoCredentials, "username"),
+		secretEnv("MONGO_INITDB_ROOT_PASSWORD", names.MongoCredentials, "password"),
+	)
+	mongod.VolumeMounts = append(mongod.VolumeMounts, apiv1.VolumeMount{Name: "mongo-keyfile", MountPath: path.Dir(mongoKeyFilePath)})
+	sidecar := &podSpec.Containers[1]
+	sidecar.Env = append(sidecar.Env,
+		secretEnv("MONGODB_USERNAME", names.MongoCredentials, "username"),
+		secretEnv("MONGODB_PASSWORD", names.MongoCredentials, "password"),
+		apiv1.EnvVar{Name: "MONGODB_DATABASE", Value: "admin"},
+	)
+	podSpec.InitContainers = []apiv1.Container{
+		{
+			Name:    "mongo-keyfile",
+			Image:   image,
+			Command: []string{"sh", "-c", fmt.Sprintf("cp /etc/mongo-credentials/keyfile %[1]v && chmod 400 %[1]v && chown 999:999 %[1]v", mongoKeyFilePath)},
+			VolumeMounts: []apiv1.VolumeMount{
+				{Name: "mongo-credentials", MountPath: "/etc/mongo-credentials", ReadOnly: true},
+				{Name: "mongo-keyfile", MountPath: path.Dir(mongoKeyFilePath)},
+			},
+		},
+	}
+	podSpec.Volumes = []apiv1.Volume{
+		{
+			Name: "mongo-credentials",
+			VolumeSource: apiv1.VolumeSource{
+				Secret: &apiv1.SecretVolumeSource{
+					SecretName: names.MongoCredentials,
+					Items:      []apiv1.KeyToPath{{Key: "keyfile", Path: "keyfile"}},
+				},
+			},
+		},
+		{
+			Name:         "mongo-keyfile",
+			VolumeSource: apiv1.VolumeSource{EmptyDir: &apiv1.EmptyDirVolumeSource{}},
+		},
 	}
-	return nil
 }
 
-func InstallVamp(clientset *kubernetes.Clientset, ns string, config *models.VampConfig) (*string, []byte, []byte, error) {
+func InstallVamp(clientset kubernetes.Interface, ns string, config *models.VampConfig) (*string, []byte, []byte, error) {
 	// Create Image Pull Secret
+	secretErr := CreateOrUpdateSecret(clientset, ns, vampImagePullSecret(ns, config))
+	if secretErr != nil {
+		fmt.Printf("Warning: %v\n", secretErr.Error())
+		return nil, nil, nil, secretErr
+	}
+
+	errHazelcastService := CreateOrUpdateService(clientset, ns, hazelcastService(config))
+	if errHazelcastService != nil {
+		fmt.Printf("Warning: %v\n", errHazelcastService.Error())
+		return nil, nil, nil, errHazelcastService
+	}
+	vampService := vampService(config)
+	errVampService := CreateOrUpdateService(clientset, ns, vampService)
+	if errVampService != nil {
+		fmt.Printf("Warning: %v\n", errVampService.Error())
+		return nil, nil, nil, errVampService
+	}
+
+	if config.Ingress != nil {
+		errIngress := CreateOrUpdateIngress(clientset, ns, vampIngress(config))
+		if errIngress != nil {
+			fmt.Printf("Warning: %v\n", errIngress.Error())
+			return nil, nil, nil, errIngress
+		}
+	}
+
+	endpoint, endpointError := ServiceEndpoint(clientset, ns, config)
+	if endpointError != nil {
+		return nil, nil, nil, endpointError
+	}
+	// certificates
+	certSecret, crt, key, certError := installCertificates(clientset, ns, config, endpoint.Host)
+	if certError != nil {
+		fmt.Printf("Warning: %v\n", certError.Error())
+		return nil, nil, nil, certError
+	}
+	if config.Ingress == nil {
+		endpoint.Host = externalHost(config, endpoint.Host)
+	} else if config.Ingress.SecretName != "" {
+		// the ingress terminates TLS so clients verify its certificate
+		ingressCrt, _, ingressCertError := readCertificateSecret(clientset, ns, tlsCertificateSecret(config.Ingress.SecretName))
+		if ingressCertError != nil {
+			return nil, nil, nil, ingressCertError
+		}
+		crt = ingressCrt
+	}
+	// Create Root Password Secret
+	paswordSecretErr := CreateOrUpdateSecret(clientset, ns, rootPasswordSecret(config))
+	if paswordSecretErr != nil {
+		fmt.Printf("Warning: %v\n", paswordSecretErr.Error())
+		return nil, nil, nil, paswordSecretErr
+	}
+
+	errDeployment := CreateOrUpdateDeployment(clientset, ns, vampDeployment(config, certSecret, endpoint.Address()))
+	if errDeployment != nil {
+		fmt.Printf("Warning: error during deployment - %v\n", errDeployment.Error())
+		return nil, nil, nil, errDeployment
+	}
+
+	errHPA := CreateOrUpdateHPA(clientset, config)
+	if errHPA != nil {
+		fmt.Printf("Warning: error during hpa creation - %v\n", errHPA.Error())
+		return nil, nil, nil, errHPA
+	}
+
+	url := endpoint.URL()
+	return &url, crt, key, nil
+}
+
+func certificatesSecretName(host string) string {
+	return "certificates-for-" + host
+}
+
+func certificatesSecret(config *models.VampConfig, name string, crt []byte, key []byte) *corev1.Secret {
+	secret := opaqueSecret(name, map[string][]byte{
+		"cert": crt,
+		"key":  key,
+	})
+	secret.ObjectMeta = objectMeta(config, name, nil)
+	return secret
+}
+
+func rootPasswordSecret(config *models.VampConfig) *corev1.Secret {
+	secret := opaqueSecret(NamesOf(config.ReleaseName).RootPassword, map[string][]byte{
+		"password": []byte(config.RootPassword),
+	})
+	secret.ObjectMeta = objectMeta(config, secret.Name, nil)
+	return secret
+}
+
+func vampImagePullSecret(ns string, config *models.VampConfig) *corev1.Secret {
 	dockerRepoAuth := base64.StdEncoding.EncodeToString([]byte(config.RepoUsername + ":" + config.RepoPassword))
 	pullSecretDataString := "{\"https://index.docker.io/v1/\":{\"auth\":\"" + dockerRepoAuth + "\"}}"
-	pullSecret := &corev1.Secret{
-		ObjectMeta: metav1.ObjectMeta{Name: "vampkubistimagepull", Namespace: ns},
+	meta := objectMeta(config, NamesOf(config.ReleaseName).ImagePull, nil)
+	meta.Namespace = ns
+	return &corev1.Secret{
+		ObjectMeta: meta,
 		Data: map[string][]byte{
 			".dockercfg": []byte(pullSecretDataString),
 		},
 		Type: "kubernetes.io/dockercfg",
 	}
-	secretErr := CreateOrUpdateSecret(clientset, ns, pullSecret)
-	if secretErr != nil {
-		fmt.Printf("Warning: %v\n", secretErr.Error())
-		return nil, nil, nil, secretErr
-	}
+}
 
-	hazelcastService := &apiv1.Service{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: "vamp-hazelcast",
-		},
+func hazelcastService(config *models.VampConfig) *apiv1.Service {
+	names := NamesOf(config.ReleaseName)
+	return &apiv1.Service{
+		ObjectMeta: objectMeta(config, names.Hazelcast, nil),
 		Spec: apiv1.ServiceSpec{
 			Selector: map[string]string{
-				"app": "vamp",
+				"app": names.Release,
 			},
 			Ports: []apiv1.ServicePort{
 				{
@@ -669,19 +890,16 @@ func InstallVamp(clientset *kubernetes.Clientset, ns string, config *models.Vamp
 			},
 		},
 	}
-	errHazelcastService := CreateOrUpdateService(clientset, ns, hazelcastService)
-	if errHazelcastService != nil {
-		fmt.Printf("Warning: %v\n", errHazelcastService.Error())
-		return nil, nil, nil, errHazelcastService
-	}
-	vampService := &apiv1.Service{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: "vamp",
-		},
+}
+
+func vampService(config *models.VampConfig) *apiv1.Service {
+	names := NamesOf(config.ReleaseName)
+	return &apiv1.Service{
+		ObjectMeta: objectMeta(config, names.Release, nil),
 		Spec: apiv1.ServiceSpec{
-			Type: "LoadBalancer",
+			Type: apiv1.ServiceType(serviceType(config)),
 			Selector: map[string]string{
-				"app": "vamp",
+				"app": names.Release,
 			},
 			Ports: []apiv1.ServicePort{
 				{
@@ -692,71 +910,23 @@ func InstallVamp(clientset *kubernetes.Clientset, ns string, config *models.Vamp
 			},
 		},
 	}
-	errVampService := CreateOrUpdateService(clientset, ns, vampService)
-	if errVampService != nil {
-		fmt.Printf("Warning: %v\n", errVampService.Error())
-		return nil, nil, nil, errVampService
-	}
-
-	ip, getIpError := GetServiceExternalIP(clientset, ns, vampService.GetObjectMeta().GetName())
-	if getIpError != nil {
-		return nil, nil, nil, getIpError
-	}
-	// certificates
+}
 
-	certSecretName := "certificates-for-" + ip
-	crt := []byte{}
-	key := []byte{}
-	certSecret, getCertSecretErr := GetOpaqueSecret(clientset, ns, certSecretName)
-	if getCertSecretErr != nil {
-		fmt.Printf("Warning: %v\n", getCertSecretErr.Error())
-		crtGen, keyGen, certError := cert.GenerateSelfSignedCertKey(ip, []net.IP{}, []string{})
-		if certError != nil {
-			return nil, nil, nil, certError
-		}
-		crt = crtGen
-		key = keyGen
-		certSecretError := CreateOrUpdateOpaqueSecret(clientset, ns, certSecretName,
-			map[string][]byte{
-				"cert": crt,
-				"key":  key,
-			})
-		if certSecretError != nil {
-			fmt.Printf("Warning: %v\n", certSecretError.Error())
-			return nil, nil, nil, certSecretError
-		}
-	} else {
-		crt = certSecret["cert"]
-		key = certSecret["key"]
+func vampDeployment(config *models.VampConfig, certSecret certificateSecret, externalAddress string) *appsv1.Deployment {
+	names := NamesOf(config.ReleaseName)
+	selector := map[string]string{
+		"app":        names.Release,
+		"deployment": names.Release,
 	}
-	// Create Root Password Secret
-	paswordSecretErr := CreateOrUpdateOpaqueSecret(clientset, ns, "vamprootpassword",
-		map[string][]byte{
-			"password": []byte(config.RootPassword),
-		})
-	if paswordSecretErr != nil {
-		fmt.Printf("Warning: %v\n", paswordSecretErr.Error())
-		return nil, nil, nil, paswordSecretErr
-	}
-
 	maxSurge := apiutil.FromInt(1)
 	maxUnavailable := apiutil.FromInt(0)
 
-	vampDeployment := &appsv1.Deployment{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: "vamp",
-			Labels: map[string]string{
-				"app":        "vamp",
-				"deployment": "vamp",
-			},
-		},
+	return &appsv1.Deployment{
+		ObjectMeta: objectMeta(config, names.Release, selector),
 		Spec: appsv1.DeploymentSpec{
 			Replicas: int32Ptr(3),
 			Selector: &metav1.LabelSelector{
-				MatchLabels: map[string]string{
-					"app":        "vamp",
-					"deployment": "vamp",
-				},
+				MatchLabels: selector,
 			},
 			Strategy: appsv1.DeploymentStrategy{
 				Type: appsv1.RollingUpdateDeploymentStrategyType,
@@ -767,15 +937,12 @@ func InstallVamp(clientset *kubernetes.Clientset, ns string, config *models.Vamp
 			},
 			Template: apiv1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: map[string]string{
-						"app":        "vamp",
-						"deployment": "vamp",
-					},
+					Labels: mergeLabels(config.Labels, selector),
 				},
 				Spec: apiv1.PodSpec{
 					Containers: []apiv1.Container{
 						{
-							Name:  "vamp",
+							Name:  vampContainerName,
 							Image: config.ImageName + ":" + config.ImageTag,
 							Lifecycle: &corev1.Lifecycle{
 								PreStop: &corev1.Handler{
@@ -855,10 +1022,7 @@ func InstallVamp(clientset *kubernetes.Clientset, ns string, config *models.Vamp
 									Name:  "MODE",
 									Value: config.Mode,
 								},
-								{
-									Name:  "DBURL",
-									Value: config.DatabaseUrl,
-								},
+								databaseUrlEnv(config),
 								{
 									Name:  "DBNAME",
 									Value: config.DatabaseName,
@@ -872,9 +1036,9 @@ func InstallVamp(clientset *kubernetes.Clientset, ns string, config *models.Vamp
 									ValueFrom: &apiv1.EnvVarSource{
 										SecretKeyRef: &apiv1.SecretKeySelector{
 											LocalObjectReference: apiv1.LocalObjectReference{
-												Name: certSecretName,
+												Name: certSecret.Name,
 											},
-											Key: "key",
+											Key: certSecret.KeyKey,
 										},
 									},
 								},
@@ -883,22 +1047,22 @@ func InstallVamp(clientset *kubernetes.Clientset, ns string, config *models.Vamp
 									ValueFrom: &apiv1.EnvVarSource{
 										SecretKeyRef: &apiv1.SecretKeySelector{
 											LocalObjectReference: apiv1.LocalObjectReference{
-												Name: certSecretName,
+												Name: certSecret.Name,
 											},
-											Key: "cert",
+											Key: certSecret.CertKey,
 										},
 									},
 								},
 								{
 									Name:  "API_EXTERNAL_HOST",
-									Value: ip + ":8888",
+									Value: externalAddress,
 								},
 								{
 									Name: "ROOT_PASSWORD",
 									ValueFrom: &apiv1.EnvVarSource{
 										SecretKeyRef: &apiv1.SecretKeySelector{
 											LocalObjectReference: apiv1.LocalObjectReference{
-												Name: "vamprootpassword",
+												Name: names.RootPassword,
 											},
 											Key: "password",
 										},
@@ -925,42 +1089,43 @@ func InstallVamp(clientset *kubernetes.Clientset, ns string, config *models.Vamp
 					},
 					ImagePullSecrets: []apiv1.LocalObjectReference{
 						{
-							Name: "vampkubistimagepull",
+							Name: names.ImagePull,
 						},
 					},
 				},
 			},
 		},
 	}
-	errDeployment := CreateOrUpdateDeployment(clientset, ns, vampDeployment)
-	if errDeployment != nil {
-		fmt.Printf("Warning: error during deployment - %v\n", errDeployment.Error())
-		return nil, nil, nil, errDeployment
-	}
+}
 
-	errHPA := CreateOrUpdateHPA(clientset, config)
-	if errHPA != nil {
-		fmt.Printf("Warning: error during hpa creation - %v\n", errHPA.Error())
-		return nil, nil, nil, errHPA
+func CreateOrUpdateHPA(clientset kubernetes.Interface, config *models.VampConfig) error {
+	hpa := vampHPA(config)
+	ns := installationNamespace(config)
+	_, err := clientset.AutoscalingV2beta1().HorizontalPodAutoscalers(ns).Create(hpa)
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
+		err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			_, updateErr := clientset.AutoscalingV2beta1().HorizontalPodAutoscalers(ns).Update(hpa)
+			return updateErr
+		})
+		if err != nil {
+			panic(fmt.Errorf("Updating HPA failed: %v", err))
+		}
 	}
-
-	url := "https://" + ip + ":8888"
-	return &url, crt, key, nil
+	return err
 }
 
-func CreateOrUpdateHPA(clientset *kubernetes.Clientset, config *models.VampConfig) error {
-	hpa := &autoscalingv2beta1.HorizontalPodAutoscaler{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: "vamp",
-			Labels: map[string]string{
-				"app":        "vamp",
-				"deployment": "vamp",
-			},
-		},
+func vampHPA(config *models.VampConfig) *autoscalingv2beta1.HorizontalPodAutoscaler {
+	names := NamesOf(config.ReleaseName)
+	return &autoscalingv2beta1.HorizontalPodAutoscaler{
+		ObjectMeta: objectMeta(config, names.Release, map[string]string{
+			"app":        names.Release,
+			"deployment": names.Release,
+		}),
 		Spec: autoscalingv2beta1.HorizontalPodAutoscalerSpec{
 			ScaleTargetRef: autoscalingv2beta1.CrossVersionObjectReference{
 				Kind:       "Deployment",
-				Name:       "vamp",
+				Name:       names.Release,
 				APIVersion: "extensions/v1beta1",
 			},
 			MinReplicas: config.MinReplicas,
@@ -997,21 +1162,9 @@ func CreateOrUpdateHPA(clientset *kubernetes.Clientset, config *models.VampConfi
 			},
 		},
 	}
-	_, err := clientset.AutoscalingV2beta1().HorizontalPodAutoscalers(InstallationNamespace).Create(hpa)
-	if err != nil {
-		fmt.Printf("Warning: %v\n", err)
-		err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
-			_, updateErr := clientset.AutoscalingV2beta1().HorizontalPodAutoscalers(InstallationNamespace).Update(hpa)
-			return updateErr
-		})
-		if err != nil {
-			panic(fmt.Errorf("Updating HPA failed: %v", err))
-		}
-	}
-	return err
 }
 
-func CreateOrUpdateDeployment(clientset *kubernetes.Clientset, ns string, deployment *appsv1.Deployment) error {
+func CreateOrUpdateDeployment(clientset kubernetes.Interface, ns string, deployment *appsv1.Deployment) error {
 	fmt.Printf("CreateOrUpdateDeployment: %v\n", deployment.GetObjectMeta().GetName())
 	deploymentsClient := clientset.AppsV1().Deployments(ns)
 	_, errDeployment := deploymentsClient.Create(deployment)
@@ -1034,7 +1187,7 @@ func CreateOrUpdateDeployment(clientset *kubernetes.Clientset, ns string, deploy
 	return nil
 }
 
-func CreateOrUpdateService(clientset *kubernetes.Clientset, ns string, service *apiv1.Service) error {
+func CreateOrUpdateService(clientset kubernetes.Interface, ns string, service *apiv1.Service) error {
 	fmt.Printf("CreateOrUpdateService: %v\n", service.GetObjectMeta().GetName())
 	servicesClient := clientset.CoreV1().Services(ns)
 	_, errService := servicesClient.Create(service)
@@ -1048,6 +1201,16 @@ func CreateOrUpdateService(clientset *kubernetes.Clientset, ns string, service *
 				panic(fmt.Errorf("Failed to get latest version of Service: %v", getErr))
 			}
 			service.Spec.ClusterIP = currentService.Spec.ClusterIP
+			// node ports are kept so endpoints of node port services don't change on updates
+			if service.Spec.Type == currentService.Spec.Type {
+				for i := range service.Spec.Ports {
+					for _, currentPort := range currentService.Spec.Ports {
+						if service.Spec.Ports[i].Port == currentPort.Port && service.Spec.Ports[i].NodePort == 0 {
+							service.Spec.Ports[i].NodePort = currentPort.NodePort
+						}
+					}
+				}
+			}
 			// TODO: increment resource version
 			service.ObjectMeta.ResourceVersion = currentService.ObjectMeta.ResourceVersion
 			_, updateErr := servicesClient.Update(service)
@@ -1060,7 +1223,7 @@ func CreateOrUpdateService(clientset *kubernetes.Clientset, ns string, service *
 	return nil
 }
 
-func GetServiceExternalIP(clientset *kubernetes.Clientset, ns string, name string) (string, error) {
+func GetServiceExternalIP(clientset kubernetes.Interface, ns string, name string) (string, error) {
 	fmt.Printf("GetServiceExternalIP: %v\n", name)
 	servicesClient := clientset.CoreV1().Services(ns)
 	count := 1
@@ -1095,7 +1258,7 @@ func GetServiceExternalIP(clientset *kubernetes.Clientset, ns string, name strin
 	return ip, nil
 }
 
-func CreateOrUpdateSecret(clientset *kubernetes.Clientset, ns string, secret *apiv1.Secret) error {
+func CreateOrUpdateSecret(clientset kubernetes.Interface, ns string, secret *apiv1.Secret) error {
 	fmt.Printf("CreateOrUpdateSecret: %v\n", secret.GetObjectMeta().GetName())
 	secretsClient := clientset.CoreV1().Secrets(ns)
 	_, err := secretsClient.Create(secret)
@@ -1118,7 +1281,7 @@ func CreateOrUpdateSecret(clientset *kubernetes.Clientset, ns string, secret *ap
 	return nil
 }
 
-func CreateOrUpdateStatefulSet(clientset *kubernetes.Clientset, ns string, statefulSet *appsv1.StatefulSet) error {
+func CreateOrUpdateStatefulSet(clientset kubernetes.Interface, ns string, statefulSet *appsv1.StatefulSet) error {
 	fmt.Printf("CreateOrUpdateStatefulSet: %v\n", statefulSet.GetObjectMeta().GetName())
 	statefulSetsClient := clientset.AppsV1().StatefulSets(ns)
 	_, err := statefulSetsClient.Create(statefulSet)
@@ -1141,16 +1304,19 @@ func CreateOrUpdateStatefulSet(clientset *kubernetes.Clientset, ns string, state
 	return nil
 }
 
-func CreateOrUpdateOpaqueSecret(clientset *kubernetes.Clientset, ns string, name string, data map[string][]byte) error {
-	secret := &corev1.Secret{
+func CreateOrUpdateOpaqueSecret(clientset kubernetes.Interface, ns string, name string, data map[string][]byte) error {
+	return CreateOrUpdateSecret(clientset, ns, opaqueSecret(name, data))
+}
+
+func opaqueSecret(name string, data map[string][]byte) *corev1.Secret {
+	return &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{Name: name},
 		Data:       data,
 		Type:       "Opaque",
 	}
-	return CreateOrUpdateSecret(clientset, ns, secret)
 }
 
-func GetOpaqueSecret(clientset *kubernetes.Clientset, ns string, name string) (map[string][]byte, error) {
+func GetOpaqueSecret(clientset kubernetes.Interface, ns string, name string) (map[string][]byte, error) {
 	secretsClient := clientset.CoreV1().Secrets(ns)
 	secret, getError := secretsClient.Get(name, metav1.GetOptions{})
 	if getError != nil {
@@ -2,28 +2,36 @@ package kubeclient
 
 import (
 	"encoding/json"
-	"errors"
 
 	"github.com/magneticio/vampkubistcli/logging"
 
-	//	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
-	//	v1 "k8s.io/api/core/v1"
 	"math"
-	"reflect"
-	"regexp"
-	"strconv"
+	"sort"
 	"time"
 
+	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+	metricsclient "k8s.io/metrics/pkg/client/clientset/versioned"
 )
 
 // PodAverageMetrics provides average CPU and memor as long as some pod's metadata
 type PodAverageMetrics struct {
-	Name   string
-	Labels map[string]string
-	CPU    float64
-	Memory float64
+	Name      string
+	Namespace string
+	Labels    map[string]string
+	CPU       float64
+	Memory    float64
+}
+
+// PodGroupMetrics provides CPU and memory of pods that have the same values of grouping labels
+type PodGroupMetrics struct {
+	Labels        map[string]string
+	Pods          int
+	SumCPU        float64
+	AverageCPU    float64
+	SumMemory     float64
+	AverageMemory float64
 }
 
 // ContainerMetrics contains container's CPU and Memory metrics
@@ -36,6 +44,7 @@ type ContainerMetrics struct {
 // PodContainersMetrics contains container's metrics for pod
 type PodContainersMetrics struct {
 	Name              string
+	Namespace         string
 	Labels            map[string]string
 	ContainersMetrics []ContainerMetrics
 }
@@ -71,7 +80,11 @@ type PodMetricsList struct {
 
 // K8sClientProvider provides interface for getting k8s client
 type K8sClientProvider interface {
-	Get(configPath string) (*kubernetes.Clientset, error)
+	Get(configPath string) (kubernetes.Interface, error)
+	// Metrics returns a client of the metrics API that is served by metrics-server
+	Metrics(configPath string) (metricsclient.Interface, error)
+	// Host returns the address of the API server that clients of configPath connect to
+	Host(configPath string) (string, error)
 }
 
 type defK8sClient struct{}
@@ -80,41 +93,85 @@ type defK8sClient struct{}
 var K8sClient K8sClientProvider = defK8sClient{}
 
 // Get returns k8s client
-func (defK8sClient) Get(configPath string) (*kubernetes.Clientset, error) {
+func (defK8sClient) Get(configPath string) (kubernetes.Interface, error) {
 	clientset, _, err := getLocalKubeClient(configPath)
 	return clientset, err
 }
 
+// Metrics returns a client of the metrics API
+func (defK8sClient) Metrics(configPath string) (metricsclient.Interface, error) {
+	config, err := getLocalKubeConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	return metricsclient.NewForConfig(config)
+}
+
+// Host returns the address of the API server
+func (defK8sClient) Host(configPath string) (string, error) {
+	config, err := getLocalKubeConfig(configPath)
+	if err != nil {
+		return "", err
+	}
+	return config.Host, nil
+}
+
+// MetricsOptions selects pods that metrics are gathered for
+type MetricsOptions struct {
+	Namespace string
+	// LabelSelector is passed to the metrics API and to the pod list, e.g. app=shop,version!=v1
+	LabelSelector string
+	// AllNamespaces gathers metrics of pods in every namespace, Namespace is ignored
+	AllNamespaces bool
+}
+
+func (options MetricsOptions) namespace() string {
+	if options.AllNamespaces {
+		return metav1.NamespaceAll
+	}
+	return options.Namespace
+}
+
 // GetRawMetrics returns list of metrics for a given namespace
 func GetRawMetrics(configPath string, namespace string, pods *PodMetricsList) error {
-	clientset, err := K8sClient.Get(configPath)
+	return GetRawMetricsWithOptions(configPath, MetricsOptions{Namespace: namespace}, pods)
+}
+
+// GetRawMetricsWithOptions returns list of metrics for pods selected by options
+func GetRawMetricsWithOptions(configPath string, options MetricsOptions, pods *PodMetricsList) error {
+	metricsClient, err := K8sClient.Metrics(configPath)
 	if err != nil {
 		return err
 	}
-	data, err := clientset.RESTClient().Get().AbsPath("apis/metrics.k8s.io/v1beta1/namespaces/" + namespace + "/pods").DoRaw()
+	list, err := metricsClient.MetricsV1beta1().PodMetricses(options.namespace()).List(metav1.ListOptions{LabelSelector: options.LabelSelector})
 	if err != nil {
 		return err
 	}
-	// TODO: decide how to log these logging.Info("----raw json: %v", string(data))
-	err = json.Unmarshal(data, &pods)
+	// PodMetricsList keeps usage as it is formatted by the metrics API, so the typed list is converted through json
+	data, err := json.Marshal(list)
 	if err != nil {
 		return err
 	}
-	return nil
+	return json.Unmarshal(data, pods)
 }
 
 // GetProcessedMetrics extracts metrics from k8s using GetRawMetrics and then populates them with labels and
 // float metrics that are converted from raw metrics' string values
 func GetProcessedMetrics(configPath string, namespace string, pods *PodMetricsList) error {
-	if err := GetRawMetrics(configPath, namespace, pods); err != nil {
+	return GetProcessedMetricsWithOptions(configPath, MetricsOptions{Namespace: namespace}, pods)
+}
+
+// GetProcessedMetricsWithOptions is GetProcessedMetrics for pods selected by options
+func GetProcessedMetricsWithOptions(configPath string, options MetricsOptions, pods *PodMetricsList) error {
+	if err := GetRawMetricsWithOptions(configPath, options, pods); err != nil {
 		return err
 	}
 
-	if err := GetLabels(configPath, namespace, pods); err != nil {
+	if err := GetLabelsWithOptions(configPath, options, pods); err != nil {
 		return err
 	}
 
-	ProcessMetrics(&pods)
+	ProcessMetrics(pods)
 
 	return nil
 }
@@ -122,13 +179,18 @@ func GetProcessedMetrics(configPath string, namespace string, pods *PodMetricsLi
 // GetSimpleMetrics extracts metrics from k8s using GetRawMetrics and then transform them to
 // new structure with labels and float metrics that are converted from raw metrics' string values
 func GetSimpleMetrics(configPath string, namespace string) ([]PodContainersMetrics, error) {
+	return GetSimpleMetricsWithOptions(configPath, MetricsOptions{Namespace: namespace})
+}
+
+// GetSimpleMetricsWithOptions is GetSimpleMetrics for pods selected by options
+func GetSimpleMetricsWithOptions(configPath string, options MetricsOptions) ([]PodContainersMetrics, error) {
 	var pods PodMetricsList
 
-	if err := GetRawMetrics(configPath, namespace, &pods); err != nil {
+	if err := GetRawMetricsWithOptions(configPath, options, &pods); err != nil {
 		return nil, err
 	}
 
-	if err := GetLabels(configPath, namespace, &pods); err != nil {
+	if err := GetLabelsWithOptions(configPath, options, &pods); err != nil {
 		return nil, err
 	}
 
@@ -136,6 +198,7 @@ func GetSimpleMetrics(configPath string, namespace string) ([]PodContainersMetri
 
 	for i := range pods.Items {
 		res[i].Name = pods.Items[i].Metadata.Name
+		res[i].Namespace = pods.Items[i].Metadata.Namespace
 		res[i].Labels = pods.Items[i].Metadata.Labels
 		res[i].ContainersMetrics = make([]ContainerMetrics, len(pods.Items[i].Containers))
 		for j := range pods.Items[i].Containers {
@@ -160,33 +223,69 @@ func GetSimpleMetrics(configPath string, namespace string) ([]PodContainersMetri
 // GetAverageMetrics extract metrics from k8s using GetRawMetrics and then transforms them to
 // new structure with labels and average CPU and memory per pod
 func GetAverageMetrics(configPath string, namespace string) ([]PodAverageMetrics, error) {
+	return GetAverageMetricsWithOptions(configPath, MetricsOptions{Namespace: namespace})
+}
+
+// GetAverageMetricsWithOptions is GetAverageMetrics for pods selected by options
+func GetAverageMetricsWithOptions(configPath string, options MetricsOptions) ([]PodAverageMetrics, error) {
 	var pods PodMetricsList
 
-	if err := GetRawMetrics(configPath, namespace, &pods); err != nil {
+	if err := GetRawMetricsWithOptions(configPath, options, &pods); err != nil {
 		return nil, err
 	}
 
-	if err := GetLabels(configPath, namespace, &pods); err != nil {
+	if err := GetLabelsWithOptions(configPath, options, &pods); err != nil {
 		return nil, err
 	}
 
 	return CalculateAverageMetrics(&pods)
 }
 
+// GetGroupMetrics extracts average metrics of pods selected by options and aggregates them per label group
+func GetGroupMetrics(configPath string, options MetricsOptions, labels []string) ([]PodGroupMetrics, error) {
+	metrics, err := GetAverageMetricsWithOptions(configPath, options)
+	if err != nil {
+		return nil, err
+	}
+	return GroupMetrics(metrics, labels), nil
+}
+
 // GetLabels populates pod metrics list with labels
 func GetLabels(configPath string, namespace string, pods *PodMetricsList) error {
+	return GetLabelsWithOptions(configPath, MetricsOptions{Namespace: namespace}, pods)
+}
+
+// GetLabelsWithOptions populates pod metrics list with labels of pods listed in a single call
+func GetLabelsWithOptions(configPath string, options MetricsOptions, pods *PodMetricsList) error {
+	missing := false
+	for i := range pods.Items {
+		missing = missing || len(pods.Items[i].Metadata.Labels) == 0
+	}
+	if !missing {
+		return nil
+	}
+
 	clientset, err := K8sClient.Get(configPath)
 	if err != nil {
 		return err
 	}
 
+	specs, err := listPods(clientset, options)
+	if err != nil {
+		return err
+	}
+
 	for i := range pods.Items {
 		if len(pods.Items[i].Metadata.Labels) == 0 {
-			// TODO: decide how to log these logging.Info("----getting labels for %v", pods.Items[i].Metadata.Name)
-			pod, err := clientset.CoreV1().Pods(namespace).Get(pods.Items[i].Metadata.Name, metav1.GetOptions{})
-			// TODO: decide how to log these logging.Info("----got pod data: %v", pod)
-			if err != nil {
-				return err
+			podNamespace := pods.Items[i].Metadata.Namespace
+			if podNamespace == "" {
+				podNamespace = options.Namespace
+			}
+			pod, ok := specs[podNamespace+"/"+pods.Items[i].Metadata.Name]
+			if !ok {
+				// the pod can be deleted after metrics are read
+				logging.Info("Pod %v is not found in namespace %v", pods.Items[i].Metadata.Name, podNamespace)
+				continue
 			}
 			pods.Items[i].Metadata.Labels = pod.Labels
 		}
@@ -195,11 +294,25 @@ func GetLabels(configPath string, namespace string, pods *PodMetricsList) error
 	return nil
 }
 
+// listPods lists pods selected by options in a single call and maps them by namespace/name
+func listPods(clientset kubernetes.Interface, options MetricsOptions) (map[string]v1.Pod, error) {
+	list, err := clientset.CoreV1().Pods(options.namespace()).List(metav1.ListOptions{LabelSelector: options.LabelSelector})
+	if err != nil {
+		return nil, err
+	}
+	pods := make(map[string]v1.Pod, len(list.Items))
+	for _, pod := range list.Items {
+		pods[pod.Namespace+"/"+pod.Name] = pod
+	}
+	return pods, nil
+}
+
 // CalculateAverageMetrics calculates average CPU and Memory for all containers in pod
 func CalculateAverageMetrics(pods *PodMetricsList) ([]PodAverageMetrics, error) {
 	var res = make([]PodAverageMetrics, len(pods.Items))
 	for i := range pods.Items {
 		res[i].Name = pods.Items[i].Metadata.Name
+		res[i].Namespace = pods.Items[i].Metadata.Namespace
 		res[i].Labels = pods.Items[i].Metadata.Labels
 		var sumCPU, sumMem float64
 		var cntCPU, cntMem int
@@ -225,98 +338,71 @@ func CalculateAverageMetrics(pods *PodMetricsList) ([]PodAverageMetrics, error)
 	return res, nil
 }
 
-// ProcessMetrics converts string values for CPU and memory to float ones and stores them into dedicated fields
-func ProcessMetrics(m interface{}) {
-	if reflect.ValueOf(m).Kind() != reflect.Ptr {
-		return
-	}
-
-	v := reflect.ValueOf(m)
-	for v.Kind() == reflect.Ptr {
-		v = v.Elem()
+// GroupMetrics sums and averages CPU and memory of pods per values of the given labels, a missing label has an empty value
+func GroupMetrics(metrics []PodAverageMetrics, labels []string) []PodGroupMetrics {
+	res := []PodGroupMetrics{}
+	index := make(map[string]int)
+	for _, m := range metrics {
+		groupLabels := make(map[string]string, len(labels))
+		key := ""
+		for _, label := range labels {
+			groupLabels[label] = m.Labels[label]
+			key += label + "=" + m.Labels[label] + ","
+		}
+		i, ok := index[key]
+		if !ok {
+			i = len(res)
+			index[key] = i
+			res = append(res, PodGroupMetrics{Labels: groupLabels})
+		}
+		res[i].Pods++
+		if !math.IsNaN(m.CPU) {
+			res[i].SumCPU += m.CPU
+		}
+		if !math.IsNaN(m.Memory) {
+			res[i].SumMemory += m.Memory
+		}
 	}
-
-	if v.Kind() != reflect.Struct {
-		return
+	for i := range res {
+		res[i].AverageCPU = res[i].SumCPU / float64(res[i].Pods)
+		res[i].AverageMemory = res[i].SumMemory / float64(res[i].Pods)
 	}
-
-	for i := 0; i < v.NumField(); i++ {
-		switch f := v.Field(i); f.Kind() {
-		case reflect.Struct:
-			ProcessMetrics(f.Addr().Interface())
-		case reflect.Slice:
-			for j := 0; j < f.Len(); j++ {
-				ProcessMetrics(f.Index(j).Addr().Interface())
+	sort.Slice(res, func(i, j int) bool {
+		for _, label := range labels {
+			if res[i].Labels[label] != res[j].Labels[label] {
+				return res[i].Labels[label] < res[j].Labels[label]
 			}
-		default:
-			if json, ok := v.Type().Field(i).Tag.Lookup("json"); ok {
-				logging.Info("----processing field with json tag %v", json)
-				switch json {
-				case "cpu":
-					cpu, err := ConvertCPU(f.String())
-					processField(&v, "CPUf", cpu, err)
-				case "memory":
-					mem, err := ConvertMemory(f.String())
-					processField(&v, "MemoryF", mem, err)
-				}
-			}
-
 		}
-	}
+		return false
+	})
+	return res
 }
 
-func processField(s *reflect.Value, fieldName string, val float64, err error) {
-	if err == nil {
-		f := s.FieldByName(fieldName)
-		if f.CanSet() {
-			f.SetFloat(val)
-		} else {
-			logging.Error("cannot find field %v for storing processed data", fieldName)
+// ProcessMetrics converts string values for CPU in cores and memory in bytes to float ones and stores them into dedicated fields
+func ProcessMetrics(pods *PodMetricsList) {
+	for i := range pods.Items {
+		for j := range pods.Items[i].Containers {
+			usage := &pods.Items[i].Containers[j].Usage
+			if cpu, err := ConvertCPU(usage.CPU); err == nil {
+				usage.CPUf = cpu
+			} else {
+				logging.Error("Conversion of CPU for %v failed - %v", pods.Items[i].Metadata.Name, err)
+			}
+			if mem, err := ConvertMemory(usage.Memory); err == nil {
+				usage.MemoryF = mem
+			} else {
+				logging.Error("Conversion of Memory for %v failed - %v", pods.Items[i].Metadata.Name, err)
+			}
 		}
-	} else {
-		logging.Error("cannot process field %v - %v", fieldName, err)
 	}
 }
 
+// ConvertCPU converts a CPU quantity like 250m or 24946926n to cores
 func ConvertCPU(cpu string) (float64, error) {
-	units := map[string]string{
-		"m": "e-3",
-		"u": "e-6",
-		"n": "e-9"}
-	unit := cpu[len(cpu)-1:]
-	if u, ok := units[unit]; ok {
-		cpu = cpu[0:len(cpu)-1] + u
-	}
-	return strconv.ParseFloat(cpu, 64)
+	return Cores.Parse(cpu)
 }
 
+// ConvertMemory converts a memory quantity like 512Mi or 1G to bytes
 func ConvertMemory(mem string) (float64, error) {
-	units := map[string]float64{
-		"E":  1e18,
-		"P":  1e15,
-		"T":  1e12,
-		"G":  1e9,
-		"M":  1e6,
-		"K":  1e3,
-		"Ei": math.Pow(1024, 6),
-		"Pi": math.Pow(1024, 5),
-		"Ti": math.Pow(1024, 4),
-		"Gi": math.Pow(1024, 3),
-		"Mi": math.Pow(1024, 2),
-		"Ki": 1024,
-	}
-	re := regexp.MustCompile("([0-9]+)(.*)")
-	res := re.FindAllStringSubmatch(mem, -1)
-	if len(res) == 0 || len(res[0]) < 3 {
-		return 0, errors.New("cannot parse memory")
-	}
-	f1, err := strconv.ParseFloat(res[0][1], 64)
-	if err != nil {
-		return 0, errors.New("cannot parse digit part of memory")
-	}
-	f2, ok := units[res[0][2]]
-	if !ok {
-		f2 = 1
-	}
-	return f1 * f2, nil
+	return Bytes.Parse(mem)
 }
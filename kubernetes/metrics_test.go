@@ -1,64 +1,100 @@
 package kubeclient_test
 
 import (
-	kubeclient "github.com/magneticio/vampkubistcli/kubernetes"
+	"encoding/json"
 	"io/ioutil"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/rest"
-	"net/http"
-	"net/http/httptest"
-	"strings"
+	"reflect"
 	"testing"
+
+	kubeclient "github.com/magneticio/vampkubistcli/kubernetes"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	metricsfake "k8s.io/metrics/pkg/client/clientset/versioned/fake"
 )
 
-func createTestServer(fn func(w http.ResponseWriter, r *http.Request)) *httptest.Server {
-	return httptest.NewServer(http.HandlerFunc(fn))
+func readJSON(t *testing.T, fileName string, object interface{}) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		t.Fatalf("Cannot read %v - %v", fileName, err)
+	}
+	if err := json.Unmarshal(data, object); err != nil {
+		t.Fatalf("Cannot parse %v - %v", fileName, err)
+	}
 }
 
-type k8sClientProviderMock struct {
-	Host string
-}
+/*
+CreateMockedK8s serves pods and nodes of the test files with a fake clientset and their metrics with a fake metrics clientset.
+Metrics are listed by reactors since the fake metrics clientset doesn't find objects that are added to its tracker.
+With pod labels the metrics have the labels of their pods like metrics-server sets them, otherwise labels are read from pods.
+*/
+func CreateMockedK8s(t *testing.T, metricsFileName string, podLabels bool) (*fake.Clientset, *metricsfake.Clientset) {
+	var pods corev1.PodList
+	readJSON(t, "pods_test.json", &pods)
+	var nodes corev1.NodeList
+	readJSON(t, "nodes_test.json", &nodes)
+	var podMetrics metricsv1beta1.PodMetricsList
+	readJSON(t, metricsFileName, &podMetrics)
+	var nodeMetrics metricsv1beta1.NodeMetricsList
+	readJSON(t, "node_metrics_test.json", &nodeMetrics)
 
-func (mock k8sClientProviderMock) Get(configPath string) (*kubernetes.Clientset, error) {
-	cfg := rest.Config{
-		Host: mock.Host,
+	objects := []runtime.Object{}
+	labels := map[string]map[string]string{}
+	for i := range pods.Items {
+		objects = append(objects, &pods.Items[i])
+		labels[pods.Items[i].Name] = pods.Items[i].Labels
 	}
-	return kubernetes.NewForConfig(&cfg)
-}
-
-func CreateMockedK8s(t *testing.T, metricsFileName string) *httptest.Server {
-	metricsJS, err := ioutil.ReadFile(metricsFileName)
-	if err != nil {
-		t.Errorf("Cannot read metrics json file - %v", err)
+	for i := range nodes.Items {
+		objects = append(objects, &nodes.Items[i])
 	}
+	clientset := newFakeClientset(objects...)
 
-	podJS, err := ioutil.ReadFile("pod_test.json")
-	if err != nil {
-		t.Errorf("Cannot read pod json file - %v", err)
-	}
-
-	ts := createTestServer(func(w http.ResponseWriter, r *http.Request) {
-		t.Logf("Method: %v", r.Method)
-		t.Logf("Path: %v", r.URL.Path)
-		switch {
-		case r.URL.Path == "/apis/metrics.k8s.io/v1beta1/namespaces/vamp-system/pods":
-			w.Header().Set("Content-Type", "application/json")
-			_, _ = w.Write(metricsJS)
-		case strings.HasPrefix(r.URL.Path, "/api/v1/namespaces/vamp-system/pods/"):
-			w.Header().Set("Content-Type", "application/json")
-			_, _ = w.Write(podJS)
-		default:
+	metrics := metricsfake.NewSimpleClientset()
+	metrics.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		list := &metricsv1beta1.PodMetricsList{}
+		for _, item := range podMetrics.Items {
+			if action.GetNamespace() != "" && item.Namespace != action.GetNamespace() {
+				continue
+			}
+			if podLabels {
+				item.Labels = labels[item.Name]
+			}
+			list.Items = append(list.Items, item)
 		}
+		return true, list, nil
+	})
+	metrics.PrependReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nodeMetrics.DeepCopy(), nil
 	})
 
-	kubeclient.K8sClient = k8sClientProviderMock{Host: ts.URL}
+	kubeclient.K8sClient = fakeK8sClientProvider{clientset: clientset, metrics: metrics}
+	return clientset, metrics
+}
 
-	return ts
+// listRequests returns paths with label selectors of list actions
+func listRequests(actions []k8stesting.Action) []string {
+	requests := []string{}
+	for _, action := range actions {
+		list, ok := action.(k8stesting.ListAction)
+		if !ok {
+			continue
+		}
+		request := list.GetResource().Resource
+		if list.GetNamespace() != "" {
+			request = "namespaces/" + list.GetNamespace() + "/" + request
+		}
+		if selector := list.GetListRestrictions().Labels.String(); selector != "" {
+			request += "?labelSelector=" + selector
+		}
+		requests = append(requests, request)
+	}
+	return requests
 }
 
 func TestGetProcessedMetrics(t *testing.T) {
-	ts := CreateMockedK8s(t, "metrics_test.json")
-	defer ts.Close()
+	CreateMockedK8s(t, "metrics_test.json", false)
 
 	var pods kubeclient.PodMetricsList
 
@@ -84,8 +120,7 @@ func TestGetProcessedMetrics(t *testing.T) {
 }
 
 func TestGetSimpleMetrics(t *testing.T) {
-	ts := CreateMockedK8s(t, "metrics_test.json")
-	defer ts.Close()
+	CreateMockedK8s(t, "metrics_test.json", false)
 
 	metrics, err := kubeclient.GetSimpleMetrics("", "vamp-system")
 
@@ -133,8 +168,7 @@ func TestGetSimpleMetrics(t *testing.T) {
 }
 
 func TestGetAverageMetrics(t *testing.T) {
-	ts := CreateMockedK8s(t, "metrics_test.json")
-	defer ts.Close()
+	CreateMockedK8s(t, "metrics_test.json", false)
 
 	metrics, err := kubeclient.GetAverageMetrics("", "vamp-system")
 	switch {
@@ -180,17 +214,24 @@ func TestGetAverageMetrics(t *testing.T) {
 	}
 }
 
-func TestGetAverageBadMetrics(t *testing.T) {
-	ts := CreateMockedK8s(t, "metrics_test_bad.json")
-	defer ts.Close()
+func TestCalculateAverageBadMetrics(t *testing.T) {
+	// clients of the metrics API can't decode bad quantities, so they are only calculated from the json of the metrics
+	data, err := ioutil.ReadFile("metrics_test_bad.json")
+	if err != nil {
+		t.Fatalf("Cannot read metrics json file - %v", err)
+	}
+	var pods kubeclient.PodMetricsList
+	if err := json.Unmarshal(data, &pods); err != nil {
+		t.Fatalf("Cannot parse metrics json file - %v", err)
+	}
 
-	metrics, err := kubeclient.GetAverageMetrics("", "vamp-system")
+	metrics, err := kubeclient.CalculateAverageMetrics(&pods)
 
 	switch {
 	case err != nil:
-		t.Errorf("GetAverageMetrics returned error: %v", err)
+		t.Errorf("CalculateAverageMetrics returned error: %v", err)
 	case len(metrics) == 0:
-		t.Error("GetAverageMetrics should return data")
+		t.Error("CalculateAverageMetrics should return data")
 	default:
 		t.Logf("--metrics: \n%v", metrics)
 	}
@@ -213,3 +254,71 @@ func TestGetAverageBadMetrics(t *testing.T) {
 		t.Errorf(`Metrics json file doesn't contain data of "%v" pod`, badPodName)
 	}
 }
+
+func TestGetAverageMetricsWithOptions(t *testing.T) {
+	clientset, metricsClient := CreateMockedK8s(t, "metrics_test.json", true)
+
+	metrics, err := kubeclient.GetAverageMetricsWithOptions("", kubeclient.MetricsOptions{Namespace: "vamp-system", LabelSelector: "app=vamp"})
+	if err != nil {
+		t.Errorf("GetAverageMetricsWithOptions returned error: %v", err)
+	}
+	if len(metrics) != 3 {
+		t.Errorf("Expected 3 pods, got %v", len(metrics))
+	}
+	expectedRequests := []string{"namespaces/vamp-system/pods?labelSelector=app=vamp"}
+	if requests := listRequests(metricsClient.Actions()); !reflect.DeepEqual(requests, expectedRequests) {
+		t.Errorf("Expected requests %v, got %v", expectedRequests, requests)
+	}
+	// pods are not listed when metrics have labels
+	if requests := listRequests(clientset.Actions()); len(requests) != 0 {
+		t.Errorf("Expected no requests, got %v", requests)
+	}
+	for _, m := range metrics {
+		if len(m.Labels) == 0 || m.Namespace != "vamp-system" {
+			t.Errorf("Labels and namespace should be set in %v", m)
+		}
+	}
+}
+
+func TestGetGroupMetricsAllNamespaces(t *testing.T) {
+	clientset, metricsClient := CreateMockedK8s(t, "metrics_test.json", false)
+
+	groups, err := kubeclient.GetGroupMetrics("", kubeclient.MetricsOptions{AllNamespaces: true}, []string{"app"})
+	if err != nil {
+		t.Errorf("GetGroupMetrics returned error: %v", err)
+	}
+	expectedRequests := []string{"pods", "pods"}
+	if requests := append(listRequests(metricsClient.Actions()), listRequests(clientset.Actions())...); !reflect.DeepEqual(requests, expectedRequests) {
+		t.Errorf("Expected requests %v, got %v", expectedRequests, requests)
+	}
+	if len(groups) != 2 {
+		t.Fatalf("Expected 2 groups, got %v", groups)
+	}
+	if groups[0].Labels["app"] != "vamp" || groups[0].Pods != 3 {
+		t.Errorf("Unexpected group %v", groups[0])
+	}
+	if groups[1].Labels["app"] != "vamp-mongodb" || groups[1].Pods != 3 {
+		t.Errorf("Unexpected group %v", groups[1])
+	}
+	if groups[0].AverageCPU != groups[0].SumCPU/3 {
+		t.Errorf("Average CPU isn't correct in %v", groups[0])
+	}
+}
+
+func TestGroupMetrics(t *testing.T) {
+	metrics := []kubeclient.PodAverageMetrics{
+		{Name: "a", Labels: map[string]string{"app": "shop", "version": "v1"}, CPU: 1, Memory: 10},
+		{Name: "b", Labels: map[string]string{"app": "shop", "version": "v2"}, CPU: 2, Memory: 20},
+		{Name: "c", Labels: map[string]string{"app": "shop", "version": "v1"}, CPU: 3, Memory: 30},
+		{Name: "d", Labels: map[string]string{}, CPU: 4, Memory: 40},
+	}
+	groups := kubeclient.GroupMetrics(metrics, []string{"app", "version"})
+	expected := []kubeclient.PodGroupMetrics{
+		{Labels: map[string]string{"app": "", "version": ""}, Pods: 1, SumCPU: 4, AverageCPU: 4, SumMemory: 40, AverageMemory: 40},
+		{Labels: map[string]string{"app": "shop", "version": "v1"}, Pods: 2, SumCPU: 4, AverageCPU: 2, SumMemory: 40, AverageMemory: 20},
+		{Labels: map[string]string{"app": "shop", "version": "v2"}, Pods: 1, SumCPU: 2, AverageCPU: 2, SumMemory: 20, AverageMemory: 20},
+	}
+	if !reflect.DeepEqual(groups, expected) {
+		t.Errorf("Expected %v, got %v", expected, groups)
+	}
+}
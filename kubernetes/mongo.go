@@ -0,0 +1,223 @@
+package kubeclient
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/magneticio/vampkubistcli/models"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DefaultMongoConfig is the internal MongoDB that is installed if no database url is configured
+var DefaultMongoConfig = models.MongoConfig{
+	Replicas:     int32Ptr(3),
+	Image:        "mongo:4.0",
+	SidecarImage: "cvallance/mongo-k8s-sidecar",
+	StorageClass: "standard",
+	StorageSize:  "1Gi",
+}
+
+// mongoKeyFilePath is where mongod reads the key that replicas authenticate each other with
+const mongoKeyFilePath = "/etc/mongo-keyfile/keyfile"
+
+// ValidateMongoConfig checks the replicas and quantities of the internal MongoDB
+func ValidateMongoConfig(config *models.MongoConfig) error {
+	if config == nil {
+		return nil
+	}
+	if config.Replicas != nil && *config.Replicas < 1 {
+		return fmt.Errorf("Mongo replicas %v should be at least 1", *config.Replicas)
+	}
+	for name, value := range map[string]string{
+		"storage size":   config.StorageSize,
+		"request CPU":    config.RequestCPU,
+		"request memory": config.RequestMemory,
+		"limit CPU":      config.LimitCPU,
+		"limit memory":   config.LimitMemory,
+	} {
+		if value == "" {
+			continue
+		}
+		if _, err := resource.ParseQuantity(value); err != nil {
+			return fmt.Errorf("Mongo %v %v is not valid - %v", name, value, err)
+		}
+	}
+	if config.Password != "" && config.Username == "" {
+		return errors.New("Mongo password requires a username")
+	}
+	return nil
+}
+
+// mongoConfig returns the MongoDB config with defaults for values that are not configured
+func mongoConfig(config *models.VampConfig) models.MongoConfig {
+	mongo := DefaultMongoConfig
+	if config.Mongo == nil {
+		return mongo
+	}
+	configured := *config.Mongo
+	if configured.Replicas != nil {
+		mongo.Replicas = configured.Replicas
+	}
+	if configured.Image != "" {
+		mongo.Image = configured.Image
+	}
+	if configured.SidecarImage != "" {
+		mongo.SidecarImage = configured.SidecarImage
+	}
+	if configured.StorageClass != "" {
+		mongo.StorageClass = configured.StorageClass
+	}
+	if configured.StorageSize != "" {
+		mongo.StorageSize = configured.StorageSize
+	}
+	mongo.RequestCPU = configured.RequestCPU
+	mongo.RequestMemory = configured.RequestMemory
+	mongo.LimitCPU = configured.LimitCPU
+	mongo.LimitMemory = configured.LimitMemory
+	mongo.Username = configured.Username
+	mongo.Password = configured.Password
+	return mongo
+}
+
+// mongoAuthentication is true if the internal MongoDB requires credentials
+func mongoAuthentication(config *models.VampConfig) bool {
+	return config.Mongo != nil && config.Mongo.Username != ""
+}
+
+// mongoDBUrl is the database url of the internal MongoDB of the config without credentials
+func mongoDBUrl(config *models.VampConfig) string {
+	return "mongodb://" + NamesOf(config.ReleaseName).MongoDBHosts(*mongoConfig(config).Replicas)
+}
+
+// mongoDBUrlWithCredentials is the database url of the internal MongoDB that authenticates with the root user
+func mongoDBUrlWithCredentials(config *models.VampConfig, username string, password string) string {
+	return "mongodb://" + url.UserPassword(username, password).String() + "@" +
+		NamesOf(config.ReleaseName).MongoDBHosts(*mongoConfig(config).Replicas) + "/?authSource=admin"
+}
+
+/*
+databaseUrlEnv returns the DBURL of the vamp deployment. If the internal MongoDB requires credentials
+the url is read from the credentials secret so the password isn't part of the deployment.
+*/
+func databaseUrlEnv(config *models.VampConfig) corev1.EnvVar {
+	if mongoAuthentication(config) && config.DatabaseUrl == mongoDBUrl(config) {
+		return secretEnv("DBURL", NamesOf(config.ReleaseName).MongoCredentials, "url")
+	}
+	return corev1.EnvVar{Name: "DBURL", Value: config.DatabaseUrl}
+}
+
+/*
+mongoCredentialsSecret returns the secret with the root user of the internal MongoDB, the key that replicas
+authenticate each other with and the database url with credentials.
+*/
+func mongoCredentialsSecret(config *models.VampConfig, password string, keyFile string) *corev1.Secret {
+	username := config.Mongo.Username
+	secret := opaqueSecret(NamesOf(config.ReleaseName).MongoCredentials, map[string][]byte{
+		"username": []byte(username),
+		"password": []byte(password),
+		"keyfile":  []byte(keyFile),
+		"url":      []byte(mongoDBUrlWithCredentials(config, username, password)),
+	})
+	secret.ObjectMeta = objectMeta(config, secret.Name, nil)
+	return secret
+}
+
+/*
+InstallMongoDBCredentials creates or updates the credentials secret of the internal MongoDB.
+If no password is configured, the password of an existing secret is kept since MongoDB only creates
+the root user when its volume is initialized, otherwise a password is generated.
+*/
+func InstallMongoDBCredentials(clientset kubernetes.Interface, ns string, config *models.VampConfig) error {
+	password := config.Mongo.Password
+	keyFile := ""
+	existing, err := clientset.CoreV1().Secrets(ns).Get(NamesOf(config.ReleaseName).MongoCredentials, metav1.GetOptions{})
+	if err == nil {
+		keyFile = string(existing.Data["keyfile"])
+		if password == "" {
+			password = string(existing.Data["password"])
+		}
+	} else if !k8serrors.IsNotFound(err) {
+		return err
+	}
+	if password == "" {
+		if password, err = randomHex(16); err != nil {
+			return err
+		}
+	}
+	if keyFile == "" {
+		if keyFile, err = randomKeyFile(); err != nil {
+			return err
+		}
+	}
+	return CreateOrUpdateSecret(clientset, ns, mongoCredentialsSecret(config, password, keyFile))
+}
+
+// renderMongoDBCredentials returns the credentials secret with a generated password if none is configured
+func renderMongoDBCredentials(config *models.VampConfig) (*corev1.Secret, error) {
+	password := config.Mongo.Password
+	if password == "" {
+		generated, err := randomHex(16)
+		if err != nil {
+			return nil, err
+		}
+		password = generated
+	}
+	keyFile, err := randomKeyFile()
+	if err != nil {
+		return nil, err
+	}
+	return mongoCredentialsSecret(config, password, keyFile), nil
+}
+
+func randomHex(size int) (string, error) {
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(data), nil
+}
+
+// randomKeyFile returns a key for internal authentication of replicas, keys are base64 of at most 1024 characters
+func randomKeyFile() (string, error) {
+	data := make([]byte, 756)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// resourceList returns the quantities that are configured, or nil if none are
+func resourceList(cpu string, memory string) corev1.ResourceList {
+	var res corev1.ResourceList
+	if cpu != "" {
+		res = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpu)}
+	}
+	if memory != "" {
+		if res == nil {
+			res = corev1.ResourceList{}
+		}
+		res[corev1.ResourceMemory] = resource.MustParse(memory)
+	}
+	return res
+}
+
+// secretEnv returns an environment variable that is read from a key of a secret
+func secretEnv(name string, secretName string, key string) corev1.EnvVar {
+	return corev1.EnvVar{
+		Name: name,
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+				Key:                  key,
+			},
+		},
+	}
+}
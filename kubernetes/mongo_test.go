@@ -0,0 +1,202 @@
+package kubeclient_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	kubeclient "github.com/magneticio/vampkubistcli/kubernetes"
+	"github.com/magneticio/vampkubistcli/models"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestInstallMongoDBWithConfig(t *testing.T) {
+	ns := kubeclient.InstallationNamespace
+	clientset := newFakeClientset()
+	config := tlsTestConfig(nil)
+	config.Mongo = &models.MongoConfig{
+		Replicas:     int32Ptr(1),
+		Image:        "mongo:4.0.10",
+		StorageClass: "fast",
+		StorageSize:  "10Gi",
+		LimitCPU:     "1",
+		LimitMemory:  "2Gi",
+	}
+	if err := kubeclient.InstallMongoDB(clientset, ns, &config); err != nil {
+		t.Fatalf("InstallMongoDB returned error: %v", err)
+	}
+	statefulSet, err := clientset.AppsV1().StatefulSets(ns).Get("mongo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Mongo stateful set is not created - %v", err)
+	}
+	mongo := statefulSet.Spec.Template.Spec.Containers[0]
+	if *statefulSet.Spec.Replicas != 1 || mongo.Image != "mongo:4.0.10" {
+		t.Errorf("Unexpected replicas %v and image %v", *statefulSet.Spec.Replicas, mongo.Image)
+	}
+	if mongo.Resources.Limits.Cpu().String() != "1" || mongo.Resources.Limits.Memory().String() != "2Gi" || mongo.Resources.Requests != nil {
+		t.Errorf("Unexpected resources %+v", mongo.Resources)
+	}
+	claim := statefulSet.Spec.VolumeClaimTemplates[0]
+	storage := claim.Spec.Resources.Requests[corev1.ResourceStorage]
+	if claim.Annotations["volume.beta.kubernetes.io/storage-class"] != "fast" || storage.String() != "10Gi" {
+		t.Errorf("Unexpected volume claim %+v", claim)
+	}
+	if len(mongo.Command) == 0 || mongo.Env != nil || statefulSet.Spec.Template.Spec.InitContainers != nil {
+		t.Error("Mongo without a username should not authenticate")
+	}
+	if _, err := clientset.CoreV1().Secrets(ns).Get("vamp-mongodb-credentials", metav1.GetOptions{}); err == nil {
+		t.Error("Credentials should not be created without a username")
+	}
+}
+
+func envOf(container corev1.Container, name string) *corev1.EnvVar {
+	for i := range container.Env {
+		if container.Env[i].Name == name {
+			return &container.Env[i]
+		}
+	}
+	return nil
+}
+
+func TestInstallVampServiceWithMongoCredentials(t *testing.T) {
+	ns := kubeclient.InstallationNamespace
+	clientset := newFakeClientset(
+		&corev1.ServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: ns},
+			Secrets:    []corev1.ObjectReference{{Name: "default-token-abcde"}},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "default-token-abcde", Namespace: ns},
+			Data:       map[string][]byte{"ca.crt": []byte("certificate"), "token": []byte("token")},
+		},
+	)
+	kubeclient.K8sClient = fakeK8sClientProvider{clientset: clientset}
+	config := tlsTestConfig(nil)
+	config.DatabaseUrl = ""
+	config.ServiceType = kubeclient.ServiceTypeClusterIP
+	config.Mongo = &models.MongoConfig{Replicas: int32Ptr(2), Username: "vamp"}
+
+	if _, _, _, err := kubeclient.InstallVampService(&config, ""); err != nil {
+		t.Fatalf("InstallVampService returned error: %v", err)
+	}
+	secret, err := clientset.CoreV1().Secrets(ns).Get("vamp-mongodb-credentials", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Credentials are not created - %v", err)
+	}
+	password := string(secret.Data["password"])
+	if string(secret.Data["username"]) != "vamp" || len(password) == 0 || len(secret.Data["keyfile"]) == 0 {
+		t.Errorf("Unexpected credentials %v", secret.Data)
+	}
+	expectedUrl := "mongodb://vamp:" + password + "@mongo-0.vamp-mongodb:27017,mongo-1.vamp-mongodb:27017/?authSource=admin"
+	if string(secret.Data["url"]) != expectedUrl {
+		t.Errorf("Unexpected database url %v", string(secret.Data["url"]))
+	}
+
+	deployment, err := clientset.AppsV1().Deployments(ns).Get("vamp", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbUrl := envOf(deployment.Spec.Template.Spec.Containers[0], "DBURL")
+	if dbUrl == nil || dbUrl.Value != "" || dbUrl.ValueFrom.SecretKeyRef.Name != "vamp-mongodb-credentials" || dbUrl.ValueFrom.SecretKeyRef.Key != "url" {
+		t.Errorf("Database url should be read from the credentials, got %+v", dbUrl)
+	}
+
+	statefulSet, err := clientset.AppsV1().StatefulSets(ns).Get("mongo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	podSpec := statefulSet.Spec.Template.Spec
+	mongo := podSpec.Containers[0]
+	if mongo.Command != nil || !strings.Contains(strings.Join(mongo.Args, " "), "--keyFile /etc/mongo-keyfile/keyfile") {
+		t.Errorf("Mongo should run with the entrypoint and a key file, got %v %v", mongo.Command, mongo.Args)
+	}
+	if envOf(mongo, "MONGO_INITDB_ROOT_PASSWORD") == nil || envOf(podSpec.Containers[1], "MONGODB_PASSWORD") == nil {
+		t.Error("Mongo and the sidecar should read the credentials")
+	}
+	if len(podSpec.InitContainers) != 1 || len(podSpec.Volumes) != 2 {
+		t.Errorf("Key file should be copied by an init container, got %+v", podSpec.InitContainers)
+	}
+
+	// reinstalling keeps the generated password since the root user is only created once
+	if err := kubeclient.InstallMongoDB(clientset, ns, &config); err != nil {
+		t.Fatalf("InstallMongoDB returned error: %v", err)
+	}
+	secret, _ = clientset.CoreV1().Secrets(ns).Get("vamp-mongodb-credentials", metav1.GetOptions{})
+	if string(secret.Data["password"]) != password {
+		t.Error("Password should be kept when it is reinstalled")
+	}
+}
+
+func TestValidateMongoConfig(t *testing.T) {
+	tests := []struct {
+		config *models.MongoConfig
+		valid  bool
+	}{
+		{nil, true},
+		{&models.MongoConfig{Replicas: int32Ptr(1), StorageSize: "5Gi", LimitCPU: "500m", Username: "vamp", Password: "secret"}, true},
+		{&models.MongoConfig{Replicas: int32Ptr(0)}, false},
+		{&models.MongoConfig{StorageSize: "big"}, false},
+		{&models.MongoConfig{RequestMemory: "1 Gi"}, false},
+		{&models.MongoConfig{Password: "secret"}, false},
+	}
+	for i, test := range tests {
+		err := kubeclient.ValidateMongoConfig(test.config)
+		if test.valid && err != nil {
+			t.Errorf("%v: unexpected error %v", i, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%v: expected an error", i)
+		}
+	}
+}
+
+func TestRenderVampManifestsWithMongoCredentials(t *testing.T) {
+	config := tlsTestConfig(nil)
+	config.DatabaseUrl = ""
+	config.Mongo = &models.MongoConfig{Username: "vamp", Password: "secret"}
+	manifests, _, err := kubeclient.RenderVampManifests(&config, "10.0.0.1")
+	if err != nil {
+		t.Fatalf("RenderVampManifests returned error: %v", err)
+	}
+	var secret corev1.Secret
+	var statefulSet appsv1.StatefulSet
+	for _, manifest := range manifests {
+		switch {
+		case strings.HasSuffix(manifest.FileName, "-secret-vamp-mongodb-credentials.yaml"):
+			if err := yaml.Unmarshal(manifest.Data, &secret); err != nil {
+				t.Fatal(err)
+			}
+		case strings.HasSuffix(manifest.FileName, "-statefulset-mongo.yaml"):
+			if err := yaml.Unmarshal(manifest.Data, &statefulSet); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if string(secret.Data["password"]) != "secret" || !strings.HasPrefix(string(secret.Data["url"]), "mongodb://vamp:secret@") {
+		t.Errorf("Unexpected credentials %v", secret.Data)
+	}
+	if len(statefulSet.Spec.Template.Spec.InitContainers) != 1 {
+		t.Error("Rendered mongo should authenticate")
+	}
+}
+
+func TestDatabaseJob(t *testing.T) {
+	ns := kubeclient.InstallationNamespace
+	clientset := installReadyVamp(t)
+	job, err := kubeclient.DatabaseJob(clientset, ns, "", "db-backup", "mongodump --archive")
+	if err != nil {
+		t.Fatalf("DatabaseJob returned error: %v", err)
+	}
+	container := job.Spec.Template.Spec.Containers[0]
+	if job.Name != "vamp-db-backup" || container.Image != kubeclient.DefaultMongoConfig.Image || !container.Stdin || !container.StdinOnce {
+		t.Errorf("Unexpected job %+v", job)
+	}
+	if container.Command[2] != "mongodump --archive" || envOf(container, "DBURL").Value != "mongodb://mongo-0.vamp-mongodb:27017" || envOf(container, "DBNAME").Value != "vamp" {
+		t.Errorf("Job should run with the database of the deployment, got %v %v", container.Command, container.Env)
+	}
+	if _, err := kubeclient.DatabaseJob(clientset, "missing", "", "db-backup", "mongodump"); err == nil {
+		t.Error("Job of a missing installation should fail")
+	}
+}
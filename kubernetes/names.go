@@ -0,0 +1,116 @@
+package kubeclient
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/magneticio/vampkubistcli/models"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// DefaultReleaseName is the release name of an installation if it is not configured
+const DefaultReleaseName = "vamp"
+
+// ReleaseNames are the names of objects of an installation, they are derived from the release name
+type ReleaseNames struct {
+	Release          string
+	Hazelcast        string
+	MongoDB          string
+	MongoStatefulSet string
+	RootPassword     string
+	ImagePull        string
+	MongoCredentials string
+}
+
+// NamesOf returns the object names of a release, the default release is used if the release name is empty
+func NamesOf(releaseName string) ReleaseNames {
+	if releaseName == "" {
+		releaseName = DefaultReleaseName
+	}
+	// the stateful set of the default release keeps its name so existing databases are kept on updates
+	mongoStatefulSet := releaseName + "-mongo"
+	if releaseName == DefaultReleaseName {
+		mongoStatefulSet = "mongo"
+	}
+	return ReleaseNames{
+		Release:          releaseName,
+		Hazelcast:        releaseName + "-hazelcast",
+		MongoDB:          releaseName + "-mongodb",
+		MongoStatefulSet: mongoStatefulSet,
+		RootPassword:     releaseName + "rootpassword",
+		ImagePull:        releaseName + "kubistimagepull",
+		MongoCredentials: releaseName + "-mongodb-credentials",
+	}
+}
+
+// Validate checks that every name is a valid object name
+func (names ReleaseNames) Validate() error {
+	for _, name := range []string{names.Release, names.Hazelcast, names.MongoDB, names.MongoStatefulSet, names.RootPassword, names.ImagePull, names.MongoCredentials} {
+		if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+			return fmt.Errorf("Release name %v is not valid for %v - %v", names.Release, name, strings.Join(errs, ", "))
+		}
+	}
+	return nil
+}
+
+// PodSelector selects the pods of the vamp deployment of the release
+func (names ReleaseNames) PodSelector() string {
+	return "app=" + names.Release + ",deployment=" + names.Release
+}
+
+// MongoDBHosts are the hosts of the replicas of the internal MongoDB of the release
+func (names ReleaseNames) MongoDBHosts(replicas int32) string {
+	hosts := make([]string, replicas)
+	for i := range hosts {
+		hosts[i] = fmt.Sprintf("%v-%v.%v:27017", names.MongoStatefulSet, i, names.MongoDB)
+	}
+	return strings.Join(hosts, ",")
+}
+
+// ClusterRoleBindingName is the name of the cluster role binding of the service account of a namespace
+func ClusterRoleBindingName(ns string) string {
+	return ns + "-sa-cluster-admin-binding"
+}
+
+// MinimalClusterRoleName is the name of the cluster role with the minimal rules of the service account of a namespace
+func MinimalClusterRoleName(ns string) string {
+	return ns + "-vamp-minimal"
+}
+
+// MinimalClusterRoleBindingName is the name of the binding of the minimal cluster role of a namespace
+func MinimalClusterRoleBindingName(ns string) string {
+	return ns + "-sa-vamp-minimal-binding"
+}
+
+// installationNamespace returns the namespace of the config, the default namespace is used if it is empty
+func installationNamespace(config *models.VampConfig) string {
+	if config.Namespace == "" {
+		return InstallationNamespace
+	}
+	return config.Namespace
+}
+
+// objectMeta returns metadata with the labels and annotations of the config, the given labels take precedence
+func objectMeta(config *models.VampConfig, name string, labels map[string]string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:        name,
+		Labels:      mergeLabels(config.Labels, labels),
+		Annotations: mergeLabels(config.Annotations, nil),
+	}
+}
+
+// mergeLabels returns labels of both maps, values of overrides take precedence
+func mergeLabels(labels map[string]string, overrides map[string]string) map[string]string {
+	if len(labels) == 0 && len(overrides) == 0 {
+		return nil
+	}
+	merged := make(map[string]string, len(labels)+len(overrides))
+	for k, v := range labels {
+		merged[k] = v
+	}
+	for k, v := range overrides {
+		merged[k] = v
+	}
+	return merged
+}
@@ -0,0 +1,53 @@
+package kubeclient
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+)
+
+/*
+PortForwardVamp forwards the local port to the vamp API of a running pod of the release.
+It blocks until stopChan is closed or forwarding fails, readyChan is closed when the local port accepts connections.
+*/
+func PortForwardVamp(configPath string, ns string, releaseName string, localPort int, stopChan <-chan struct{}, readyChan chan struct{}) error {
+	config, err := getLocalKubeConfig(configPath)
+	if err != nil {
+		return err
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+	podName, err := GetVampPodName(clientset, ns, releaseName)
+	if err != nil {
+		return err
+	}
+	transport, upgrader, err := spdy.RoundTripperFor(config)
+	if err != nil {
+		return err
+	}
+	req := clientset.CoreV1().RESTClient().Post().Resource("pods").Namespace(ns).Name(podName).SubResource("portforward")
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, req.URL())
+	ports := []string{fmt.Sprintf("%v:%v", localPort, VampPort)}
+	forwarder, err := portforward.New(dialer, ports, stopChan, readyChan, ioutil.Discard, os.Stderr)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Forwarding localhost:%v to pod %v\n", localPort, podName)
+	return forwarder.ForwardPorts()
+}
+
+// GetVampCertificateOfRelease returns the certificate that the vamp deployment of the release serves
+func GetVampCertificateOfRelease(configPath string, ns string, releaseName string) ([]byte, error) {
+	clientset, err := K8sClient.Get(configPath)
+	if err != nil {
+		return nil, err
+	}
+	return GetVampCertificate(clientset, ns, releaseName)
+}
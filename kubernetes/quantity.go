@@ -0,0 +1,84 @@
+package kubeclient
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+/*
+Unit converts quantities to float values in the unit.
+A quantity in base units, cores or bytes, is multiplied by 10^decimalExponent and divided by 2^binaryExponent.
+*/
+type Unit struct {
+	Name            string
+	decimalExponent int
+	binaryExponent  uint
+}
+
+// CPU units
+var (
+	Cores      = Unit{Name: "cores"}
+	Millicores = Unit{Name: "millicores", decimalExponent: 3}
+	Microcores = Unit{Name: "microcores", decimalExponent: 6}
+	Nanocores  = Unit{Name: "nanocores", decimalExponent: 9}
+)
+
+// Memory units
+var (
+	Bytes     = Unit{Name: "bytes"}
+	Kilobytes = Unit{Name: "kB", decimalExponent: -3}
+	Megabytes = Unit{Name: "MB", decimalExponent: -6}
+	Gigabytes = Unit{Name: "GB", decimalExponent: -9}
+	Kibibytes = Unit{Name: "KiB", binaryExponent: 10}
+	Mebibytes = Unit{Name: "MiB", binaryExponent: 20}
+	Gibibytes = Unit{Name: "GiB", binaryExponent: 30}
+)
+
+var units = []Unit{Cores, Millicores, Microcores, Nanocores, Bytes, Kilobytes, Megabytes, Gigabytes, Kibibytes, Mebibytes, Gibibytes}
+
+// ParseUnit returns the unit with the given name, names are case insensitive
+func ParseUnit(name string) (Unit, error) {
+	for _, unit := range units {
+		if strings.EqualFold(unit.Name, name) {
+			return unit, nil
+		}
+	}
+	names := make([]string, len(units))
+	for i, unit := range units {
+		names[i] = unit.Name
+	}
+	return Unit{}, fmt.Errorf("Unit %v is not supported, use one of %v", name, strings.Join(names, ", "))
+}
+
+/*
+Value converts a quantity to the unit.
+The decimal value of the quantity is shifted before it is parsed as float,
+so quantities like 24946926n are converted without rounding errors of multiplication.
+*/
+func (u Unit) Value(q resource.Quantity) float64 {
+	dec := q.AsDec()
+	value, err := strconv.ParseFloat(dec.String()+"e"+strconv.Itoa(u.decimalExponent), 64)
+	if err != nil {
+		// a decimal string is always parsable, out of range values are infinite
+		return math.Inf(dec.Sign())
+	}
+	return value / math.Pow(2, float64(u.binaryExponent))
+}
+
+// FromBase converts a value in base units, cores or bytes, to the unit
+func (u Unit) FromBase(value float64) float64 {
+	return value * math.Pow10(u.decimalExponent) / math.Pow(2, float64(u.binaryExponent))
+}
+
+// Parse parses a quantity like 250m, 1.5, 2e3 or 512Mi and converts it to the unit
+func (u Unit) Parse(quantity string) (float64, error) {
+	q, err := resource.ParseQuantity(strings.TrimSpace(quantity))
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse quantity %q - %v", quantity, err)
+	}
+	return u.Value(q), nil
+}
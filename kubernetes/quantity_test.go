@@ -0,0 +1,139 @@
+package kubeclient_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"testing"
+
+	kubeclient "github.com/magneticio/vampkubistcli/kubernetes"
+)
+
+func TestUnitParse(t *testing.T) {
+	tests := []struct {
+		quantity string
+		unit     kubeclient.Unit
+		expected float64
+	}{
+		{"24946926n", kubeclient.Cores, 0.024946926},
+		{"24946926n", kubeclient.Millicores, 24.946926},
+		{"24946926n", kubeclient.Nanocores, 24946926},
+		{"1500u", kubeclient.Millicores, 1.5},
+		{"250m", kubeclient.Cores, 0.25},
+		{"250m", kubeclient.Millicores, 250},
+		{"2", kubeclient.Millicores, 2000},
+		{"1.5", kubeclient.Cores, 1.5},
+		{"2e3", kubeclient.Cores, 2000},
+		{"5E-3", kubeclient.Millicores, 5},
+		{"1k", kubeclient.Bytes, 1e3},
+		{"1M", kubeclient.Kilobytes, 1e3},
+		{"3G", kubeclient.Megabytes, 3e3},
+		{"1T", kubeclient.Gigabytes, 1e3},
+		{"1P", kubeclient.Bytes, 1e15},
+		{"1E", kubeclient.Bytes, 1e18},
+		{"290716Ki", kubeclient.Bytes, 290716 * 1024},
+		{"290716Ki", kubeclient.Kibibytes, 290716},
+		{"512Mi", kubeclient.Mebibytes, 512},
+		{"512Mi", kubeclient.Gibibytes, 0.5},
+		{"2Gi", kubeclient.Mebibytes, 2048},
+		{"1Ti", kubeclient.Gibibytes, 1024},
+		{"1Pi", kubeclient.Bytes, math.Pow(1024, 5)},
+		{"1Ei", kubeclient.Bytes, math.Pow(1024, 6)},
+		{"1Gi", kubeclient.Bytes, 1 << 30},
+		{" 100Mi ", kubeclient.Mebibytes, 100},
+	}
+	for _, test := range tests {
+		value, err := test.unit.Parse(test.quantity)
+		if err != nil {
+			t.Errorf("Parse of %v returned error: %v", test.quantity, err)
+			continue
+		}
+		if value != test.expected {
+			t.Errorf("Expected %v in %v to be %v, got %v", test.quantity, test.unit.Name, test.expected, value)
+		}
+	}
+
+	for _, quantity := range []string{"", "abc", "12x", "1.5.2", "K"} {
+		if _, err := kubeclient.Bytes.Parse(quantity); err == nil {
+			t.Errorf("Parse of %q should fail", quantity)
+		}
+	}
+}
+
+func TestParseUnit(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected kubeclient.Unit
+	}{
+		{"cores", kubeclient.Cores},
+		{"Millicores", kubeclient.Millicores},
+		{"mib", kubeclient.Mebibytes},
+		{"GiB", kubeclient.Gibibytes},
+		{"MB", kubeclient.Megabytes},
+	}
+	for _, test := range tests {
+		unit, err := kubeclient.ParseUnit(test.name)
+		if err != nil {
+			t.Errorf("ParseUnit of %v returned error: %v", test.name, err)
+		} else if unit != test.expected {
+			t.Errorf("Expected unit %v, got %v", test.expected.Name, unit.Name)
+		}
+	}
+	if _, err := kubeclient.ParseUnit("furlongs"); err == nil {
+		t.Error("ParseUnit of an unknown unit should fail")
+	}
+	if value := kubeclient.Millicores.FromBase(0.25); value != 250 {
+		t.Errorf("Expected 250 millicores, got %v", value)
+	}
+	if value := kubeclient.Mebibytes.FromBase(3 * 1024 * 1024); value != 3 {
+		t.Errorf("Expected 3 MiB, got %v", value)
+	}
+}
+
+func TestProcessMetricsFixture(t *testing.T) {
+	data, err := ioutil.ReadFile("metrics_test.json")
+	if err != nil {
+		t.Fatalf("Cannot read fixture: %v", err)
+	}
+	var pods kubeclient.PodMetricsList
+	if err := json.Unmarshal(data, &pods); err != nil {
+		t.Fatalf("Cannot parse fixture: %v", err)
+	}
+	kubeclient.ProcessMetrics(&pods)
+
+	tests := []struct {
+		pod       string
+		container string
+		cpu       float64
+		memory    float64
+	}{
+		{"vamp-6dc7f8cd87-47kdw", "vamp", 0.024946926, 308068 * 1024},
+		{"vamp-6dc7f8cd87-ldj55", "vamp", 0.021391076, 290716 * 1024},
+		{"mongo-0", "mongo", 0.007261378, 60128 * 1024},
+		{"mongo-1", "mongo-sidecar", 0.00218722, 69016 * 1024},
+		{"mongo-2", "mongo-sidecar", 0.002563134, 67568 * 1024},
+	}
+	for _, test := range tests {
+		found := false
+		for _, item := range pods.Items {
+			if item.Metadata.Name != test.pod {
+				continue
+			}
+			for _, container := range item.Containers {
+				if container.Name != test.container {
+					continue
+				}
+				found = true
+				if container.Usage.CPUf != test.cpu {
+					t.Errorf("Expected cpu %v of %v/%v, got %v", test.cpu, test.pod, test.container, container.Usage.CPUf)
+				}
+				if container.Usage.MemoryF != test.memory {
+					t.Errorf("Expected memory %v of %v/%v, got %v", test.memory, test.pod, test.container, container.Usage.MemoryF)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("Container %v/%v is not found in fixture", test.pod, test.container)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+package kubeclient
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// RBACClusterAdmin binds the default service account of the namespace to cluster-admin
+	RBACClusterAdmin = "cluster-admin"
+	// RBACMinimal binds a dedicated service account to a cluster role with the permissions that vamp needs
+	RBACMinimal = "minimal"
+)
+
+// VampServiceAccount is the dedicated service account of the minimal RBAC mode
+const VampServiceAccount = "vamp"
+
+var readVerbs = []string{"get", "list", "watch"}
+var writeVerbs = []string{"get", "list", "watch", "create", "update", "patch", "delete"}
+
+// labelVerbs allow to label objects that vamp manages but doesn't create
+var labelVerbs = []string{"get", "list", "watch", "update", "patch"}
+
+// eventVerbs allow to record events, they are patched when an event repeats
+var eventVerbs = []string{"get", "list", "watch", "create", "patch"}
+
+// MinimalClusterRoleRules are the permissions that vamp needs to manage Istio and the workloads of a cluster
+var MinimalClusterRoleRules = []rbacv1.PolicyRule{
+	{APIGroups: []string{"networking.istio.io"}, Resources: []string{"virtualservices", "destinationrules", "gateways", "serviceentries"}, Verbs: writeVerbs},
+	{APIGroups: []string{"config.istio.io"}, Resources: []string{"rules", "handlers", "instances", "attributemanifests"}, Verbs: writeVerbs},
+	{APIGroups: []string{"apiextensions.k8s.io"}, Resources: []string{"customresourcedefinitions"}, Verbs: readVerbs},
+	{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: writeVerbs},
+	{APIGroups: []string{"apps"}, Resources: []string{"replicasets"}, Verbs: readVerbs},
+	{APIGroups: []string{""}, Resources: []string{"services", "configmaps", "secrets"}, Verbs: writeVerbs},
+	{APIGroups: []string{""}, Resources: []string{"namespaces"}, Verbs: labelVerbs},
+	{APIGroups: []string{""}, Resources: []string{"events"}, Verbs: eventVerbs},
+	{APIGroups: []string{""}, Resources: []string{"pods", "nodes"}, Verbs: readVerbs},
+	{APIGroups: []string{"autoscaling"}, Resources: []string{"horizontalpodautoscalers"}, Verbs: writeVerbs},
+	{APIGroups: []string{"metrics.k8s.io"}, Resources: []string{"pods", "nodes"}, Verbs: readVerbs},
+}
+
+// ServiceAccountOfRBAC returns the service account that the RBAC mode binds
+func ServiceAccountOfRBAC(rbac string) (string, error) {
+	switch rbac {
+	case RBACClusterAdmin, "":
+		return "default", nil
+	case RBACMinimal:
+		return VampServiceAccount, nil
+	}
+	return "", fmt.Errorf("RBAC mode %v is not supported, it should be %v or %v", rbac, RBACClusterAdmin, RBACMinimal)
+}
+
+// clusterRoleBindingNameOfRBAC returns the name of the cluster role binding that the RBAC mode creates
+func clusterRoleBindingNameOfRBAC(rbac string, ns string) string {
+	if rbac == RBACMinimal {
+		return MinimalClusterRoleBindingName(ns)
+	}
+	return ClusterRoleBindingName(ns)
+}
+
+// BootstrappedRBAC returns the RBAC mode that the namespace is bootstrapped with, it is found by the cluster role binding
+func BootstrappedRBAC(clientset kubernetes.Interface, ns string) (string, error) {
+	for _, rbac := range []string{RBACMinimal, RBACClusterAdmin} {
+		_, err := clientset.RbacV1().ClusterRoleBindings().Get(clusterRoleBindingNameOfRBAC(rbac, ns), metav1.GetOptions{})
+		if err == nil {
+			return rbac, nil
+		}
+		if !k8serrors.IsNotFound(err) {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("Namespace %v is not bootstrapped, neither cluster role binding %v nor %v exists",
+		ns, ClusterRoleBindingName(ns), MinimalClusterRoleBindingName(ns))
+}
+
+/*
+SetupMinimalVampCredentials creates the namespace, the dedicated service account and a cluster role
+with the minimal rules that is bound to it. Existing objects are updated.
+*/
+func SetupMinimalVampCredentials(clientset kubernetes.Interface, ns string) error {
+	fmt.Printf("SetupMinimalVampCredentials: %v\n", ns)
+	if _, err := clientset.CoreV1().Namespaces().Create(vampNamespace(ns)); err != nil && !k8serrors.IsAlreadyExists(err) {
+		return err
+	}
+	serviceAccount := vampServiceAccount(ns)
+	if _, err := clientset.CoreV1().ServiceAccounts(ns).Create(serviceAccount); err != nil && !k8serrors.IsAlreadyExists(err) {
+		return err
+	}
+	clusterRole := minimalClusterRole(ns)
+	if _, err := clientset.RbacV1().ClusterRoles().Create(clusterRole); k8serrors.IsAlreadyExists(err) {
+		_, err = clientset.RbacV1().ClusterRoles().Update(clusterRole)
+		if err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+	binding := minimalClusterRoleBinding(ns)
+	if _, err := clientset.RbacV1().ClusterRoleBindings().Create(binding); k8serrors.IsAlreadyExists(err) {
+		_, err = clientset.RbacV1().ClusterRoleBindings().Update(binding)
+		if err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+func vampServiceAccount(ns string) *corev1.ServiceAccount {
+	return &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: VampServiceAccount, Namespace: ns}}
+}
+
+func minimalClusterRole(ns string) *rbacv1.ClusterRole {
+	return &rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: MinimalClusterRoleName(ns)},
+		Rules:      MinimalClusterRoleRules,
+	}
+}
+
+func minimalClusterRoleBinding(ns string) *rbacv1.ClusterRoleBinding {
+	return &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: MinimalClusterRoleBindingName(ns)},
+		Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: VampServiceAccount, Namespace: ns}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: MinimalClusterRoleName(ns), APIGroup: rbacv1.GroupName},
+	}
+}
+
+// AuditVampServiceAccount audits the service account of the RBAC mode with the kube config
+func AuditVampServiceAccount(configPath string, ns string, rbac string) (*RBACAudit, error) {
+	serviceAccount, err := ServiceAccountOfRBAC(rbac)
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := K8sClient.Get(configPath)
+	if err != nil {
+		return nil, err
+	}
+	return AuditServiceAccount(clientset, ns, serviceAccount)
+}
+
+// Permission is a verb on a resource of an API group, or on a non-resource URL
+type Permission struct {
+	APIGroup       string `yaml:"apiGroup,omitempty" json:"apiGroup,omitempty"`
+	Resource       string `yaml:"resource,omitempty" json:"resource,omitempty"`
+	NonResourceURL string `yaml:"nonResourceURL,omitempty" json:"nonResourceURL,omitempty"`
+	Verb           string `yaml:"verb" json:"verb"`
+	// Namespace is set if the permission is only granted in a namespace
+	Namespace string `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+}
+
+func (permission Permission) String() string {
+	target := permission.NonResourceURL
+	if target == "" {
+		target = permission.Resource
+		if permission.APIGroup != "" {
+			target = permission.Resource + "." + permission.APIGroup
+		}
+	}
+	res := permission.Verb + " " + target
+	if permission.Namespace != "" {
+		res += " in namespace " + permission.Namespace
+	}
+	return res
+}
+
+// covers is true if the permission grants the other permission, wildcards match every value
+func (permission Permission) covers(other Permission) bool {
+	if permission.Namespace != "" && permission.Namespace != other.Namespace {
+		return false
+	}
+	if other.NonResourceURL != "" || permission.NonResourceURL != "" {
+		return matches(permission.NonResourceURL, other.NonResourceURL) && matches(permission.Verb, other.Verb)
+	}
+	return matches(permission.APIGroup, other.APIGroup) && matches(permission.Resource, other.Resource) && matches(permission.Verb, other.Verb)
+}
+
+func matches(pattern string, value string) bool {
+	return pattern == "*" || pattern == value ||
+		(strings.HasSuffix(pattern, "*") && strings.HasPrefix(value, strings.TrimSuffix(pattern, "*")))
+}
+
+// permissionsOf expands rules into a permission for each verb, resource and API group
+func permissionsOf(rules []rbacv1.PolicyRule, ns string) []Permission {
+	permissions := []Permission{}
+	for _, rule := range rules {
+		for _, verb := range rule.Verbs {
+			for _, url := range rule.NonResourceURLs {
+				permissions = append(permissions, Permission{NonResourceURL: url, Verb: verb, Namespace: ns})
+			}
+			for _, group := range rule.APIGroups {
+				for _, resource := range rule.Resources {
+					permissions = append(permissions, Permission{APIGroup: group, Resource: resource, Verb: verb, Namespace: ns})
+				}
+			}
+		}
+	}
+	return permissions
+}
+
+// RBACAudit compares the permissions of a service account with the minimal cluster role
+type RBACAudit struct {
+	ServiceAccount string       `yaml:"serviceAccount" json:"serviceAccount"`
+	Namespace      string       `yaml:"namespace" json:"namespace"`
+	Bindings       []string     `yaml:"bindings" json:"bindings"`
+	Effective      []Permission `yaml:"effective" json:"effective"`
+	Missing        []Permission `yaml:"missing" json:"missing"`
+	Excess         []Permission `yaml:"excess" json:"excess"`
+}
+
+// boundTo is true if a binding subject is the service account or a group that it belongs to
+func boundTo(subject rbacv1.Subject, ns string, serviceAccount string) bool {
+	switch subject.Kind {
+	case rbacv1.ServiceAccountKind:
+		return subject.Name == serviceAccount && subject.Namespace == ns
+	case rbacv1.UserKind:
+		return subject.Name == "system:serviceaccount:"+ns+":"+serviceAccount
+	case rbacv1.GroupKind:
+		return subject.Name == "system:serviceaccounts" || subject.Name == "system:serviceaccounts:"+ns
+	}
+	return false
+}
+
+/*
+AuditServiceAccount resolves the cluster role bindings and the role bindings of the namespace of a service account
+into its effective permissions. They are compared with the minimal cluster role, permissions that the minimal role
+needs but aren't granted cluster wide are missing and permissions that it doesn't need are excess.
+Roles that every authenticated user is bound to are not included.
+*/
+func AuditServiceAccount(clientset kubernetes.Interface, ns string, serviceAccount string) (*RBACAudit, error) {
+	audit := &RBACAudit{ServiceAccount: serviceAccount, Namespace: ns, Bindings: []string{}, Effective: []Permission{}}
+	clusterRoleBindings, err := clientset.RbacV1().ClusterRoleBindings().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, binding := range clusterRoleBindings.Items {
+		if !bindsServiceAccount(binding.Subjects, ns, serviceAccount) {
+			continue
+		}
+		rules, err := roleRules(clientset, "", binding.RoleRef)
+		if err != nil {
+			return nil, err
+		}
+		audit.Bindings = append(audit.Bindings, "clusterrolebinding/"+binding.Name+" to "+strings.ToLower(binding.RoleRef.Kind)+"/"+binding.RoleRef.Name)
+		audit.Effective = append(audit.Effective, permissionsOf(rules, "")...)
+	}
+	roleBindings, err := clientset.RbacV1().RoleBindings(ns).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, binding := range roleBindings.Items {
+		if !bindsServiceAccount(binding.Subjects, ns, serviceAccount) {
+			continue
+		}
+		rules, err := roleRules(clientset, ns, binding.RoleRef)
+		if err != nil {
+			return nil, err
+		}
+		audit.Bindings = append(audit.Bindings, "rolebinding/"+binding.Name+" to "+strings.ToLower(binding.RoleRef.Kind)+"/"+binding.RoleRef.Name)
+		audit.Effective = append(audit.Effective, permissionsOf(rules, ns)...)
+	}
+
+	minimal := permissionsOf(MinimalClusterRoleRules, "")
+	for _, needed := range minimal {
+		if !coveredBy(needed, audit.Effective) {
+			audit.Missing = append(audit.Missing, needed)
+		}
+	}
+	for _, granted := range audit.Effective {
+		clusterWide := granted
+		clusterWide.Namespace = ""
+		if !coveredBy(clusterWide, minimal) {
+			audit.Excess = append(audit.Excess, granted)
+		}
+	}
+	sortPermissions(audit.Effective)
+	sortPermissions(audit.Excess)
+	return audit, nil
+}
+
+func bindsServiceAccount(subjects []rbacv1.Subject, ns string, serviceAccount string) bool {
+	for _, subject := range subjects {
+		if boundTo(subject, ns, serviceAccount) {
+			return true
+		}
+	}
+	return false
+}
+
+func coveredBy(permission Permission, permissions []Permission) bool {
+	for _, granted := range permissions {
+		if granted.covers(permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// roleRules returns the rules of the cluster role or the role of the namespace that a binding refers to
+func roleRules(clientset kubernetes.Interface, ns string, ref rbacv1.RoleRef) ([]rbacv1.PolicyRule, error) {
+	if ref.Kind == "ClusterRole" {
+		role, err := clientset.RbacV1().ClusterRoles().Get(ref.Name, metav1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			fmt.Printf("Warning: cluster role %v is bound but doesn't exist\n", ref.Name)
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return role.Rules, nil
+	}
+	role, err := clientset.RbacV1().Roles(ns).Get(ref.Name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		fmt.Printf("Warning: role %v is bound but doesn't exist\n", ref.Name)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return role.Rules, nil
+}
+
+func sortPermissions(permissions []Permission) {
+	sort.SliceStable(permissions, func(i, j int) bool {
+		return permissions[i].String() < permissions[j].String()
+	})
+}
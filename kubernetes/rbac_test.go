@@ -0,0 +1,187 @@
+package kubeclient_test
+
+import (
+	"testing"
+
+	kubeclient "github.com/magneticio/vampkubistcli/kubernetes"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestBootstrapVampServiceWithMinimalRBAC(t *testing.T) {
+	ns := kubeclient.InstallationNamespace
+	clientset := newFakeClientset(
+		&corev1.ServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{Name: kubeclient.VampServiceAccount, Namespace: ns},
+			Secrets:    []corev1.ObjectReference{{Name: "vamp-token-abcde"}},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "vamp-token-abcde", Namespace: ns},
+			Data:       map[string][]byte{"ca.crt": []byte("certificate"), "token": []byte("token")},
+		},
+	)
+	kubeclient.K8sClient = fakeK8sClientProvider{clientset: clientset}
+
+	_, crt, token, err := kubeclient.BootstrapVampServiceWithOptions("", ns, kubeclient.BootstrapOptions{RBAC: kubeclient.RBACMinimal})
+	if err != nil {
+		t.Fatalf("BootstrapVampServiceWithOptions returned error: %v", err)
+	}
+	if crt != "certificate" || token != "token" {
+		t.Errorf("Credentials of the vamp service account should be returned, got %v %v", crt, token)
+	}
+	binding, err := clientset.RbacV1().ClusterRoleBindings().Get(kubeclient.MinimalClusterRoleBindingName(ns), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Minimal cluster role binding is not created - %v", err)
+	}
+	if binding.Subjects[0].Kind != "ServiceAccount" || binding.Subjects[0].Name != "vamp" || binding.RoleRef.Name != kubeclient.MinimalClusterRoleName(ns) {
+		t.Errorf("Unexpected binding %+v", binding)
+	}
+	if _, err := clientset.RbacV1().ClusterRoleBindings().Get(kubeclient.ClusterRoleBindingName(ns), metav1.GetOptions{}); err == nil {
+		t.Error("Minimal RBAC should not bind cluster-admin")
+	}
+
+	// bootstrapping again updates the role
+	if err := kubeclient.SetupMinimalVampCredentials(clientset, ns); err != nil {
+		t.Fatalf("SetupMinimalVampCredentials over existing credentials returned error: %v", err)
+	}
+	audit, err := kubeclient.AuditServiceAccount(clientset, ns, kubeclient.VampServiceAccount)
+	if err != nil {
+		t.Fatalf("AuditServiceAccount returned error: %v", err)
+	}
+	if len(audit.Bindings) != 1 || len(audit.Missing) != 0 || len(audit.Excess) != 0 {
+		t.Errorf("Minimal role should match, got bindings %v missing %v excess %v", audit.Bindings, audit.Missing, audit.Excess)
+	}
+
+	if _, _, _, err := kubeclient.BootstrapVampServiceWithOptions("", ns, kubeclient.BootstrapOptions{RBAC: "admin"}); err == nil {
+		t.Error("Unknown RBAC mode should fail")
+	}
+}
+
+func TestRefreshCredentialsAfterMinimalBootstrap(t *testing.T) {
+	ns := kubeclient.InstallationNamespace
+	clientset := newFakeClientset()
+	kubeclient.K8sClient = fakeK8sClientProvider{clientset: clientset}
+	if _, _, _, err := kubeclient.BootstrapVampServiceWithOptions("", ns, kubeclient.BootstrapOptions{RBAC: kubeclient.RBACMinimal}); err != nil {
+		t.Fatalf("BootstrapVampServiceWithOptions returned error: %v", err)
+	}
+
+	_, _, token, err := kubeclient.BootstrapVampServiceWithOptions("", ns, kubeclient.BootstrapOptions{RefreshCredentials: true})
+	if err != nil {
+		t.Fatalf("Refresh returned error: %v", err)
+	}
+	if token != "token-vamp-vamp-token" {
+		t.Errorf("Token of the vamp service account should be refreshed, got %v", token)
+	}
+	if _, err := clientset.RbacV1().ClusterRoleBindings().Get(kubeclient.ClusterRoleBindingName(ns), metav1.GetOptions{}); err == nil {
+		t.Error("Refresh should not bind cluster-admin")
+	}
+
+	if _, _, _, err := kubeclient.BootstrapVampServiceWithOptions("", ns, kubeclient.BootstrapOptions{RBAC: kubeclient.RBACClusterAdmin, RefreshCredentials: true}); err == nil {
+		t.Error("Refresh with another RBAC mode than bootstrap should fail")
+	}
+	if _, _, _, err := kubeclient.BootstrapVampServiceWithOptions("", "vamp-missing", kubeclient.BootstrapOptions{RefreshCredentials: true}); err == nil {
+		t.Error("Refresh of a namespace that is not bootstrapped should fail")
+	}
+}
+
+func TestAuditServiceAccount(t *testing.T) {
+	ns := kubeclient.InstallationNamespace
+	clientset := newFakeClientset(
+		&rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-admin"},
+			Rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}},
+				{NonResourceURLs: []string{"*"}, Verbs: []string{"*"}},
+			},
+		},
+		&rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: kubeclient.ClusterRoleBindingName(ns)},
+			Subjects:   []rbacv1.Subject{{Kind: "User", Name: "system:serviceaccount:" + ns + ":default"}},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "cluster-admin"},
+		},
+		&rbacv1.Role{
+			ObjectMeta: metav1.ObjectMeta{Name: "services", Namespace: ns},
+			Rules:      []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"services"}, Verbs: []string{"get"}}},
+		},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "services", Namespace: ns},
+			Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "limited", Namespace: ns}},
+			RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "services"},
+		},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "volumes", Namespace: ns},
+			Subjects:   []rbacv1.Subject{{Kind: "Group", Name: "system:serviceaccounts:" + ns}},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "volumes"},
+		},
+		&rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{Name: "volumes"},
+			Rules:      []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"persistentvolumeclaims"}, Verbs: []string{"list"}}},
+		},
+	)
+
+	admin, err := kubeclient.AuditServiceAccount(clientset, ns, "default")
+	if err != nil {
+		t.Fatalf("AuditServiceAccount returned error: %v", err)
+	}
+	if len(admin.Bindings) != 2 || len(admin.Missing) != 0 {
+		t.Errorf("cluster-admin should grant every permission, got bindings %v missing %v", admin.Bindings, admin.Missing)
+	}
+	if len(admin.Excess) != 3 || admin.Excess[0].String() != "* *" || admin.Excess[1].String() != "* *.*" {
+		t.Errorf("Wildcards and volumes should be excess, got %v", admin.Excess)
+	}
+
+	limited, err := kubeclient.AuditServiceAccount(clientset, ns, "limited")
+	if err != nil {
+		t.Fatalf("AuditServiceAccount returned error: %v", err)
+	}
+	if len(limited.Effective) != 2 || len(limited.Excess) != 1 || limited.Excess[0].String() != "list persistentvolumeclaims in namespace "+ns {
+		t.Errorf("Unexpected effective %v and excess %v", limited.Effective, limited.Excess)
+	}
+	for _, missing := range limited.Missing {
+		if missing.String() == "get services" {
+			return
+		}
+	}
+	t.Errorf("Services that are only granted in the namespace should be missing, got %v", limited.Missing)
+}
+
+// vampWrites are the changes that vamp makes to clusters it manages
+var vampWrites = []rbacv1.PolicyRule{
+	{APIGroups: []string{"networking.istio.io"}, Resources: []string{"virtualservices", "destinationrules", "gateways", "serviceentries"}, Verbs: []string{"create", "update", "patch", "delete"}},
+	{APIGroups: []string{"config.istio.io"}, Resources: []string{"rules", "handlers", "instances", "attributemanifests"}, Verbs: []string{"create", "update", "patch", "delete"}},
+	{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"create", "update", "patch", "delete"}},
+	{APIGroups: []string{"autoscaling"}, Resources: []string{"horizontalpodautoscalers"}, Verbs: []string{"create", "update", "patch", "delete"}},
+	// services of deployments, configuration and certificates of gateways
+	{APIGroups: []string{""}, Resources: []string{"services", "configmaps", "secrets"}, Verbs: []string{"create", "update", "patch", "delete"}},
+	// namespaces are labelled with vamp-managed but not created
+	{APIGroups: []string{""}, Resources: []string{"namespaces"}, Verbs: []string{"update", "patch"}},
+	{APIGroups: []string{""}, Resources: []string{"events"}, Verbs: []string{"create", "patch"}},
+}
+
+func TestMinimalClusterRoleRulesMatchVampWrites(t *testing.T) {
+	ns := kubeclient.InstallationNamespace
+	clientset := newFakeClientset(
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "writes"}, Rules: vampWrites},
+		&rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "writes"},
+			Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "writer", Namespace: ns}},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "writes"},
+		},
+	)
+	audit, err := kubeclient.AuditServiceAccount(clientset, ns, "writer")
+	if err != nil {
+		t.Fatalf("AuditServiceAccount returned error: %v", err)
+	}
+	// writes that the minimal role doesn't grant are excess
+	if len(audit.Excess) != 0 {
+		t.Errorf("Minimal role should grant every write of vamp, missing %v", audit.Excess)
+	}
+	for _, missing := range audit.Missing {
+		switch missing.Verb {
+		case "get", "list", "watch":
+		default:
+			t.Errorf("Minimal role should not grant writes that vamp doesn't make, got %v", missing)
+		}
+	}
+}
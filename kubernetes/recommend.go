@@ -0,0 +1,98 @@
+package kubeclient
+
+import (
+	"errors"
+	"math"
+
+	"github.com/magneticio/vampkubistcli/models"
+	"github.com/magneticio/vampkubistcli/stats"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// RecommendOptions configures how resources are recommended from observed usage
+type RecommendOptions struct {
+	// Headroom is added on top of observed usage, 0.3 means 30% more than observed
+	Headroom float64
+	// TargetUtilisation is the share of requested resources that autoscaling targets, between 0 and 1
+	TargetUtilisation float64
+}
+
+// DefaultRecommendOptions are used if no options are given
+var DefaultRecommendOptions = RecommendOptions{
+	Headroom:          0.3,
+	TargetUtilisation: 0.8,
+}
+
+// Smallest values that are recommended, usage of an idle pod can be close to zero
+const (
+	minimumRecommendedMilliCPU = 10
+	minimumRecommendedMemory   = 64 * 1024 * 1024
+)
+
+/*
+RecommendVampConfig returns a config patch with resources based on observed CPU in cores and memory in bytes of vamp pods.
+Requests are the 95th percentile and limits are the maximum of usage with headroom,
+autoscaling targets are the target utilisation of requests.
+*/
+func RecommendVampConfig(cpu []float64, memory []float64, options RecommendOptions) (*models.VampConfig, error) {
+	// usage of pods without metrics is NaN
+	cpu = withoutNaN(cpu)
+	memory = withoutNaN(memory)
+	if len(cpu) == 0 || len(memory) == 0 {
+		return nil, errors.New("No usage is observed to recommend resources from")
+	}
+	if options.Headroom < 0 {
+		return nil, errors.New("Headroom can not be negative")
+	}
+	if options.TargetUtilisation <= 0 || options.TargetUtilisation > 1 {
+		return nil, errors.New("Target utilisation should be greater than 0 and at most 1")
+	}
+	cpuUsage := stats.Describe(cpu, 0)
+	memoryUsage := stats.Describe(memory, 0)
+
+	requestMilliCPU := roundUpMilliCPU(cpuUsage.P95 * (1 + options.Headroom))
+	limitMilliCPU := maxInt64(roundUpMilliCPU(cpuUsage.Max*(1+options.Headroom)), requestMilliCPU)
+	requestMemory := roundUpMemory(memoryUsage.P95 * (1 + options.Headroom))
+	limitMemory := maxInt64(roundUpMemory(memoryUsage.Max*(1+options.Headroom)), requestMemory)
+	targetMilliCPU := roundUpMilliCPU(float64(requestMilliCPU) / 1000 * options.TargetUtilisation)
+	targetMemory := roundUpMemory(float64(requestMemory) * options.TargetUtilisation)
+
+	return &models.VampConfig{
+		RequestCPU:               resource.NewMilliQuantity(requestMilliCPU, resource.DecimalSI).String(),
+		LimitCPU:                 resource.NewMilliQuantity(limitMilliCPU, resource.DecimalSI).String(),
+		RequestMemory:            resource.NewQuantity(requestMemory, resource.BinarySI).String(),
+		LimitMemory:              resource.NewQuantity(limitMemory, resource.BinarySI).String(),
+		TargetCPUAverageValue:    resource.NewMilliQuantity(targetMilliCPU, resource.DecimalSI).String(),
+		TargetMemoryAverageValue: resource.NewQuantity(targetMemory, resource.BinarySI).String(),
+	}, nil
+}
+
+func withoutNaN(values []float64) []float64 {
+	res := make([]float64, 0, len(values))
+	for _, value := range values {
+		if !math.IsNaN(value) {
+			res = append(res, value)
+		}
+	}
+	return res
+}
+
+// roundingTolerance keeps floating point errors from rounding up exact values
+const roundingTolerance = 1e-9
+
+func roundUpMilliCPU(cores float64) int64 {
+	return maxInt64(int64(math.Ceil(cores*1000-roundingTolerance)), minimumRecommendedMilliCPU)
+}
+
+// roundUpMemory rounds bytes up to mebibytes
+func roundUpMemory(bytes float64) int64 {
+	const mebibyte = 1024 * 1024
+	return maxInt64(int64(math.Ceil(bytes/mebibyte-roundingTolerance))*mebibyte, minimumRecommendedMemory)
+}
+
+func maxInt64(a int64, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,57 @@
+package kubeclient_test
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	kubeclient "github.com/magneticio/vampkubistcli/kubernetes"
+	"github.com/magneticio/vampkubistcli/models"
+)
+
+func TestRecommendVampConfig(t *testing.T) {
+	cpu := []float64{0.1, 0.2, 0.2, 0.3, 0.4}
+	memory := []float64{200 * 1024 * 1024, 300 * 1024 * 1024, 400 * 1024 * 1024}
+	config, err := kubeclient.RecommendVampConfig(cpu, memory, kubeclient.RecommendOptions{Headroom: 0.5, TargetUtilisation: 0.8})
+	if err != nil {
+		t.Fatalf("RecommendVampConfig returned error: %v", err)
+	}
+	expected := models.VampConfig{
+		// p95 of cpu is 0.38
+		RequestCPU:               "570m",
+		LimitCPU:                 "600m",
+		RequestMemory:            "585Mi",
+		LimitMemory:              "600Mi",
+		TargetCPUAverageValue:    "456m",
+		TargetMemoryAverageValue: "468Mi",
+	}
+	if !reflect.DeepEqual(*config, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, *config)
+	}
+
+	idle, err := kubeclient.RecommendVampConfig([]float64{0}, []float64{0}, kubeclient.DefaultRecommendOptions)
+	if err != nil {
+		t.Fatalf("RecommendVampConfig returned error: %v", err)
+	}
+	if idle.RequestCPU != "10m" || idle.RequestMemory != "64Mi" {
+		t.Errorf("Idle usage should be recommended minimum values in %+v", *idle)
+	}
+
+	if _, err := kubeclient.RecommendVampConfig(nil, nil, kubeclient.DefaultRecommendOptions); err == nil {
+		t.Error("RecommendVampConfig should fail without usage")
+	}
+
+	missing, err := kubeclient.RecommendVampConfig(append(cpu, math.NaN()), append(memory, math.NaN()), kubeclient.RecommendOptions{Headroom: 0.5, TargetUtilisation: 0.8})
+	if err != nil {
+		t.Fatalf("RecommendVampConfig with missing metrics returned error: %v", err)
+	}
+	if !reflect.DeepEqual(*missing, expected) {
+		t.Errorf("Missing metrics should be ignored, expected %+v, got %+v", expected, *missing)
+	}
+	if _, err := kubeclient.RecommendVampConfig([]float64{math.NaN()}, memory, kubeclient.DefaultRecommendOptions); err == nil {
+		t.Error("RecommendVampConfig should fail if only pods without metrics are observed")
+	}
+	if _, err := kubeclient.RecommendVampConfig(cpu, memory, kubeclient.RecommendOptions{Headroom: 0.2, TargetUtilisation: 1.5}); err == nil {
+		t.Error("RecommendVampConfig should fail with target utilisation over 1")
+	}
+}
@@ -0,0 +1,152 @@
+package kubeclient
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/magneticio/vampkubistcli/stats"
+)
+
+// MetricsRecord is a sample of average metrics of pods, timestamp is in unix milliseconds
+type MetricsRecord struct {
+	Timestamp int64         `json:"t"`
+	Pods      []RecordedPod `json:"p"`
+}
+
+// RecordedPod is average CPU in cores and memory in bytes of a pod in a record
+type RecordedPod struct {
+	Name      string  `json:"n"`
+	Namespace string  `json:"ns,omitempty"`
+	CPU       float64 `json:"c"`
+	Memory    float64 `json:"m"`
+}
+
+// RecordWriter writes records as gzip compressed json lines
+type RecordWriter struct {
+	gz      *gzip.Writer
+	encoder *json.Encoder
+}
+
+// NewRecordWriter creates a record writer, Close should be called to flush the compressed stream
+func NewRecordWriter(w io.Writer) *RecordWriter {
+	gz := gzip.NewWriter(w)
+	return &RecordWriter{
+		gz:      gz,
+		encoder: json.NewEncoder(gz),
+	}
+}
+
+// Write writes a record and flushes it so that an interrupted recording keeps completed records
+func (w *RecordWriter) Write(record MetricsRecord) error {
+	if err := w.encoder.Encode(record); err != nil {
+		return err
+	}
+	return w.gz.Flush()
+}
+
+// Close finishes the compressed stream
+func (w *RecordWriter) Close() error {
+	return w.gz.Close()
+}
+
+// NewMetricsRecord converts average metrics of pods to a record, NaN values are recorded as 0
+func NewMetricsRecord(timestamp int64, metrics []PodAverageMetrics) MetricsRecord {
+	record := MetricsRecord{Timestamp: timestamp, Pods: make([]RecordedPod, len(metrics))}
+	for i, m := range metrics {
+		record.Pods[i] = RecordedPod{Name: m.Name, Namespace: m.Namespace, CPU: m.CPU, Memory: m.Memory}
+		if math.IsNaN(m.CPU) {
+			record.Pods[i].CPU = 0
+		}
+		if math.IsNaN(m.Memory) {
+			record.Pods[i].Memory = 0
+		}
+	}
+	return record
+}
+
+// ReadRecords reads records written by a record writer, a truncated last record is ignored
+func ReadRecords(r io.Reader) ([]MetricsRecord, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	records := []MetricsRecord{}
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var record MetricsRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			break
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	return records, nil
+}
+
+// ResourceSummary provides statistics of a resource of a pod over recorded samples
+type ResourceSummary struct {
+	Min     float64
+	Average float64
+	Max     float64
+	P95     float64
+	Values  []float64 `json:"-"`
+}
+
+// PodSummary provides CPU and memory statistics of a pod over recorded samples
+type PodSummary struct {
+	Name      string
+	Namespace string
+	Samples   int
+	From      int64
+	To        int64
+	CPU       ResourceSummary
+	Memory    ResourceSummary
+}
+
+// SummariseRecords computes min, average, max and 95th percentile of CPU and memory per pod sorted by namespace and name
+func SummariseRecords(records []MetricsRecord) []PodSummary {
+	summaries := make(map[string]*PodSummary)
+	for _, record := range records {
+		for _, pod := range record.Pods {
+			key := pod.Namespace + "/" + pod.Name
+			summary, ok := summaries[key]
+			if !ok {
+				summary = &PodSummary{Name: pod.Name, Namespace: pod.Namespace, From: record.Timestamp}
+				summaries[key] = summary
+			}
+			summary.Samples++
+			summary.To = record.Timestamp
+			summary.CPU.Values = append(summary.CPU.Values, pod.CPU)
+			summary.Memory.Values = append(summary.Memory.Values, pod.Memory)
+		}
+	}
+	res := make([]PodSummary, 0, len(summaries))
+	for _, summary := range summaries {
+		summary.CPU.describe()
+		summary.Memory.describe()
+		res = append(res, *summary)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Namespace != res[j].Namespace {
+			return res[i].Namespace < res[j].Namespace
+		}
+		return res[i].Name < res[j].Name
+	})
+	return res
+}
+
+func (s *ResourceSummary) describe() {
+	value := stats.Describe(s.Values, 0)
+	s.Min = value.Min
+	s.Average = value.Average
+	s.Max = value.Max
+	s.P95 = value.P95
+}
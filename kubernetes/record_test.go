@@ -0,0 +1,58 @@
+package kubeclient_test
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	kubeclient "github.com/magneticio/vampkubistcli/kubernetes"
+)
+
+func TestRecords(t *testing.T) {
+	var buffer bytes.Buffer
+	writer := kubeclient.NewRecordWriter(&buffer)
+	for i := 0; i < 20; i++ {
+		metrics := []kubeclient.PodAverageMetrics{
+			{Name: "vamp-1", Namespace: "vamp-system", CPU: float64(i+1) / 100, Memory: float64(100 + i)},
+			{Name: "mongo-0", Namespace: "vamp-system", CPU: 0.01, Memory: math.NaN()},
+		}
+		if err := writer.Write(kubeclient.NewMetricsRecord(int64(i*1000), metrics)); err != nil {
+			t.Fatalf("Write returned error: %v", err)
+		}
+	}
+
+	// a recording that is interrupted still has flushed records
+	records, err := kubeclient.ReadRecords(bytes.NewReader(buffer.Bytes()))
+	if err != nil {
+		t.Fatalf("ReadRecords of an unfinished recording returned error: %v", err)
+	}
+	if len(records) != 20 {
+		t.Errorf("Expected 20 records, got %v", len(records))
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+	records, err = kubeclient.ReadRecords(&buffer)
+	if err != nil {
+		t.Fatalf("ReadRecords returned error: %v", err)
+	}
+
+	summaries := kubeclient.SummariseRecords(records)
+	if len(summaries) != 2 {
+		t.Fatalf("Expected 2 pods, got %v", summaries)
+	}
+	mongo, vamp := summaries[0], summaries[1]
+	if mongo.Name != "mongo-0" || mongo.Memory.Max != 0 {
+		t.Errorf("NaN memory should be recorded as 0 in %v", mongo)
+	}
+	if vamp.Samples != 20 || vamp.From != 0 || vamp.To != 19000 {
+		t.Errorf("Samples aren't correct in %v", vamp)
+	}
+	if vamp.CPU.Min != 0.01 || vamp.CPU.Max != 0.2 || math.Abs(vamp.CPU.Average-0.105) > 1e-12 {
+		t.Errorf("CPU summary isn't correct in %v", vamp.CPU)
+	}
+	if math.Abs(vamp.Memory.P95-118.05) > 1e-9 {
+		t.Errorf("Memory p95 isn't correct in %v", vamp.Memory)
+	}
+}
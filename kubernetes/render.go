@@ -0,0 +1,182 @@
+package kubeclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/magneticio/vampkubistcli/models"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/util/cert"
+)
+
+// PlaceholderHost is the external host of rendered manifests if the host isn't known before installation
+const PlaceholderHost = "vamp-external-host"
+
+// Placeholders of certificates that should be replaced before rendered manifests are applied
+const (
+	PlaceholderCertificate = "REPLACE_WITH_PEM_CERTIFICATE"
+	PlaceholderKey         = "REPLACE_WITH_PEM_PRIVATE_KEY"
+)
+
+// KustomizationFileName is the name of the file that makes rendered manifests a kustomize base
+const KustomizationFileName = "kustomization.yaml"
+
+// Manifest is a kubernetes object rendered as yaml
+type Manifest struct {
+	FileName string
+	Data     []byte
+}
+
+/*
+RenderVampManifests renders the objects that installation applies to a cluster as yaml manifests in the order they are applied.
+Unless TLS is configured the certificate is generated for the external host, if the host is empty placeholders are used
+for the host and certificates so they should be replaced before manifests are applied.
+It returns the certificate if it is known before installation so it can be used to login.
+*/
+func RenderVampManifests(config *models.VampConfig, host string) ([]Manifest, []byte, error) {
+	ns := installationNamespace(config)
+	// installation sets the database url of the internal database, the config of the caller is kept as it is
+	vampConfig := *config
+	objects := []runtime.Object{
+		vampNamespace(ns),
+		vampClusterRoleBinding(ns, ClusterRoleBindingName(ns)),
+	}
+	if vampConfig.DatabaseUrl == "" {
+		if mongoAuthentication(&vampConfig) {
+			credentials, err := renderMongoDBCredentials(&vampConfig)
+			if err != nil {
+				return nil, nil, err
+			}
+			objects = append(objects, credentials)
+		}
+		objects = append(objects, mongoDBService(&vampConfig), mongoDBStatefulSet(&vampConfig))
+		vampConfig.DatabaseUrl = mongoDBUrl(&vampConfig)
+	}
+
+	if host == "" {
+		host = PlaceholderHost
+	}
+	objects = append(objects,
+		vampImagePullSecret(ns, &vampConfig),
+		hazelcastService(&vampConfig),
+		vampService(&vampConfig),
+	)
+	endpoint := Endpoint{Host: externalHost(&vampConfig, host), Port: VampPort}
+	if vampConfig.Ingress != nil {
+		objects = append(objects, vampIngress(&vampConfig))
+		endpoint = Endpoint{Host: vampConfig.Ingress.Host, Port: IngressPort}
+	}
+	certSecret, certObject, crt, certError := renderCertificates(&vampConfig, ns, host)
+	if certError != nil {
+		return nil, nil, certError
+	}
+	if certObject != nil {
+		objects = append(objects, certObject)
+	}
+	objects = append(objects,
+		rootPasswordSecret(&vampConfig),
+		vampDeployment(&vampConfig, certSecret, endpoint.Address()),
+		vampHPA(&vampConfig),
+	)
+
+	manifests := make([]Manifest, len(objects))
+	for i, object := range objects {
+		manifest, err := renderManifest(i, ns, object)
+		if err != nil {
+			return nil, nil, err
+		}
+		manifests[i] = manifest
+	}
+	return manifests, crt, nil
+}
+
+/*
+renderCertificates returns the secret of the certificate of vamp and the object that provides it.
+An existing secret is only referred to, certificate files are rendered as a secret and an issuer as a cert-manager Certificate.
+Otherwise a self-signed certificate is generated, or placeholders are used if the host isn't known.
+The certificate is only returned if it is known before installation.
+*/
+func renderCertificates(config *models.VampConfig, ns string, host string) (certificateSecret, runtime.Object, []byte, error) {
+	tlsConfig := config.TLS
+	if tlsConfig == nil {
+		tlsConfig = &models.TLSConfig{}
+	}
+	switch {
+	case tlsConfig.SecretName != "":
+		return tlsCertificateSecret(tlsConfig.SecretName), nil, nil, nil
+	case tlsConfig.CertificateFile != "":
+		crt, key, err := readCertificateFiles(tlsConfig)
+		if err != nil {
+			return certificateSecret{}, nil, nil, err
+		}
+		secret := tlsCertificateSecret(tlsSecretName(config))
+		return secret, tlsSecret(config, secret.Name, crt, key), crt, nil
+	case tlsConfig.Issuer != nil:
+		secret := tlsCertificateSecret(tlsSecretName(config))
+		return secret, certManagerCertificate(config, ns, secret.Name, host), nil, nil
+	}
+
+	secret := selfSignedCertificateSecret(host)
+	if host == PlaceholderHost {
+		return secret, certificatesSecret(config, secret.Name, []byte(PlaceholderCertificate), []byte(PlaceholderKey)), nil, nil
+	}
+	crt, key, err := cert.GenerateSelfSignedCertKey(host, []net.IP{}, alternateDNSNames(config, ns, host))
+	if err != nil {
+		return certificateSecret{}, nil, nil, err
+	}
+	return secret, certificatesSecret(config, secret.Name, crt, key), crt, nil
+}
+
+// renderManifest sets the kind and namespace that are implied when objects are applied with a client
+func renderManifest(index int, ns string, object runtime.Object) (Manifest, error) {
+	kinds, _, err := scheme.Scheme.ObjectKinds(object)
+	if err != nil {
+		return Manifest{}, err
+	}
+	object.GetObjectKind().SetGroupVersionKind(kinds[0])
+	accessor, err := meta.Accessor(object)
+	if err != nil {
+		return Manifest{}, err
+	}
+	if kinds[0].Kind != "Namespace" && kinds[0].Kind != "ClusterRoleBinding" {
+		accessor.SetNamespace(ns)
+	}
+	data, err := json.Marshal(object)
+	if err != nil {
+		return Manifest{}, err
+	}
+	yamlData, err := yaml.JSONToYAML(data)
+	if err != nil {
+		return Manifest{}, err
+	}
+	// files are prefixed with their index so applying a directory keeps the order of installation
+	fileName := fmt.Sprintf("%02d-%v-%v.yaml", index, strings.ToLower(kinds[0].Kind), accessor.GetName())
+	return Manifest{FileName: fileName, Data: yamlData}, nil
+}
+
+// RenderKustomization renders a kustomization that makes the manifests a kustomize base
+func RenderKustomization(manifests []Manifest) (Manifest, error) {
+	resources := make([]string, len(manifests))
+	for i, manifest := range manifests {
+		resources[i] = manifest.FileName
+	}
+	kustomization := struct {
+		APIVersion string   `json:"apiVersion"`
+		Kind       string   `json:"kind"`
+		Resources  []string `json:"resources"`
+	}{
+		APIVersion: "kustomize.config.k8s.io/v1beta1",
+		Kind:       "Kustomization",
+		Resources:  resources,
+	}
+	data, err := yaml.Marshal(kustomization)
+	if err != nil {
+		return Manifest{}, err
+	}
+	return Manifest{FileName: KustomizationFileName, Data: data}, nil
+}
@@ -0,0 +1,124 @@
+package kubeclient_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	kubeclient "github.com/magneticio/vampkubistcli/kubernetes"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRenderVampManifests(t *testing.T) {
+	config := kubeclient.DefaultVampConfig
+	config.RootPassword = "root"
+	config.RepoUsername = "user"
+	config.RepoPassword = "pass"
+
+	manifests, crt, err := kubeclient.RenderVampManifests(&config, "10.0.0.1")
+	if err != nil {
+		t.Fatalf("RenderVampManifests returned error: %v", err)
+	}
+	if config.DatabaseUrl != "" {
+		t.Error("Config of the caller should not be changed")
+	}
+	if len(crt) == 0 {
+		t.Error("Certificate should be generated for the host")
+	}
+	expectedFiles := []string{
+		"00-namespace-vamp-system.yaml",
+		"01-clusterrolebinding-vamp-system-sa-cluster-admin-binding.yaml",
+		"02-service-vamp-mongodb.yaml",
+		"03-statefulset-mongo.yaml",
+		"04-secret-vampkubistimagepull.yaml",
+		"05-service-vamp-hazelcast.yaml",
+		"06-service-vamp.yaml",
+		"07-secret-certificates-for-10.0.0.1.yaml",
+		"08-secret-vamprootpassword.yaml",
+		"09-deployment-vamp.yaml",
+		"10-horizontalpodautoscaler-vamp.yaml",
+	}
+	if len(manifests) != len(expectedFiles) {
+		t.Fatalf("Expected %v manifests, got %v", len(expectedFiles), len(manifests))
+	}
+	for i, manifest := range manifests {
+		if manifest.FileName != expectedFiles[i] {
+			t.Errorf("Expected file %v, got %v", expectedFiles[i], manifest.FileName)
+		}
+	}
+
+	var namespace corev1.Namespace
+	if err := yaml.Unmarshal(manifests[0].Data, &namespace); err != nil {
+		t.Fatalf("Cannot parse namespace manifest: %v", err)
+	}
+	if namespace.Kind != "Namespace" || namespace.APIVersion != "v1" || namespace.Namespace != "" {
+		t.Errorf("Unexpected namespace manifest %+v", namespace)
+	}
+
+	// rendered objects are the same as installed objects
+	clientset := newFakeClientset()
+	config.DatabaseUrl = "mongodb://" + kubeclient.NamesOf(config.ReleaseName).MongoDBHosts(3)
+	if _, _, _, err := kubeclient.InstallVamp(clientset, kubeclient.InstallationNamespace, &config); err != nil {
+		t.Fatalf("InstallVamp returned error: %v", err)
+	}
+	installed, err := clientset.AppsV1().Deployments(kubeclient.InstallationNamespace).Get("vamp", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Vamp deployment is not created - %v", err)
+	}
+	var rendered appsv1.Deployment
+	if err := yaml.Unmarshal(manifests[9].Data, &rendered); err != nil {
+		t.Fatalf("Cannot parse deployment manifest: %v", err)
+	}
+	if rendered.Kind != "Deployment" || rendered.APIVersion != "apps/v1" || rendered.Namespace != kubeclient.InstallationNamespace {
+		t.Errorf("Unexpected type or namespace of deployment manifest %v %v %v", rendered.Kind, rendered.APIVersion, rendered.Namespace)
+	}
+	if !reflect.DeepEqual(rendered.Spec.Template.Spec.Containers[0].Env, installed.Spec.Template.Spec.Containers[0].Env) {
+		t.Errorf("Rendered environment %v differs from installed %v", rendered.Spec.Template.Spec.Containers[0].Env, installed.Spec.Template.Spec.Containers[0].Env)
+	}
+	if !reflect.DeepEqual(rendered.Labels, installed.Labels) || *rendered.Spec.Replicas != *installed.Spec.Replicas {
+		t.Error("Rendered deployment differs from installed deployment")
+	}
+}
+
+func TestRenderVampManifestsWithPlaceholders(t *testing.T) {
+	config := kubeclient.DefaultVampConfig
+	config.DatabaseUrl = "mongodb://external:27017"
+	manifests, crt, err := kubeclient.RenderVampManifests(&config, "")
+	if err != nil {
+		t.Fatalf("RenderVampManifests returned error: %v", err)
+	}
+	if crt != nil {
+		t.Error("No certificate should be returned for placeholders")
+	}
+	if len(manifests) != 9 {
+		t.Fatalf("Manifests of the internal database should not be rendered, got %v manifests", len(manifests))
+	}
+	var secret corev1.Secret
+	if err := yaml.Unmarshal(manifests[5].Data, &secret); err != nil {
+		t.Fatalf("Cannot parse certificates manifest: %v", err)
+	}
+	if secret.Name != "certificates-for-"+kubeclient.PlaceholderHost || string(secret.Data["cert"]) != kubeclient.PlaceholderCertificate {
+		t.Errorf("Certificates should be placeholders in %v", secret.Name)
+	}
+	if !strings.Contains(string(manifests[7].Data), kubeclient.PlaceholderHost+":8888") {
+		t.Error("External host of the deployment should be a placeholder")
+	}
+
+	kustomization, err := kubeclient.RenderKustomization(manifests)
+	if err != nil {
+		t.Fatalf("RenderKustomization returned error: %v", err)
+	}
+	var parsed struct {
+		Kind      string   `json:"kind"`
+		Resources []string `json:"resources"`
+	}
+	if err := yaml.Unmarshal(kustomization.Data, &parsed); err != nil {
+		t.Fatalf("Cannot parse kustomization: %v", err)
+	}
+	if kustomization.FileName != "kustomization.yaml" || parsed.Kind != "Kustomization" || len(parsed.Resources) != 9 || parsed.Resources[0] != manifests[0].FileName {
+		t.Errorf("Unexpected kustomization %v", string(kustomization.Data))
+	}
+}
@@ -0,0 +1,276 @@
+package kubeclient
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/magneticio/vampkubistcli/client"
+	"github.com/magneticio/vampkubistcli/util"
+	autoscalingv2beta1 "k8s.io/api/autoscaling/v2beta1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// CertExpiryWarningPeriod is how long before its expiry a certificate is reported as expiring
+const CertExpiryWarningPeriod = 30 * 24 * time.Hour
+
+// maxStatusEvents is the number of recent warning events in a status report
+const maxStatusEvents = 10
+
+// StatusCheck is the result of checking one part of an installation
+type StatusCheck struct {
+	Name    string `yaml:"name" json:"name"`
+	Healthy bool   `yaml:"healthy" json:"healthy"`
+	Message string `yaml:"message" json:"message"`
+}
+
+// InstallationStatus is the health report of an installation
+type InstallationStatus struct {
+	Namespace   string        `yaml:"namespace" json:"namespace"`
+	ReleaseName string        `yaml:"releaseName" json:"releaseName"`
+	Checks      []StatusCheck `yaml:"checks" json:"checks"`
+	Events      []string      `yaml:"events,omitempty" json:"events,omitempty"`
+}
+
+// Healthy is true if every check of the installation is healthy
+func (status *InstallationStatus) Healthy() bool {
+	for _, check := range status.Checks {
+		if !check.Healthy {
+			return false
+		}
+	}
+	return true
+}
+
+func (status *InstallationStatus) add(name string, healthy bool, format string, args ...interface{}) {
+	status.Checks = append(status.Checks, StatusCheck{Name: name, Healthy: healthy, Message: fmt.Sprintf(format, args...)})
+}
+
+// PingFunc pings the vamp API at the url with the certificate
+type PingFunc func(url string, cert []byte) error
+
+// PingVamp pings the vamp API once
+func PingVamp(url string, cert []byte) error {
+	pong, err := client.NewRestClient(url, "", "", false, string(cert), nil).Ping()
+	if err != nil {
+		return err
+	}
+	if !pong {
+		return errors.New("Service is not available yet.")
+	}
+	return nil
+}
+
+// GetVampStatus reports the health of the installation of a release
+func GetVampStatus(configPath string, ns string, releaseName string) (*InstallationStatus, error) {
+	clientset, err := K8sClient.Get(configPath)
+	if err != nil {
+		return nil, err
+	}
+	return GetInstallationStatus(clientset, ns, releaseName, PingVamp), nil
+}
+
+/*
+GetInstallationStatus checks the objects of an installation: the vamp deployment and its autoscaler, the database,
+services, secrets and the cluster role binding. It reports the expiry of the certificate, whether the API answers
+and recent warning events of the namespace. Ping isn't checked if vamp can't be reached from outside of the cluster.
+*/
+func GetInstallationStatus(clientset kubernetes.Interface, ns string, releaseName string, ping PingFunc) *InstallationStatus {
+	names := NamesOf(releaseName)
+	status := &InstallationStatus{Namespace: ns, ReleaseName: names.Release}
+
+	deployment, deploymentErr := clientset.AppsV1().Deployments(ns).Get(names.Release, metav1.GetOptions{})
+	if deploymentErr != nil {
+		status.add("deployment/"+names.Release, false, "%v", deploymentErr)
+	} else {
+		desired := int32(1)
+		if deployment.Spec.Replicas != nil {
+			desired = *deployment.Spec.Replicas
+		}
+		ready := deployment.Status.ReadyReplicas
+		tags := []string{}
+		for _, container := range deployment.Spec.Template.Spec.Containers {
+			tags = append(tags, imageTag(container.Image))
+		}
+		message := fmt.Sprintf("%v/%v replicas ready, image tag %v", ready, desired, strings.Join(tags, ", "))
+		if container, err := vampContainer(deployment); err == nil && imageTag(container.Image) != DefaultVampConfig.ImageTag {
+			message += fmt.Sprintf(" differs from %v of this version", DefaultVampConfig.ImageTag)
+		}
+		status.add("deployment/"+names.Release, ready > 0 && ready >= desired, "%v", message)
+	}
+
+	hpa, err := clientset.AutoscalingV2beta1().HorizontalPodAutoscalers(ns).Get(names.Release, metav1.GetOptions{})
+	if err != nil {
+		status.add("horizontalpodautoscaler/"+names.Release, false, "%v", err)
+	} else {
+		status.add("horizontalpodautoscaler/"+names.Release, true, "%v replicas of %v-%v, %v",
+			hpa.Status.CurrentReplicas, derefInt32(hpa.Spec.MinReplicas, 1), hpa.Spec.MaxReplicas, hpaMetrics(hpa))
+	}
+
+	statefulSet, err := clientset.AppsV1().StatefulSets(ns).Get(names.MongoStatefulSet, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		status.add("statefulset/"+names.MongoStatefulSet, true, "not installed, an external database is used")
+	} else if err != nil {
+		status.add("statefulset/"+names.MongoStatefulSet, false, "%v", err)
+	} else {
+		desired := derefInt32(statefulSet.Spec.Replicas, 1)
+		ready := statefulSet.Status.ReadyReplicas
+		status.add("statefulset/"+names.MongoStatefulSet, ready >= desired, "%v/%v replicas ready", ready, desired)
+	}
+
+	for _, name := range []string{names.Release, names.Hazelcast} {
+		service, err := clientset.CoreV1().Services(ns).Get(name, metav1.GetOptions{})
+		if err != nil {
+			status.add("service/"+name, false, "%v", err)
+			continue
+		}
+		if service.Spec.Type == corev1.ServiceTypeLoadBalancer && len(service.Status.LoadBalancer.Ingress) == 0 {
+			status.add("service/"+name, false, "%v without an external address", service.Spec.Type)
+			continue
+		}
+		status.add("service/"+name, true, "%v", service.Spec.Type)
+	}
+
+	secretNames := []string{names.ImagePull, names.RootPassword}
+	var certSecret certificateSecret
+	var endpoint Endpoint
+	if deploymentErr == nil {
+		certSecret, endpoint, err = deploymentCertificate(deployment)
+		if err == nil {
+			secretNames = append(secretNames, certSecret.Name)
+		} else {
+			status.add("certificate", false, "%v", err)
+		}
+	}
+	for _, name := range secretNames {
+		if _, err := clientset.CoreV1().Secrets(ns).Get(name, metav1.GetOptions{}); err != nil {
+			status.add("secret/"+name, false, "%v", err)
+		} else {
+			status.add("secret/"+name, true, "exists")
+		}
+	}
+
+	// the binding of either RBAC mode of bootstrap is accepted
+	if rbac, err := BootstrappedRBAC(clientset, ns); err != nil {
+		status.add("clusterrolebinding/"+ClusterRoleBindingName(ns), false, "%v", err)
+	} else {
+		status.add("clusterrolebinding/"+clusterRoleBindingNameOfRBAC(rbac, ns), true, "exists, %v RBAC", rbac)
+	}
+
+	if certSecret.Name != "" {
+		crt, _, err := readCertificateSecret(clientset, ns, certSecret)
+		if err != nil {
+			status.add("certificate", false, "%v", err)
+		} else {
+			checkCertificate(status, crt)
+			if clientCrt, err := clientCertificate(clientset, ns, releaseName, crt); err != nil {
+				status.add("ping", false, "certificate of the ingress can not be read - %v", err)
+			} else {
+				checkPing(status, endpoint, serviceHost(releaseName, ns), clientCrt, ping)
+			}
+		}
+	}
+
+	status.Events = warningEvents(clientset, ns)
+	return status
+}
+
+func checkCertificate(status *InstallationStatus, crt []byte) {
+	expiry, err := util.CertificateExpiry(string(crt))
+	if err != nil {
+		status.add("certificate", false, "%v", err)
+		return
+	}
+	remaining := time.Until(expiry)
+	switch {
+	case remaining <= 0:
+		status.add("certificate", false, "expired at %v", expiry.Format(time.RFC3339))
+	case remaining < CertExpiryWarningPeriod && IsGeneratedCertificate(crt):
+		status.add("certificate", true, "expires in %v days at %v, it should be rotated", int(remaining.Hours()/24), expiry.Format(time.RFC3339))
+	case remaining < CertExpiryWarningPeriod:
+		status.add("certificate", true, "expires in %v days at %v, it should be renewed where it is issued", int(remaining.Hours()/24), expiry.Format(time.RFC3339))
+	default:
+		status.add("certificate", true, "expires at %v", expiry.Format(time.RFC3339))
+	}
+}
+
+func checkPing(status *InstallationStatus, endpoint Endpoint, serviceHost string, crt []byte, ping PingFunc) {
+	if endpoint.Host == "" {
+		status.add("ping", false, "external host of the deployment is unknown")
+		return
+	}
+	if endpoint.Host == serviceHost {
+		status.add("ping", true, "%v is only reachable in the cluster, skipped", endpoint.URL())
+		return
+	}
+	if err := ping(endpoint.URL(), crt); err != nil {
+		status.add("ping", false, "%v doesn't answer - %v", endpoint.URL(), err)
+		return
+	}
+	status.add("ping", true, "%v answers", endpoint.URL())
+}
+
+// warningEvents returns the most recent warning events of the namespace, the latest first
+func warningEvents(clientset kubernetes.Interface, ns string) []string {
+	events, err := clientset.CoreV1().Events(ns).List(metav1.ListOptions{})
+	if err != nil {
+		return []string{fmt.Sprintf("Events can not be listed - %v", err)}
+	}
+	warnings := []corev1.Event{}
+	for _, event := range events.Items {
+		if event.Type == corev1.EventTypeWarning {
+			warnings = append(warnings, event)
+		}
+	}
+	sort.Slice(warnings, func(i, j int) bool {
+		return warnings[j].LastTimestamp.Before(&warnings[i].LastTimestamp)
+	})
+	if len(warnings) > maxStatusEvents {
+		warnings = warnings[:maxStatusEvents]
+	}
+	res := make([]string, len(warnings))
+	for i, event := range warnings {
+		res[i] = fmt.Sprintf("%v %v/%v %v: %v", event.LastTimestamp.Format(time.RFC3339),
+			strings.ToLower(event.InvolvedObject.Kind), event.InvolvedObject.Name, event.Reason, event.Message)
+	}
+	return res
+}
+
+// hpaMetrics describes the current metrics of an autoscaler
+func hpaMetrics(hpa *autoscalingv2beta1.HorizontalPodAutoscaler) string {
+	metrics := []string{}
+	for _, metric := range hpa.Status.CurrentMetrics {
+		if metric.Resource == nil {
+			continue
+		}
+		if metric.Resource.CurrentAverageUtilization != nil {
+			metrics = append(metrics, fmt.Sprintf("%v %v%%", metric.Resource.Name, *metric.Resource.CurrentAverageUtilization))
+		} else {
+			metrics = append(metrics, fmt.Sprintf("%v %v", metric.Resource.Name, metric.Resource.CurrentAverageValue.String()))
+		}
+	}
+	if len(metrics) == 0 {
+		return "no current metrics"
+	}
+	return strings.Join(metrics, ", ")
+}
+
+// imageTag returns the tag of an image, latest is implied if an image has no tag
+func imageTag(image string) string {
+	if i := strings.LastIndex(image, ":"); i >= 0 && !strings.Contains(image[i:], "/") {
+		return image[i+1:]
+	}
+	return "latest"
+}
+
+func derefInt32(value *int32, defaultValue int32) int32 {
+	if value == nil {
+		return defaultValue
+	}
+	return *value
+}
@@ -0,0 +1,120 @@
+package kubeclient_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	kubeclient "github.com/magneticio/vampkubistcli/kubernetes"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func findCheck(status *kubeclient.InstallationStatus, name string) *kubeclient.StatusCheck {
+	for i := range status.Checks {
+		if status.Checks[i].Name == name {
+			return &status.Checks[i]
+		}
+	}
+	return nil
+}
+
+func TestGetInstallationStatus(t *testing.T) {
+	ns := kubeclient.InstallationNamespace
+	clientset := newFakeClientset(
+		&rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: kubeclient.ClusterRoleBindingName(ns)}},
+		&corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: "vamp.1", Namespace: ns},
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "vamp-1"},
+			Type:           corev1.EventTypeWarning,
+			Reason:         "BackOff",
+			Message:        "Back-off restarting failed container",
+			LastTimestamp:  metav1.NewTime(time.Now()),
+		},
+		&corev1.Event{
+			ObjectMeta: metav1.ObjectMeta{Name: "vamp.2", Namespace: ns},
+			Type:       corev1.EventTypeNormal,
+			Reason:     "Scheduled",
+		},
+	)
+	config := tlsTestConfig(nil)
+	if _, _, _, err := kubeclient.InstallVamp(clientset, ns, &config); err != nil {
+		t.Fatalf("InstallVamp returned error: %v", err)
+	}
+	pinged := ""
+	ping := func(url string, cert []byte) error {
+		pinged = url
+		return errors.New("connection refused")
+	}
+
+	status := kubeclient.GetInstallationStatus(clientset, ns, "", ping)
+	if status.Healthy() {
+		t.Error("Installation without ready replicas and ping should not be healthy")
+	}
+	if check := findCheck(status, "deployment/vamp"); check == nil || check.Healthy || !strings.Contains(check.Message, "0/3 replicas ready, image tag "+kubeclient.DefaultVampConfig.ImageTag) {
+		t.Errorf("Unexpected deployment check %+v", check)
+	}
+	if check := findCheck(status, "statefulset/mongo"); check == nil || !check.Healthy {
+		t.Errorf("Missing database should be reported as external %+v", check)
+	}
+	if check := findCheck(status, "secret/certificates-for-10.0.0.1"); check == nil || !check.Healthy {
+		t.Errorf("Certificate secret should be checked %+v", check)
+	}
+	if check := findCheck(status, "certificate"); check == nil || !check.Healthy || !strings.HasPrefix(check.Message, "expires at") {
+		t.Errorf("Unexpected certificate check %+v", check)
+	}
+	if check := findCheck(status, "ping"); pinged != "https://10.0.0.1:8888" || check == nil || check.Healthy {
+		t.Errorf("Unexpected ping of %v %+v", pinged, check)
+	}
+	if len(status.Events) != 1 || !strings.Contains(status.Events[0], "pod/vamp-1 BackOff") {
+		t.Errorf("Only warning events should be reported, got %v", status.Events)
+	}
+
+	deployment, _ := clientset.AppsV1().Deployments(ns).Get("vamp", metav1.GetOptions{})
+	deployment.Status.ReadyReplicas = 3
+	deployment.Spec.Template.Spec.Containers[0].Image = "magneticio/vampkubist:0.0.1"
+	if _, err := clientset.AppsV1().Deployments(ns).Update(deployment); err != nil {
+		t.Fatal(err)
+	}
+	status = kubeclient.GetInstallationStatus(clientset, ns, "", func(url string, cert []byte) error { return nil })
+	for _, check := range status.Checks {
+		if !check.Healthy {
+			t.Errorf("Unexpected unhealthy check %+v", check)
+		}
+	}
+	if check := findCheck(status, "deployment/vamp"); check == nil || !strings.Contains(check.Message, "0.0.1 differs from "+kubeclient.DefaultVampConfig.ImageTag) {
+		t.Errorf("Image tag should be compared with the default %+v", check)
+	}
+}
+
+func TestGetInstallationStatusWithMinimalRBAC(t *testing.T) {
+	ns := kubeclient.InstallationNamespace
+	clientset := newFakeClientset(&rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: kubeclient.MinimalClusterRoleBindingName(ns)}})
+	config := tlsTestConfig(nil)
+	if _, _, _, err := kubeclient.InstallVamp(clientset, ns, &config); err != nil {
+		t.Fatalf("InstallVamp returned error: %v", err)
+	}
+
+	status := kubeclient.GetInstallationStatus(clientset, ns, "", func(url string, cert []byte) error { return nil })
+	if check := findCheck(status, "clusterrolebinding/"+kubeclient.MinimalClusterRoleBindingName(ns)); check == nil || !check.Healthy {
+		t.Errorf("Binding of minimal RBAC should be accepted %+v", check)
+	}
+	if check := findCheck(status, "clusterrolebinding/"+kubeclient.ClusterRoleBindingName(ns)); check != nil {
+		t.Errorf("Binding of cluster-admin should not be required %+v", check)
+	}
+}
+
+func TestGetInstallationStatusWithoutInstallation(t *testing.T) {
+	status := kubeclient.GetInstallationStatus(newFakeClientset(), "vamp-missing", "", func(url string, cert []byte) error {
+		t.Error("Missing installation should not be pinged")
+		return nil
+	})
+	if status.Healthy() {
+		t.Error("Missing installation should not be healthy")
+	}
+	if check := findCheck(status, "clusterrolebinding/vamp-missing-sa-cluster-admin-binding"); check == nil || check.Healthy {
+		t.Errorf("Missing cluster role binding should be reported %+v", check)
+	}
+}
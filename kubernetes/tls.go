@@ -0,0 +1,460 @@
+package kubeclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	"github.com/magneticio/vampkubistcli/models"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/cert"
+)
+
+// CertManagerAPIVersion is the API version of cert-manager certificates that are created for issuers
+const CertManagerAPIVersion = "cert-manager.io/v1"
+
+// RestartedAtAnnotation is the pod template annotation that triggers a rolling restart of a deployment
+const RestartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+
+// certificateSecret refers to the keys of the certificate and the private key of vamp in a secret
+type certificateSecret struct {
+	Name    string
+	CertKey string
+	KeyKey  string
+}
+
+// selfSignedCertificateSecret is the secret of a generated certificate for the host
+func selfSignedCertificateSecret(host string) certificateSecret {
+	return certificateSecret{Name: certificatesSecretName(host), CertKey: "cert", KeyKey: "key"}
+}
+
+// tlsCertificateSecret is a secret of type kubernetes.io/tls like the ones issued by cert-manager
+func tlsCertificateSecret(name string) certificateSecret {
+	return certificateSecret{Name: name, CertKey: corev1.TLSCertKey, KeyKey: corev1.TLSPrivateKeyKey}
+}
+
+// tlsSecretName is the name of the secret of certificates from files or an issuer
+func tlsSecretName(config *models.VampConfig) string {
+	return NamesOf(config.ReleaseName).Release + "-tls"
+}
+
+// ValidateTLSConfig checks that at most one source of the certificate is configured and sets the default issuer kind
+func ValidateTLSConfig(config *models.TLSConfig) error {
+	if config == nil {
+		return nil
+	}
+	sources := 0
+	if config.SecretName != "" {
+		sources++
+	}
+	if config.CertificateFile != "" || config.KeyFile != "" {
+		if config.CertificateFile == "" || config.KeyFile == "" {
+			return errors.New("Both certificate file and key file are required")
+		}
+		sources++
+	}
+	if config.Issuer != nil {
+		if config.Issuer.Name == "" {
+			return errors.New("Issuer name is required")
+		}
+		if config.Issuer.Kind == "" {
+			config.Issuer.Kind = "Issuer"
+		}
+		if config.Issuer.Kind != "Issuer" && config.Issuer.Kind != "ClusterIssuer" {
+			return fmt.Errorf("Issuer kind %v is not supported, use Issuer or ClusterIssuer", config.Issuer.Kind)
+		}
+		sources++
+	}
+	if sources > 1 {
+		return errors.New("Only one of secret name, certificate files and issuer can be configured")
+	}
+	for _, name := range config.DNSNames {
+		if errs := validation.IsWildcardDNS1123Subdomain(name); len(errs) > 0 && len(validation.IsDNS1123Subdomain(name)) > 0 {
+			return fmt.Errorf("DNS name %v is not valid - %v", name, strings.Join(errs, ", "))
+		}
+	}
+	return nil
+}
+
+// externalHost is the host that clients connect to, the first DNS name of the certificate is preferred over the IP
+func externalHost(config *models.VampConfig, ip string) string {
+	if config.TLS != nil && len(config.TLS.DNSNames) > 0 && !strings.HasPrefix(config.TLS.DNSNames[0], "*") {
+		return config.TLS.DNSNames[0]
+	}
+	return ip
+}
+
+func dnsNames(config *models.VampConfig) []string {
+	if config.TLS == nil {
+		return []string{}
+	}
+	return config.TLS.DNSNames
+}
+
+/*
+installCertificates makes sure the secret with the certificate of vamp exists and returns it with the certificate.
+An existing secret is used as it is, certificate files are stored in a secret, for an issuer a cert-manager Certificate is created
+and the issued secret is awaited, otherwise a self-signed certificate is generated for the IP and DNS names.
+*/
+func installCertificates(clientset kubernetes.Interface, ns string, config *models.VampConfig, ip string) (certificateSecret, []byte, []byte, error) {
+	tlsConfig := config.TLS
+	if tlsConfig == nil {
+		tlsConfig = &models.TLSConfig{}
+	}
+	switch {
+	case tlsConfig.SecretName != "":
+		secret := tlsCertificateSecret(tlsConfig.SecretName)
+		crt, key, err := readCertificateSecret(clientset, ns, secret)
+		return secret, crt, key, err
+	case tlsConfig.CertificateFile != "":
+		crt, key, err := readCertificateFiles(tlsConfig)
+		if err != nil {
+			return certificateSecret{}, nil, nil, err
+		}
+		secret := tlsCertificateSecret(tlsSecretName(config))
+		if err := CreateOrUpdateSecret(clientset, ns, tlsSecret(config, secret.Name, crt, key)); err != nil {
+			return certificateSecret{}, nil, nil, err
+		}
+		return secret, crt, key, nil
+	case tlsConfig.Issuer != nil:
+		secret := tlsCertificateSecret(tlsSecretName(config))
+		if err := CreateOrUpdateCertificate(clientset, ns, certManagerCertificate(config, ns, secret.Name, ip)); err != nil {
+			return certificateSecret{}, nil, nil, err
+		}
+		crt, key, err := WaitForCertificateSecret(clientset, ns, secret.Name)
+		return secret, crt, key, err
+	}
+
+	secret := selfSignedCertificateSecret(ip)
+	certSecret, getCertSecretErr := GetOpaqueSecret(clientset, ns, secret.Name)
+	if getCertSecretErr == nil {
+		return secret, certSecret[secret.CertKey], certSecret[secret.KeyKey], nil
+	}
+	fmt.Printf("Warning: %v\n", getCertSecretErr.Error())
+	crt, key, certError := cert.GenerateSelfSignedCertKey(ip, []net.IP{}, alternateDNSNames(config, ns, ip))
+	if certError != nil {
+		return certificateSecret{}, nil, nil, certError
+	}
+	certSecretError := CreateOrUpdateSecret(clientset, ns, certificatesSecret(config, secret.Name, crt, key))
+	if certSecretError != nil {
+		fmt.Printf("Warning: %v\n", certSecretError.Error())
+		return certificateSecret{}, nil, nil, certSecretError
+	}
+	return secret, crt, key, nil
+}
+
+func readCertificateFiles(config *models.TLSConfig) ([]byte, []byte, error) {
+	crt, err := ioutil.ReadFile(config.CertificateFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := ioutil.ReadFile(config.KeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := tls.X509KeyPair(crt, key); err != nil {
+		return nil, nil, fmt.Errorf("Certificate and key are not a valid pair - %v", err)
+	}
+	return crt, key, nil
+}
+
+func readCertificateSecret(clientset kubernetes.Interface, ns string, secret certificateSecret) ([]byte, []byte, error) {
+	data, err := GetOpaqueSecret(clientset, ns, secret.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(data[secret.CertKey]) == 0 || len(data[secret.KeyKey]) == 0 {
+		return nil, nil, fmt.Errorf("Secret %v doesn't have %v and %v", secret.Name, secret.CertKey, secret.KeyKey)
+	}
+	return data[secret.CertKey], data[secret.KeyKey], nil
+}
+
+/*
+clientCertificate returns the certificate that clients of a release verify, crt is the certificate of vamp.
+An ingress of the release that terminates TLS serves the certificate of its secret instead.
+*/
+func clientCertificate(clientset kubernetes.Interface, ns string, releaseName string, crt []byte) ([]byte, error) {
+	ingress, err := clientset.ExtensionsV1beta1().Ingresses(ns).Get(NamesOf(releaseName).Release, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return crt, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, ingressTLS := range ingress.Spec.TLS {
+		if ingressTLS.SecretName != "" {
+			ingressCrt, _, err := readCertificateSecret(clientset, ns, tlsCertificateSecret(ingressTLS.SecretName))
+			return ingressCrt, err
+		}
+	}
+	return crt, nil
+}
+
+// WaitForCertificateSecret waits until the secret of an issued certificate exists and returns the certificate and key
+func WaitForCertificateSecret(clientset kubernetes.Interface, ns string, name string) ([]byte, []byte, error) {
+	var crt, key []byte
+	count := 1
+	operation := func() error {
+		fmt.Printf("Waiting for certificate secret %v trial %v\n", name, count)
+		count++
+		var err error
+		crt, key, err = readCertificateSecret(clientset, ns, tlsCertificateSecret(name))
+		return err
+	}
+	if err := backoff.Retry(operation, backoff.NewExponentialBackOff()); err != nil {
+		return nil, nil, err
+	}
+	return crt, key, nil
+}
+
+func tlsSecret(config *models.VampConfig, name string, crt []byte, key []byte) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: objectMeta(config, name, nil),
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       crt,
+			corev1.TLSPrivateKeyKey: key,
+		},
+		Type: corev1.SecretTypeTLS,
+	}
+}
+
+// certManagerCertificate returns a cert-manager Certificate for the DNS names and the IP of vamp
+func certManagerCertificate(config *models.VampConfig, ns string, secretName string, ip string) *unstructured.Unstructured {
+	meta := objectMeta(config, secretName, nil)
+	spec := map[string]interface{}{
+		"secretName": secretName,
+		"issuerRef": map[string]interface{}{
+			"name":  config.TLS.Issuer.Name,
+			"kind":  config.TLS.Issuer.Kind,
+			"group": "cert-manager.io",
+		},
+	}
+	if names := publicDNSNames(config); len(names) > 0 {
+		spec["dnsNames"] = toInterfaces(names)
+	}
+	if net.ParseIP(ip) != nil {
+		spec["ipAddresses"] = []interface{}{ip}
+	}
+	certificate := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": CertManagerAPIVersion,
+		"kind":       "Certificate",
+		"spec":       spec,
+	}}
+	certificate.SetName(meta.Name)
+	certificate.SetNamespace(ns)
+	certificate.SetLabels(meta.Labels)
+	certificate.SetAnnotations(meta.Annotations)
+	return certificate
+}
+
+func toInterfaces(values []string) []interface{} {
+	res := make([]interface{}, len(values))
+	for i, v := range values {
+		res[i] = v
+	}
+	return res
+}
+
+/*
+CreateOrUpdateCertificate creates a cert-manager Certificate or updates an existing one.
+cert-manager isn't a typed client so the certificate is sent through the REST client of discovery.
+*/
+func CreateOrUpdateCertificate(clientset kubernetes.Interface, ns string, certificate *unstructured.Unstructured) error {
+	fmt.Printf("CreateOrUpdateCertificate: %v\n", certificate.GetName())
+	restClient := clientset.Discovery().RESTClient()
+	if restClient == nil {
+		return errors.New("Certificates can not be requested without a REST client")
+	}
+	path := "apis/" + CertManagerAPIVersion + "/namespaces/" + ns + "/certificates"
+	body, err := json.Marshal(certificate.Object)
+	if err != nil {
+		return err
+	}
+	err = restClient.Post().AbsPath(path).SetHeader("Content-Type", "application/json").Body(body).Do().Error()
+	if !k8serrors.IsAlreadyExists(err) {
+		return err
+	}
+	data, err := restClient.Get().AbsPath(path, certificate.GetName()).DoRaw()
+	if err != nil {
+		return err
+	}
+	var current unstructured.Unstructured
+	if err := current.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	certificate.SetResourceVersion(current.GetResourceVersion())
+	body, err = json.Marshal(certificate.Object)
+	if err != nil {
+		return err
+	}
+	return restClient.Put().AbsPath(path, certificate.GetName()).SetHeader("Content-Type", "application/json").Body(body).Do().Error()
+}
+
+// GetCertificateSecretName returns the name of the secret that has the certificate of the vamp deployment
+func GetCertificateSecretName(clientset kubernetes.Interface, ns string, releaseName string) (string, error) {
+	deployment, err := clientset.AppsV1().Deployments(ns).Get(NamesOf(releaseName).Release, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	secret, _, err := deploymentCertificate(deployment)
+	return secret.Name, err
+}
+
+// deploymentCertificate returns the certificate secret and the external endpoint of the vamp deployment
+func deploymentCertificate(deployment *appsv1.Deployment) (certificateSecret, Endpoint, error) {
+	var secret certificateSecret
+	endpoint := Endpoint{Port: VampPort}
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		for _, env := range container.Env {
+			switch {
+			case env.Name == "API_SERVER_CERTIFICATE" && env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil:
+				secret.Name = env.ValueFrom.SecretKeyRef.Name
+				secret.CertKey = env.ValueFrom.SecretKeyRef.Key
+			case env.Name == "API_PRIVATE_KEY" && env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil:
+				secret.KeyKey = env.ValueFrom.SecretKeyRef.Key
+			case env.Name == "API_EXTERNAL_HOST":
+				endpoint.Host = env.Value
+				if host, port, splitErr := net.SplitHostPort(env.Value); splitErr == nil {
+					portNumber, _ := strconv.Atoi(port)
+					endpoint = Endpoint{Host: host, Port: int32(portNumber)}
+				}
+			}
+		}
+	}
+	if secret.Name == "" || secret.KeyKey == "" {
+		return secret, endpoint, fmt.Errorf("Deployment %v doesn't have a certificate", deployment.Name)
+	}
+	return secret, endpoint, nil
+}
+
+// RotateVampCertificates rotates the certificate of an installation and waits until vamp serves the new certificate
+func RotateVampCertificates(configPath string, ns string, releaseName string) (string, []byte, error) {
+	clientset, err := K8sClient.Get(configPath)
+	if err != nil {
+		return "", nil, err
+	}
+	return RotateCertificatesAndWait(clientset, ns, releaseName, PingVamp)
+}
+
+/*
+RotateCertificatesAndWait rotates the certificate of an installation and pings vamp until it serves the new certificate,
+old pods serve the previous certificate until the rollout is complete.
+Vamp that is only reachable in the cluster is not pinged, the same as after upgrades.
+*/
+func RotateCertificatesAndWait(clientset kubernetes.Interface, ns string, releaseName string, ping PingFunc) (string, []byte, error) {
+	vampURL, crt, err := RotateCertificates(clientset, ns, releaseName)
+	if err != nil {
+		return "", nil, err
+	}
+	parsedURL, err := url.Parse(vampURL)
+	if err != nil {
+		return vampURL, crt, err
+	}
+	if parsedURL.Hostname() == serviceHost(releaseName, ns) {
+		fmt.Printf("Vamp is only reachable in the cluster, rotation is not verified\n")
+		return vampURL, crt, nil
+	}
+	clientCrt, err := clientCertificate(clientset, ns, releaseName, crt)
+	if err != nil {
+		return vampURL, crt, err
+	}
+	if waitErr := waitForService(vampURL, clientCrt, ping, backoff.NewExponentialBackOff()); waitErr != nil {
+		return vampURL, crt, waitErr
+	}
+	return vampURL, crt, nil
+}
+
+/*
+RotateCertificates generates a new self-signed certificate for the external host of the vamp deployment,
+it keeps the IP addresses and DNS names of the previous certificate. The secret is updated and the deployment is restarted
+so it serves the new certificate. Certificates that are not generated by installation are not rotated,
+they should be renewed where they are issued.
+*/
+func RotateCertificates(clientset kubernetes.Interface, ns string, releaseName string) (string, []byte, error) {
+	deployment, err := clientset.AppsV1().Deployments(ns).Get(NamesOf(releaseName).Release, metav1.GetOptions{})
+	if err != nil {
+		return "", nil, err
+	}
+	certSecret, endpoint, err := deploymentCertificate(deployment)
+	if err != nil {
+		return "", nil, err
+	}
+	host := endpoint.Host
+	if certSecret.CertKey != selfSignedCertificateSecret(host).CertKey {
+		return "", nil, fmt.Errorf("Certificate of secret %v is not generated by installation, renew it where it is issued", certSecret.Name)
+	}
+	secret, err := clientset.CoreV1().Secrets(ns).Get(certSecret.Name, metav1.GetOptions{})
+	if err != nil {
+		return "", nil, err
+	}
+	alternateIPs := []net.IP{}
+	// localhost is kept for port forwarding
+	alternateDNS := []string{"localhost"}
+	if previous, parseErr := parseCertificate(secret.Data[certSecret.CertKey]); parseErr == nil {
+		for _, ip := range previous.IPAddresses {
+			if ip.String() != host {
+				alternateIPs = append(alternateIPs, ip)
+			}
+		}
+		alternateDNS = uniqueNames(append(alternateDNS, previous.DNSNames...), host)
+	} else {
+		fmt.Printf("Warning: previous certificate can not be parsed - %v\n", parseErr)
+	}
+	crt, key, err := cert.GenerateSelfSignedCertKey(host, alternateIPs, alternateDNS)
+	if err != nil {
+		return "", nil, err
+	}
+	secret.Data = map[string][]byte{
+		certSecret.CertKey: crt,
+		certSecret.KeyKey:  key,
+	}
+	if _, err := clientset.CoreV1().Secrets(ns).Update(secret); err != nil {
+		return "", nil, err
+	}
+	// environment variables of secrets are only read at start so pods are restarted the same way as kubectl rollout restart
+	if deployment.Spec.Template.Annotations == nil {
+		deployment.Spec.Template.Annotations = map[string]string{}
+	}
+	deployment.Spec.Template.Annotations[RestartedAtAnnotation] = time.Now().Format(time.RFC3339)
+	if _, err := clientset.AppsV1().Deployments(ns).Update(deployment); err != nil {
+		return "", nil, err
+	}
+	return endpoint.URL(), crt, nil
+}
+
+// generatedIssuer matches the name of the CA that signs certificates that are generated by installation
+var generatedIssuer = regexp.MustCompile(`-ca@[0-9]+$`)
+
+// IsGeneratedCertificate is true if installation generated the certificate so it can be rotated by installation
+func IsGeneratedCertificate(crt []byte) bool {
+	parsed, err := parseCertificate(crt)
+	if err != nil {
+		return false
+	}
+	return generatedIssuer.MatchString(parsed.Issuer.CommonName)
+}
+
+func parseCertificate(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("failed to decode certificate")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
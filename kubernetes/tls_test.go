@@ -0,0 +1,332 @@
+package kubeclient_test
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	kubeclient "github.com/magneticio/vampkubistcli/kubernetes"
+	"github.com/magneticio/vampkubistcli/models"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/util/cert"
+)
+
+func tlsTestConfig(tlsConfig *models.TLSConfig) models.VampConfig {
+	config := kubeclient.DefaultVampConfig
+	config.RootPassword = "root"
+	config.RepoUsername = "user"
+	config.RepoPassword = "pass"
+	config.DatabaseUrl = "mongodb://mongo-0.vamp-mongodb:27017"
+	config.TLS = tlsConfig
+	return config
+}
+
+func certificateEnv(t *testing.T, container corev1.Container) (*corev1.SecretKeySelector, *corev1.SecretKeySelector) {
+	var crt, key *corev1.SecretKeySelector
+	for _, env := range container.Env {
+		switch env.Name {
+		case "API_SERVER_CERTIFICATE":
+			crt = env.ValueFrom.SecretKeyRef
+		case "API_PRIVATE_KEY":
+			key = env.ValueFrom.SecretKeyRef
+		}
+	}
+	if crt == nil || key == nil {
+		t.Fatalf("Certificate environment is missing in %v", container.Env)
+	}
+	return crt, key
+}
+
+func TestInstallVampWithExistingSecret(t *testing.T) {
+	crt, key, _ := cert.GenerateSelfSignedCertKey("vamp.example.com", []net.IP{}, []string{})
+	ns := kubeclient.InstallationNamespace
+	clientset := newFakeClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "vamp-cert", Namespace: ns},
+		Data:       map[string][]byte{corev1.TLSCertKey: crt, corev1.TLSPrivateKeyKey: key},
+		Type:       corev1.SecretTypeTLS,
+	})
+	config := tlsTestConfig(&models.TLSConfig{SecretName: "vamp-cert", DNSNames: []string{"vamp.example.com"}})
+
+	url, installedCert, _, err := kubeclient.InstallVamp(clientset, ns, &config)
+	if err != nil {
+		t.Fatalf("InstallVamp returned error: %v", err)
+	}
+	if *url != "https://vamp.example.com:8888" {
+		t.Errorf("Expected url of the DNS name, got %v", *url)
+	}
+	if !bytes.Equal(crt, installedCert) {
+		t.Error("Certificate of the existing secret should be returned")
+	}
+	if _, err := clientset.CoreV1().Secrets(ns).Get("certificates-for-10.0.0.1", metav1.GetOptions{}); err == nil {
+		t.Error("Self-signed certificate should not be generated")
+	}
+
+	deployment, err := clientset.AppsV1().Deployments(ns).Get("vamp", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Vamp deployment is not created - %v", err)
+	}
+	crtRef, keyRef := certificateEnv(t, deployment.Spec.Template.Spec.Containers[0])
+	if crtRef.Name != "vamp-cert" || crtRef.Key != corev1.TLSCertKey || keyRef.Name != "vamp-cert" || keyRef.Key != corev1.TLSPrivateKeyKey {
+		t.Errorf("Deployment should use the existing secret, got %+v %+v", crtRef, keyRef)
+	}
+	secretName, err := kubeclient.GetCertificateSecretName(clientset, ns, config.ReleaseName)
+	if err != nil || secretName != "vamp-cert" {
+		t.Errorf("Expected certificate secret vamp-cert, got %v %v", secretName, err)
+	}
+}
+
+func TestInstallVampWithMissingSecret(t *testing.T) {
+	clientset := newFakeClientset()
+	config := tlsTestConfig(&models.TLSConfig{SecretName: "missing"})
+	if _, _, _, err := kubeclient.InstallVamp(clientset, kubeclient.InstallationNamespace, &config); err == nil {
+		t.Error("InstallVamp should fail if the certificate secret doesn't exist")
+	}
+}
+
+func TestInstallVampWithCertificateFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	crt, key, _ := cert.GenerateSelfSignedCertKey("vamp.example.com", []net.IP{}, []string{})
+	_, otherKey, _ := cert.GenerateSelfSignedCertKey("other.example.com", []net.IP{}, []string{})
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	otherKeyFile := filepath.Join(dir, "other.key")
+	ioutil.WriteFile(certFile, crt, 0600)
+	ioutil.WriteFile(keyFile, key, 0600)
+	ioutil.WriteFile(otherKeyFile, otherKey, 0600)
+
+	ns := kubeclient.InstallationNamespace
+	clientset := newFakeClientset()
+	config := tlsTestConfig(&models.TLSConfig{CertificateFile: certFile, KeyFile: otherKeyFile})
+	if _, _, _, err := kubeclient.InstallVamp(clientset, ns, &config); err == nil || !strings.Contains(err.Error(), "not a valid pair") {
+		t.Errorf("Certificate with the key of another certificate should be rejected, got %v", err)
+	}
+
+	config.TLS.KeyFile = keyFile
+	url, installedCert, _, err := kubeclient.InstallVamp(clientset, ns, &config)
+	if err != nil {
+		t.Fatalf("InstallVamp returned error: %v", err)
+	}
+	if *url != "https://10.0.0.1:8888" || !bytes.Equal(crt, installedCert) {
+		t.Errorf("Unexpected url %v or certificate", *url)
+	}
+	secret, err := clientset.CoreV1().Secrets(ns).Get("vamp-tls", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Certificate secret is not created - %v", err)
+	}
+	if secret.Type != corev1.SecretTypeTLS || !bytes.Equal(secret.Data[corev1.TLSPrivateKeyKey], key) {
+		t.Errorf("Unexpected certificate secret %v", secret.Type)
+	}
+	deployment, _ := clientset.AppsV1().Deployments(ns).Get("vamp", metav1.GetOptions{})
+	crtRef, _ := certificateEnv(t, deployment.Spec.Template.Spec.Containers[0])
+	if crtRef.Name != "vamp-tls" || crtRef.Key != corev1.TLSCertKey {
+		t.Errorf("Deployment should use the certificate secret, got %+v", crtRef)
+	}
+}
+
+func TestValidateTLSConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *models.TLSConfig
+		valid  bool
+	}{
+		{"none", nil, true},
+		{"dns names", &models.TLSConfig{DNSNames: []string{"vamp.example.com", "*.example.com"}}, true},
+		{"invalid dns name", &models.TLSConfig{DNSNames: []string{"Vamp_Example"}}, false},
+		{"secret", &models.TLSConfig{SecretName: "vamp-cert"}, true},
+		{"files", &models.TLSConfig{CertificateFile: "tls.crt", KeyFile: "tls.key"}, true},
+		{"missing key file", &models.TLSConfig{CertificateFile: "tls.crt"}, false},
+		{"issuer", &models.TLSConfig{Issuer: &models.IssuerReference{Name: "letsencrypt", Kind: "ClusterIssuer"}}, true},
+		{"issuer without name", &models.TLSConfig{Issuer: &models.IssuerReference{}}, false},
+		{"unknown issuer kind", &models.TLSConfig{Issuer: &models.IssuerReference{Name: "ca", Kind: "Vault"}}, false},
+		{"secret and issuer", &models.TLSConfig{SecretName: "vamp-cert", Issuer: &models.IssuerReference{Name: "ca"}}, false},
+	}
+	for _, test := range tests {
+		err := kubeclient.ValidateTLSConfig(test.config)
+		if test.valid && err != nil {
+			t.Errorf("%v: unexpected error %v", test.name, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%v: expected an error", test.name)
+		}
+	}
+
+	issuer := &models.TLSConfig{Issuer: &models.IssuerReference{Name: "ca"}}
+	kubeclient.ValidateTLSConfig(issuer)
+	if issuer.Issuer.Kind != "Issuer" {
+		t.Errorf("Issuer kind should default to Issuer, got %v", issuer.Issuer.Kind)
+	}
+}
+
+func TestRenderVampManifestsWithIssuer(t *testing.T) {
+	config := tlsTestConfig(&models.TLSConfig{
+		DNSNames: []string{"vamp.example.com"},
+		Issuer:   &models.IssuerReference{Name: "letsencrypt", Kind: "ClusterIssuer"},
+	})
+	manifests, crt, err := kubeclient.RenderVampManifests(&config, "10.0.0.1")
+	if err != nil {
+		t.Fatalf("RenderVampManifests returned error: %v", err)
+	}
+	if crt != nil {
+		t.Error("Certificate of an issuer is not known before installation")
+	}
+	var certificate *kubeclient.Manifest
+	for i := range manifests {
+		if strings.Contains(manifests[i].FileName, "-certificate-") {
+			certificate = &manifests[i]
+		}
+		if strings.Contains(manifests[i].FileName, "certificates-for") {
+			t.Errorf("Self-signed certificate should not be rendered, got %v", manifests[i].FileName)
+		}
+	}
+	if certificate == nil {
+		t.Fatal("Certificate manifest is not rendered")
+	}
+	if certificate.FileName != "05-certificate-vamp-tls.yaml" {
+		t.Errorf("Unexpected file name %v", certificate.FileName)
+	}
+	var parsed unstructured.Unstructured
+	if err := yaml.Unmarshal(certificate.Data, &parsed.Object); err != nil {
+		t.Fatalf("Cannot parse certificate manifest: %v", err)
+	}
+	secretName, _, _ := unstructured.NestedString(parsed.Object, "spec", "secretName")
+	issuerKind, _, _ := unstructured.NestedString(parsed.Object, "spec", "issuerRef", "kind")
+	names, _, _ := unstructured.NestedStringSlice(parsed.Object, "spec", "dnsNames")
+	ips, _, _ := unstructured.NestedStringSlice(parsed.Object, "spec", "ipAddresses")
+	if parsed.GetAPIVersion() != kubeclient.CertManagerAPIVersion || parsed.GetNamespace() != kubeclient.InstallationNamespace {
+		t.Errorf("Unexpected certificate %v %v", parsed.GetAPIVersion(), parsed.GetNamespace())
+	}
+	if secretName != "vamp-tls" || issuerKind != "ClusterIssuer" || len(names) != 1 || names[0] != "vamp.example.com" || len(ips) != 1 || ips[0] != "10.0.0.1" {
+		t.Errorf("Unexpected certificate spec %v", parsed.Object["spec"])
+	}
+	if !strings.Contains(string(manifests[len(manifests)-2].Data), "vamp.example.com:8888") {
+		t.Error("External host of the deployment should be the DNS name")
+	}
+}
+
+func TestRotateCertificates(t *testing.T) {
+	ns := kubeclient.InstallationNamespace
+	clientset := newFakeClientset()
+	config := tlsTestConfig(&models.TLSConfig{DNSNames: []string{"vamp.example.com"}})
+	_, installedCert, _, err := kubeclient.InstallVamp(clientset, ns, &config)
+	if err != nil {
+		t.Fatalf("InstallVamp returned error: %v", err)
+	}
+
+	url, rotatedCert, err := kubeclient.RotateCertificates(clientset, ns, config.ReleaseName)
+	if err != nil {
+		t.Fatalf("RotateCertificates returned error: %v", err)
+	}
+	if url != "https://vamp.example.com:8888" {
+		t.Errorf("Expected url of the external host, got %v", url)
+	}
+	if bytes.Equal(installedCert, rotatedCert) {
+		t.Error("Certificate should be renewed")
+	}
+	secret, err := clientset.CoreV1().Secrets(ns).Get("certificates-for-10.0.0.1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Certificate secret is missing - %v", err)
+	}
+	if !bytes.Equal(secret.Data["cert"], rotatedCert) {
+		t.Error("Secret should have the rotated certificate")
+	}
+	block, _ := pem.Decode(rotatedCert)
+	parsed, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("Cannot parse rotated certificate: %v", err)
+	}
+	if err := parsed.VerifyHostname("10.0.0.1"); err != nil {
+		t.Errorf("IP of the previous certificate should be kept - %v", err)
+	}
+	if err := parsed.VerifyHostname("vamp.example.com"); err != nil {
+		t.Errorf("DNS name of the previous certificate should be kept - %v", err)
+	}
+	deployment, _ := clientset.AppsV1().Deployments(ns).Get("vamp", metav1.GetOptions{})
+	if deployment.Spec.Template.Annotations[kubeclient.RestartedAtAnnotation] == "" {
+		t.Error("Deployment should be restarted")
+	}
+}
+
+func TestRotateCertificatesAndWait(t *testing.T) {
+	ns := kubeclient.InstallationNamespace
+	clientset := newFakeClientset()
+	config := tlsTestConfig(nil)
+	if _, _, _, err := kubeclient.InstallVamp(clientset, ns, &config); err != nil {
+		t.Fatalf("InstallVamp returned error: %v", err)
+	}
+	var pinged []byte
+	url, rotatedCert, err := kubeclient.RotateCertificatesAndWait(clientset, ns, config.ReleaseName, func(url string, cert []byte) error {
+		pinged = cert
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("RotateCertificatesAndWait returned error: %v", err)
+	}
+	if url != "https://10.0.0.1:8888" || !bytes.Equal(pinged, rotatedCert) {
+		t.Errorf("Vamp should be pinged with the rotated certificate at %v", url)
+	}
+}
+
+func TestRotateCertificatesOfClusterInternalVamp(t *testing.T) {
+	ns := kubeclient.InstallationNamespace
+	clientset := newFakeClientset()
+	config := tlsTestConfig(nil)
+	config.ServiceType = kubeclient.ServiceTypeClusterIP
+	if _, _, _, err := kubeclient.InstallVamp(clientset, ns, &config); err != nil {
+		t.Fatalf("InstallVamp returned error: %v", err)
+	}
+	url, _, err := kubeclient.RotateCertificatesAndWait(clientset, ns, config.ReleaseName, func(url string, cert []byte) error {
+		t.Errorf("Vamp that is only reachable in the cluster should not be pinged at %v", url)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("RotateCertificatesAndWait returned error: %v", err)
+	}
+	if url != "https://vamp.vamp-system.svc:8888" {
+		t.Errorf("Expected url of the service, got %v", url)
+	}
+}
+
+func TestRotateCertificatesOfExistingSecret(t *testing.T) {
+	crt, key, _ := cert.GenerateSelfSignedCertKey("vamp.example.com", []net.IP{}, []string{})
+	ns := kubeclient.InstallationNamespace
+	clientset := newFakeClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "vamp-cert", Namespace: ns},
+		Data:       map[string][]byte{corev1.TLSCertKey: crt, corev1.TLSPrivateKeyKey: key},
+		Type:       corev1.SecretTypeTLS,
+	})
+	config := tlsTestConfig(&models.TLSConfig{SecretName: "vamp-cert"})
+	if _, _, _, err := kubeclient.InstallVamp(clientset, ns, &config); err != nil {
+		t.Fatalf("InstallVamp returned error: %v", err)
+	}
+	if _, _, err := kubeclient.RotateCertificates(clientset, ns, config.ReleaseName); err == nil {
+		t.Error("Certificates that are not generated by installation should not be rotated")
+	}
+}
+
+func TestIsGeneratedCertificate(t *testing.T) {
+	generated, _, _ := cert.GenerateSelfSignedCertKey("vamp.example.com", []net.IP{}, []string{})
+	if !kubeclient.IsGeneratedCertificate(generated) {
+		t.Error("Certificate of installation should be recognized")
+	}
+	key, _ := cert.NewPrivateKey()
+	issued, _ := cert.NewSelfSignedCACert(cert.Config{CommonName: "vamp.example.com"}, key)
+	if kubeclient.IsGeneratedCertificate(cert.EncodeCertPEM(issued)) {
+		t.Error("Certificate that is not generated by installation should not be recognized")
+	}
+	if kubeclient.IsGeneratedCertificate([]byte("not a certificate")) {
+		t.Error("Invalid certificate should not be recognized")
+	}
+}
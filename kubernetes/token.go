@@ -0,0 +1,182 @@
+package kubeclient
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DefaultTokenTimeout is how long bootstrap waits for the token controller to populate a token secret
+const DefaultTokenTimeout = 2 * time.Minute
+
+// RootCAConfigMap is the config map with the certificate of the API server that is published in every namespace
+const RootCAConfigMap = "kube-root-ca.crt"
+
+// BootstrapOptions configure the service account of vamp and how its token is obtained
+type BootstrapOptions struct {
+	RBAC string
+	// TokenDuration requests a token that expires after the duration with the TokenRequest API,
+	// otherwise the token of a service account token secret is used which doesn't expire
+	TokenDuration time.Duration
+	// RefreshCredentials replaces the token of the service account, previous token secrets are deleted
+	RefreshCredentials bool
+}
+
+// tokenSecretName is the name of the token secret that bootstrap creates for a service account
+func tokenSecretName(serviceAccount string) string {
+	return serviceAccount + "-vamp-token"
+}
+
+/*
+ServiceAccountCredentials returns the certificate of the API server and a token of the service account.
+With a token duration the token is requested with the TokenRequest API. Otherwise a token secret that was
+created for the service account is used, clusters since Kubernetes 1.24 don't create them so a token secret
+is created and populated by the token controller. Refresh deletes the token secrets of the service account first,
+including those that the cluster created, so their tokens are revoked.
+*/
+func ServiceAccountCredentials(clientset kubernetes.Interface, ns string, serviceAccount string, options BootstrapOptions) (string, string, error) {
+	sa, err := clientset.CoreV1().ServiceAccounts(ns).Get(serviceAccount, metav1.GetOptions{})
+	if err != nil {
+		return "", "", err
+	}
+	if options.TokenDuration > 0 {
+		return requestToken(clientset, ns, sa, options.TokenDuration)
+	}
+	if !options.RefreshCredentials {
+		for _, reference := range sa.Secrets {
+			secret, err := clientset.CoreV1().Secrets(ns).Get(reference.Name, metav1.GetOptions{})
+			if err == nil && len(secret.Data[corev1.ServiceAccountTokenKey]) > 0 {
+				return string(secret.Data[corev1.ServiceAccountRootCAKey]), string(secret.Data[corev1.ServiceAccountTokenKey]), nil
+			}
+		}
+	}
+	if options.RefreshCredentials {
+		if err := deleteTokenSecrets(clientset, ns, sa); err != nil {
+			return "", "", err
+		}
+	}
+	secret, err := createTokenSecret(clientset, ns, serviceAccount)
+	if err != nil {
+		return "", "", err
+	}
+	return string(secret.Data[corev1.ServiceAccountRootCAKey]), string(secret.Data[corev1.ServiceAccountTokenKey]), nil
+}
+
+// requestToken requests a token that expires after the duration, the certificate is read from the root CA config map
+func requestToken(clientset kubernetes.Interface, ns string, sa *corev1.ServiceAccount, duration time.Duration) (string, string, error) {
+	crt := ""
+	if configMap, err := clientset.CoreV1().ConfigMaps(ns).Get(RootCAConfigMap, metav1.GetOptions{}); err == nil {
+		crt = configMap.Data[corev1.ServiceAccountRootCAKey]
+	} else if !k8serrors.IsNotFound(err) {
+		return "", "", err
+	}
+	if crt == "" {
+		// clusters before Kubernetes 1.20 don't publish the certificate, it is part of token secrets
+		for _, reference := range sa.Secrets {
+			if secret, err := clientset.CoreV1().Secrets(ns).Get(reference.Name, metav1.GetOptions{}); err == nil && len(secret.Data[corev1.ServiceAccountRootCAKey]) > 0 {
+				crt = string(secret.Data[corev1.ServiceAccountRootCAKey])
+				break
+			}
+		}
+	}
+	if crt == "" {
+		return "", "", fmt.Errorf("Certificate of the API server is not found in config map %v of namespace %v", RootCAConfigMap, ns)
+	}
+	expirationSeconds := int64(duration.Seconds())
+	tokenRequest, err := clientset.CoreV1().ServiceAccounts(ns).CreateToken(sa.Name, &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{ExpirationSeconds: &expirationSeconds},
+	})
+	if err != nil {
+		return "", "", err
+	}
+	if tokenRequest.Status.Token == "" {
+		return "", "", fmt.Errorf("Token of service account %v is empty", sa.Name)
+	}
+	fmt.Printf("Token of service account %v expires at %v\n", sa.Name, tokenRequest.Status.ExpirationTimestamp.Format(time.RFC3339))
+	return crt, tokenRequest.Status.Token, nil
+}
+
+// deleteTokenSecrets deletes the token secret of bootstrap and the token secrets of the service account that the cluster created
+func deleteTokenSecrets(clientset kubernetes.Interface, ns string, sa *corev1.ServiceAccount) error {
+	names := []string{tokenSecretName(sa.Name)}
+	for _, reference := range sa.Secrets {
+		secret, err := clientset.CoreV1().Secrets(ns).Get(reference.Name, metav1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		// image pull secrets can be listed too
+		if secret.Type == corev1.SecretTypeServiceAccountToken && secret.Name != names[0] {
+			names = append(names, secret.Name)
+		}
+	}
+	for _, name := range names {
+		if err := clientset.CoreV1().Secrets(ns).Delete(name, nil); err != nil && !k8serrors.IsNotFound(err) {
+			return err
+		}
+		// the token controller keeps the secret until it has revoked the token
+		if err := waitForSecretDeletion(clientset, ns, name, DefaultTokenTimeout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createTokenSecret creates a token secret for the service account and waits until it is populated
+func createTokenSecret(clientset kubernetes.Interface, ns string, serviceAccount string) (*corev1.Secret, error) {
+	name := tokenSecretName(serviceAccount)
+	secrets := clientset.CoreV1().Secrets(ns)
+	_, err := secrets.Create(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Annotations: map[string]string{corev1.ServiceAccountNameKey: serviceAccount},
+		},
+		Type: corev1.SecretTypeServiceAccountToken,
+	})
+	if err != nil && !k8serrors.IsAlreadyExists(err) {
+		return nil, err
+	}
+	return waitForTokenSecret(clientset, ns, name, DefaultTokenTimeout)
+}
+
+// waitForTokenSecret waits until the token controller has populated the token and certificate of a token secret
+func waitForTokenSecret(clientset kubernetes.Interface, ns string, name string, timeout time.Duration) (*corev1.Secret, error) {
+	var secret *corev1.Secret
+	operation := func() error {
+		fmt.Printf("Waiting for token secret %v\n", name)
+		current, err := clientset.CoreV1().Secrets(ns).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if len(current.Data[corev1.ServiceAccountTokenKey]) == 0 || len(current.Data[corev1.ServiceAccountRootCAKey]) == 0 {
+			return errors.New("Token secret is not populated yet")
+		}
+		secret = current
+		return nil
+	}
+	err := backoff.Retry(operation, timeoutBackOff(timeout))
+	return secret, err
+}
+
+func waitForSecretDeletion(clientset kubernetes.Interface, ns string, name string, timeout time.Duration) error {
+	operation := func() error {
+		_, err := clientset.CoreV1().Secrets(ns).Get(name, metav1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("Secret %v is not deleted yet", name)
+	}
+	return backoff.Retry(operation, timeoutBackOff(timeout))
+}
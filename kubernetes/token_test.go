@@ -0,0 +1,113 @@
+package kubeclient_test
+
+import (
+	"testing"
+	"time"
+
+	kubeclient "github.com/magneticio/vampkubistcli/kubernetes"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestServiceAccountCredentialsCreatesTokenSecret(t *testing.T) {
+	ns := kubeclient.InstallationNamespace
+	clientset := newFakeClientset(&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "vamp", Namespace: ns}})
+
+	crt, token, err := kubeclient.ServiceAccountCredentials(clientset, ns, "vamp", kubeclient.BootstrapOptions{})
+	if err != nil {
+		t.Fatalf("ServiceAccountCredentials returned error: %v", err)
+	}
+	if crt != "certificate" || token != "token-vamp-vamp-token" {
+		t.Errorf("Credentials of the token secret should be returned, got %v %v", crt, token)
+	}
+	secret, err := clientset.CoreV1().Secrets(ns).Get("vamp-vamp-token", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Token secret is not created - %v", err)
+	}
+	if secret.Type != corev1.SecretTypeServiceAccountToken || secret.Annotations[corev1.ServiceAccountNameKey] != "vamp" {
+		t.Errorf("Unexpected token secret %+v", secret)
+	}
+
+	// refresh deletes the token secret so its token is revoked and creates it again
+	clientset.ClearActions()
+	if _, _, err := kubeclient.ServiceAccountCredentials(clientset, ns, "vamp", kubeclient.BootstrapOptions{RefreshCredentials: true}); err != nil {
+		t.Fatalf("ServiceAccountCredentials with refresh returned error: %v", err)
+	}
+	deleted := false
+	for _, action := range clientset.Actions() {
+		if action.Matches("delete", "secrets") {
+			deleted = true
+		}
+	}
+	if !deleted {
+		t.Error("Token secret should be replaced when credentials are refreshed")
+	}
+}
+
+func TestServiceAccountCredentialsRefreshDeletesLegacyTokenSecrets(t *testing.T) {
+	ns := kubeclient.InstallationNamespace
+	clientset := newFakeClientset(
+		&corev1.ServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{Name: "vamp", Namespace: ns},
+			Secrets:    []corev1.ObjectReference{{Name: "vamp-token-abcde"}, {Name: "registry"}},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "vamp-token-abcde", Namespace: ns, Annotations: map[string]string{corev1.ServiceAccountNameKey: "vamp"}},
+			Type:       corev1.SecretTypeServiceAccountToken,
+			Data:       map[string][]byte{"ca.crt": []byte("certificate"), "token": []byte("legacy")},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "registry", Namespace: ns},
+			Type:       corev1.SecretTypeDockerConfigJson,
+		},
+	)
+
+	_, token, err := kubeclient.ServiceAccountCredentials(clientset, ns, "vamp", kubeclient.BootstrapOptions{RefreshCredentials: true})
+	if err != nil {
+		t.Fatalf("ServiceAccountCredentials with refresh returned error: %v", err)
+	}
+	if token != "token-vamp-vamp-token" {
+		t.Errorf("Token of the new token secret should be returned, got %v", token)
+	}
+	if _, err := clientset.CoreV1().Secrets(ns).Get("vamp-token-abcde", metav1.GetOptions{}); err == nil {
+		t.Error("Token secret that the cluster created should be deleted so its token is revoked")
+	}
+	if _, err := clientset.CoreV1().Secrets(ns).Get("registry", metav1.GetOptions{}); err != nil {
+		t.Errorf("Secrets that are not tokens should be kept - %v", err)
+	}
+}
+
+func TestServiceAccountCredentialsRequestsToken(t *testing.T) {
+	ns := kubeclient.InstallationNamespace
+	clientset := newFakeClientset(
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "vamp", Namespace: ns}},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: kubeclient.RootCAConfigMap, Namespace: ns},
+			Data:       map[string]string{"ca.crt": "certificate"},
+		},
+	)
+	var expirationSeconds int64
+	clientset.PrependReactor("create", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "token" {
+			return false, nil, nil
+		}
+		request := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenRequest)
+		expirationSeconds = *request.Spec.ExpirationSeconds
+		request.Status = authenticationv1.TokenRequestStatus{Token: "requested", ExpirationTimestamp: metav1.Now()}
+		return true, request, nil
+	})
+
+	crt, token, err := kubeclient.ServiceAccountCredentials(clientset, ns, "vamp", kubeclient.BootstrapOptions{TokenDuration: time.Hour})
+	if err != nil {
+		t.Fatalf("ServiceAccountCredentials returned error: %v", err)
+	}
+	if crt != "certificate" || token != "requested" || expirationSeconds != 3600 {
+		t.Errorf("Unexpected credentials %v %v expiring in %v seconds", crt, token, expirationSeconds)
+	}
+	if secrets, _ := clientset.CoreV1().Secrets(ns).List(metav1.ListOptions{}); len(secrets.Items) != 0 {
+		t.Error("Requested tokens should not create token secrets")
+	}
+}
@@ -0,0 +1,362 @@
+package kubeclient
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2beta1 "k8s.io/api/autoscaling/v2beta1"
+	corev1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// UninstallOptions configure what uninstall removes
+type UninstallOptions struct {
+	ReleaseName string
+	// DryRun only plans the uninstall
+	DryRun bool
+	// KeepData keeps the namespace with the volumes and credentials of the database and the certificate secrets
+	KeepData bool
+	// BackupDir is the directory that removed objects are exported to before they are deleted
+	BackupDir string
+}
+
+/*
+UninstallPlan lists the objects that uninstall deletes and keeps.
+Objects in a deleted namespace are deleted with the namespace.
+*/
+type UninstallPlan struct {
+	Namespace string
+	Delete    []runtime.Object
+	InDeleted []runtime.Object
+	Keep      []runtime.Object
+}
+
+// ObjectName returns the kind and name of an object
+func ObjectName(object runtime.Object) string {
+	name := ""
+	if accessor, err := meta.Accessor(object); err == nil {
+		name = accessor.GetName()
+	}
+	kind := "unknown"
+	if kinds, _, err := scheme.Scheme.ObjectKinds(object); err == nil {
+		kind = strings.ToLower(kinds[0].Kind)
+	}
+	return kind + "/" + name
+}
+
+/*
+PlanUninstall finds the objects of an installation. The namespace and the cluster role bindings are deleted,
+or if data is kept, only the objects of the release are deleted and the namespace is kept with its volumes
+and certificate secrets. If other releases are installed in the namespace, the namespace and the bindings are
+shared with them so only the objects of the release and its data are deleted.
+Objects that can not be read are reported in the error, the plan is always returned
+so uninstall can continue with what is found.
+*/
+func PlanUninstall(clientset kubernetes.Interface, ns string, options UninstallOptions) (*UninstallPlan, error) {
+	plan := &UninstallPlan{Namespace: ns}
+	var errs []error
+	objects, listErrs := namespaceObjects(clientset, ns)
+	errs = append(errs, listErrs...)
+	names := NamesOf(options.ReleaseName)
+	if others := otherReleases(objects, names); len(others) > 0 {
+		fmt.Printf("Releases %v are installed in namespace %v too, only the objects of release %v are deleted\n",
+			strings.Join(others, ", "), ns, names.Release)
+		planRelease(plan, objects, names, !options.KeepData)
+		return plan, utilerrors.NewAggregate(errs)
+	}
+
+	// objects that can not be read are still deleted by name
+	planDelete := func(object runtime.Object, name string, err error) {
+		if k8serrors.IsNotFound(err) {
+			return
+		}
+		if err != nil {
+			errs = append(errs, err)
+			meta.NewAccessor().SetName(object, name)
+		}
+		plan.Delete = append(plan.Delete, object)
+	}
+	for _, name := range []string{ClusterRoleBindingName(ns), MinimalClusterRoleBindingName(ns)} {
+		crb, err := clientset.RbacV1().ClusterRoleBindings().Get(name, metav1.GetOptions{})
+		if err != nil {
+			crb = &rbacv1.ClusterRoleBinding{}
+		}
+		planDelete(crb, name, err)
+	}
+	role, err := clientset.RbacV1().ClusterRoles().Get(MinimalClusterRoleName(ns), metav1.GetOptions{})
+	if err != nil {
+		role = &rbacv1.ClusterRole{}
+	}
+	planDelete(role, MinimalClusterRoleName(ns), err)
+	if !options.KeepData {
+		namespace, err := clientset.CoreV1().Namespaces().Get(ns, metav1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			// a missing installation is reported but its bindings are still deleted
+			errs = append(errs, err)
+		} else {
+			if err != nil {
+				namespace = &corev1.Namespace{}
+			}
+			planDelete(namespace, ns, err)
+		}
+		plan.InDeleted = objects
+		return plan, utilerrors.NewAggregate(errs)
+	}
+	planRelease(plan, objects, names, false)
+	return plan, utilerrors.NewAggregate(errs)
+}
+
+// otherReleases returns the names of vamp deployments of other releases, they select their pods by the release name
+func otherReleases(objects []runtime.Object, names ReleaseNames) []string {
+	others := []string{}
+	for _, object := range objects {
+		deployment, ok := object.(*appsv1.Deployment)
+		if !ok || deployment.Name == names.Release || deployment.Spec.Selector == nil {
+			continue
+		}
+		selector := deployment.Spec.Selector.MatchLabels
+		if selector["app"] == deployment.Name && selector["deployment"] == deployment.Name {
+			others = append(others, deployment.Name)
+		}
+	}
+	return others
+}
+
+/*
+planRelease plans the deletion of the objects of a release. Its data, the volumes and credentials of the database
+and its generated certificate, is deleted or kept. Certificates that other releases use are kept.
+*/
+func planRelease(plan *UninstallPlan, objects []runtime.Object, names ReleaseNames, deleteData bool) {
+	release := map[string]bool{}
+	for _, name := range []string{
+		"deployment/" + names.Release,
+		"horizontalpodautoscaler/" + names.Release,
+		"statefulset/" + names.MongoStatefulSet,
+		"service/" + names.Release,
+		"service/" + names.Hazelcast,
+		"service/" + names.MongoDB,
+		"ingress/" + names.Release,
+		"secret/" + names.ImagePull,
+		"secret/" + names.RootPassword,
+	} {
+		release[name] = true
+	}
+	data := map[string]bool{
+		"secret/" + names.MongoCredentials: true,
+		"secret/" + names.Release + "-tls": true,
+	}
+	shared := map[string]bool{}
+	for _, object := range objects {
+		if deployment, ok := object.(*appsv1.Deployment); ok {
+			// secrets that are brought along are not data of the release
+			certSecret, _, err := deploymentCertificate(deployment)
+			if err == nil && strings.HasPrefix(certSecret.Name, certificatesSecretName("")) {
+				if deployment.Name == names.Release {
+					data["secret/"+certSecret.Name] = true
+				} else {
+					shared["secret/"+certSecret.Name] = true
+				}
+			}
+		}
+	}
+	volumes := "persistentvolumeclaim/mongo-persistent-storage-" + names.MongoStatefulSet + "-"
+	for _, object := range objects {
+		name := ObjectName(object)
+		switch {
+		case release[name]:
+			plan.Delete = append(plan.Delete, object)
+		case (data[name] || strings.HasPrefix(name, volumes)) && deleteData && !shared[name]:
+			plan.Delete = append(plan.Delete, object)
+		case data[name], strings.HasPrefix(name, volumes):
+			plan.Keep = append(plan.Keep, object)
+		}
+	}
+}
+
+/*
+namespaceObjects lists the kinds of objects that installations create in a namespace and their volumes.
+Kinds that the cluster doesn't serve, like extensions/v1beta1 ingresses since Kubernetes 1.22, have no objects.
+*/
+func namespaceObjects(clientset kubernetes.Interface, ns string) ([]runtime.Object, []error) {
+	objects := []runtime.Object{}
+	var errs []error
+	listed := func(kind string, err error) bool {
+		if err != nil && !k8serrors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("%v can not be listed - %v", kind, err))
+		}
+		return err == nil
+	}
+	listOptions := metav1.ListOptions{}
+	if list, err := clientset.AppsV1().Deployments(ns).List(listOptions); listed("deployments", err) {
+		for i := range list.Items {
+			objects = append(objects, &list.Items[i])
+		}
+	}
+	if list, err := clientset.AutoscalingV2beta1().HorizontalPodAutoscalers(ns).List(listOptions); listed("horizontal pod autoscalers", err) {
+		for i := range list.Items {
+			objects = append(objects, &list.Items[i])
+		}
+	}
+	if list, err := clientset.AppsV1().StatefulSets(ns).List(listOptions); listed("stateful sets", err) {
+		for i := range list.Items {
+			objects = append(objects, &list.Items[i])
+		}
+	}
+	if list, err := clientset.CoreV1().Services(ns).List(listOptions); listed("services", err) {
+		for i := range list.Items {
+			objects = append(objects, &list.Items[i])
+		}
+	}
+	if list, err := clientset.ExtensionsV1beta1().Ingresses(ns).List(listOptions); listed("ingresses", err) {
+		for i := range list.Items {
+			objects = append(objects, &list.Items[i])
+		}
+	}
+	if list, err := clientset.CoreV1().Secrets(ns).List(listOptions); listed("secrets", err) {
+		for i := range list.Items {
+			objects = append(objects, &list.Items[i])
+		}
+	}
+	if list, err := clientset.CoreV1().PersistentVolumeClaims(ns).List(listOptions); listed("persistent volume claims", err) {
+		for i := range list.Items {
+			objects = append(objects, &list.Items[i])
+		}
+	}
+	return objects, errs
+}
+
+/*
+UninstallVampServiceWithOptions plans and runs an uninstall with the kube config, a dry run only returns the plan.
+Errors of planning and deleting are returned together.
+*/
+func UninstallVampServiceWithOptions(configPath string, ns string, options UninstallOptions) (*UninstallPlan, error) {
+	clientset, err := K8sClient.Get(configPath)
+	if err != nil {
+		return nil, err
+	}
+	plan, planErr := PlanUninstall(clientset, ns, options)
+	if options.DryRun {
+		return plan, planErr
+	}
+	return plan, utilerrors.NewAggregate([]error{planErr, Uninstall(clientset, plan, options)})
+}
+
+/*
+Uninstall deletes the objects of the plan after they are exported to the backup directory.
+It continues after failures and returns them together.
+*/
+func Uninstall(clientset kubernetes.Interface, plan *UninstallPlan, options UninstallOptions) error {
+	if options.BackupDir != "" {
+		if err := BackupObjects(options.BackupDir, plan.Namespace, append(append(append([]runtime.Object{}, plan.Delete...), plan.InDeleted...), plan.Keep...)); err != nil {
+			return fmt.Errorf("Backup failed, nothing is deleted - %v", err)
+		}
+	}
+	var errs []error
+	for _, object := range plan.Delete {
+		fmt.Printf("Deleting %v\n", ObjectName(object))
+		if err := deleteObject(clientset, plan.Namespace, object); err != nil && !k8serrors.IsNotFound(err) {
+			fmt.Printf("Warning: %v can not be deleted - %v\n", ObjectName(object), err)
+			errs = append(errs, fmt.Errorf("%v: %v", ObjectName(object), err))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func deleteObject(clientset kubernetes.Interface, ns string, object runtime.Object) error {
+	accessor, err := meta.Accessor(object)
+	if err != nil {
+		return err
+	}
+	name := accessor.GetName()
+	switch object.(type) {
+	case *corev1.Namespace:
+		return clientset.CoreV1().Namespaces().Delete(name, nil)
+	case *rbacv1.ClusterRoleBinding:
+		return clientset.RbacV1().ClusterRoleBindings().Delete(name, nil)
+	case *rbacv1.ClusterRole:
+		return clientset.RbacV1().ClusterRoles().Delete(name, nil)
+	case *appsv1.Deployment:
+		return clientset.AppsV1().Deployments(ns).Delete(name, nil)
+	case *autoscalingv2beta1.HorizontalPodAutoscaler:
+		return clientset.AutoscalingV2beta1().HorizontalPodAutoscalers(ns).Delete(name, nil)
+	case *appsv1.StatefulSet:
+		return clientset.AppsV1().StatefulSets(ns).Delete(name, nil)
+	case *corev1.Service:
+		return clientset.CoreV1().Services(ns).Delete(name, nil)
+	case *extensionsv1beta1.Ingress:
+		return clientset.ExtensionsV1beta1().Ingresses(ns).Delete(name, nil)
+	case *corev1.Secret:
+		return clientset.CoreV1().Secrets(ns).Delete(name, nil)
+	case *corev1.PersistentVolumeClaim:
+		return clientset.CoreV1().PersistentVolumeClaims(ns).Delete(name, nil)
+	}
+	return fmt.Errorf("%v can not be deleted", ObjectName(object))
+}
+
+/*
+BackupObjects exports objects as manifests into the directory so they can be applied again.
+Fields that the cluster sets are removed, volumes of claims are not exported.
+*/
+func BackupObjects(dir string, ns string, objects []runtime.Object) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	for i, object := range objects {
+		copied := object.DeepCopyObject()
+		if err := clearClusterFields(copied); err != nil {
+			return err
+		}
+		manifest, err := renderManifest(i, ns, copied)
+		if err != nil {
+			return err
+		}
+		// backups contain secrets so they are only readable by the user
+		if err := ioutil.WriteFile(filepath.Join(dir, manifest.FileName), manifest.Data, 0600); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("%v objects are exported to %v\n", len(objects), dir)
+	return nil
+}
+
+func clearClusterFields(object runtime.Object) error {
+	accessor, err := meta.Accessor(object)
+	if err != nil {
+		return err
+	}
+	accessor.SetResourceVersion("")
+	accessor.SetUID("")
+	accessor.SetSelfLink("")
+	accessor.SetCreationTimestamp(metav1.Time{})
+	accessor.SetGeneration(0)
+	switch o := object.(type) {
+	case *appsv1.Deployment:
+		o.Status = appsv1.DeploymentStatus{}
+	case *appsv1.StatefulSet:
+		o.Status = appsv1.StatefulSetStatus{}
+	case *autoscalingv2beta1.HorizontalPodAutoscaler:
+		o.Status = autoscalingv2beta1.HorizontalPodAutoscalerStatus{}
+	case *corev1.Service:
+		o.Spec.ClusterIP = ""
+		o.Status = corev1.ServiceStatus{}
+	case *extensionsv1beta1.Ingress:
+		o.Status = extensionsv1beta1.IngressStatus{}
+	case *corev1.PersistentVolumeClaim:
+		o.Status = corev1.PersistentVolumeClaimStatus{}
+	case *corev1.Namespace:
+		o.Status = corev1.NamespaceStatus{}
+	}
+	return nil
+}
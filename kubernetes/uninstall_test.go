@@ -0,0 +1,265 @@
+package kubeclient_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	kubeclient "github.com/magneticio/vampkubistcli/kubernetes"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func installForUninstall(t *testing.T) *fake.Clientset {
+	ns := kubeclient.InstallationNamespace
+	clientset := newFakeClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: ns}},
+		&rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: kubeclient.ClusterRoleBindingName(ns)}},
+		&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "mongo-persistent-storage-mongo-0", Namespace: ns}},
+	)
+	config := tlsTestConfig(nil)
+	if err := kubeclient.InstallMongoDB(clientset, ns, &config); err != nil {
+		t.Fatalf("InstallMongoDB returned error: %v", err)
+	}
+	if _, _, _, err := kubeclient.InstallVamp(clientset, ns, &config); err != nil {
+		t.Fatalf("InstallVamp returned error: %v", err)
+	}
+	return clientset
+}
+
+func objectNames(objects []runtime.Object) []string {
+	names := make([]string, len(objects))
+	for i, object := range objects {
+		names[i] = kubeclient.ObjectName(object)
+	}
+	return names
+}
+
+func TestPlanUninstall(t *testing.T) {
+	ns := kubeclient.InstallationNamespace
+	clientset := installForUninstall(t)
+
+	plan, err := kubeclient.PlanUninstall(clientset, ns, kubeclient.UninstallOptions{})
+	if err != nil {
+		t.Fatalf("PlanUninstall returned error: %v", err)
+	}
+	deleted := objectNames(plan.Delete)
+	if len(deleted) != 2 || !contains(deleted, "namespace/"+ns) || !contains(deleted, "clusterrolebinding/"+kubeclient.ClusterRoleBindingName(ns)) {
+		t.Errorf("Namespace and cluster role binding should be deleted, got %v", deleted)
+	}
+	inDeleted := objectNames(plan.InDeleted)
+	for _, name := range []string{"deployment/vamp", "statefulset/mongo", "persistentvolumeclaim/mongo-persistent-storage-mongo-0", "secret/certificates-for-10.0.0.1"} {
+		if !contains(inDeleted, name) {
+			t.Errorf("%v should be deleted with the namespace, got %v", name, inDeleted)
+		}
+	}
+	if len(plan.Keep) != 0 {
+		t.Errorf("Nothing should be kept, got %v", objectNames(plan.Keep))
+	}
+
+	// a dry run doesn't delete anything
+	kubeclient.K8sClient = fakeK8sClientProvider{clientset: clientset}
+	if _, err := kubeclient.UninstallVampServiceWithOptions("", ns, kubeclient.UninstallOptions{DryRun: true}); err != nil {
+		t.Fatalf("Dry run returned error: %v", err)
+	}
+	if _, err := clientset.CoreV1().Namespaces().Get(ns, metav1.GetOptions{}); err != nil {
+		t.Errorf("Dry run should keep the namespace - %v", err)
+	}
+}
+
+func TestUninstallKeepData(t *testing.T) {
+	ns := kubeclient.InstallationNamespace
+	clientset := installForUninstall(t)
+	options := kubeclient.UninstallOptions{KeepData: true}
+
+	plan, err := kubeclient.PlanUninstall(clientset, ns, options)
+	if err != nil {
+		t.Fatalf("PlanUninstall returned error: %v", err)
+	}
+	kept := objectNames(plan.Keep)
+	if len(kept) != 2 || !contains(kept, "persistentvolumeclaim/mongo-persistent-storage-mongo-0") || !contains(kept, "secret/certificates-for-10.0.0.1") {
+		t.Errorf("Volumes and certificates should be kept, got %v", kept)
+	}
+	if err := kubeclient.Uninstall(clientset, plan, options); err != nil {
+		t.Fatalf("Uninstall returned error: %v", err)
+	}
+	if _, err := clientset.AppsV1().Deployments(ns).Get("vamp", metav1.GetOptions{}); err == nil {
+		t.Error("Deployment should be deleted")
+	}
+	if _, err := clientset.AppsV1().StatefulSets(ns).Get("mongo", metav1.GetOptions{}); err == nil {
+		t.Error("Stateful set should be deleted")
+	}
+	if _, err := clientset.CoreV1().Secrets(ns).Get("vamprootpassword", metav1.GetOptions{}); err == nil {
+		t.Error("Root password should be deleted")
+	}
+	if _, err := clientset.CoreV1().Namespaces().Get(ns, metav1.GetOptions{}); err != nil {
+		t.Errorf("Namespace should be kept - %v", err)
+	}
+	if _, err := clientset.CoreV1().PersistentVolumeClaims(ns).Get("mongo-persistent-storage-mongo-0", metav1.GetOptions{}); err != nil {
+		t.Errorf("Volume claim should be kept - %v", err)
+	}
+	if _, err := clientset.CoreV1().Secrets(ns).Get("certificates-for-10.0.0.1", metav1.GetOptions{}); err != nil {
+		t.Errorf("Certificate should be kept - %v", err)
+	}
+}
+
+func TestUninstallReleaseNextToOtherReleases(t *testing.T) {
+	ns := kubeclient.InstallationNamespace
+	clientset := installForUninstall(t)
+	if _, err := clientset.CoreV1().PersistentVolumeClaims(ns).Create(&corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "mongo-persistent-storage-staging-mongo-0", Namespace: ns},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	config := tlsTestConfig(nil)
+	config.ReleaseName = "staging"
+	if err := kubeclient.InstallMongoDB(clientset, ns, &config); err != nil {
+		t.Fatalf("InstallMongoDB returned error: %v", err)
+	}
+	if _, _, _, err := kubeclient.InstallVamp(clientset, ns, &config); err != nil {
+		t.Fatalf("InstallVamp returned error: %v", err)
+	}
+	options := kubeclient.UninstallOptions{ReleaseName: "staging"}
+
+	plan, err := kubeclient.PlanUninstall(clientset, ns, options)
+	if err != nil {
+		t.Fatalf("PlanUninstall returned error: %v", err)
+	}
+	deleted := objectNames(plan.Delete)
+	for _, name := range []string{"deployment/staging", "statefulset/staging-mongo", "service/staging-mongodb", "persistentvolumeclaim/mongo-persistent-storage-staging-mongo-0"} {
+		if !contains(deleted, name) {
+			t.Errorf("%v should be deleted, got %v", name, deleted)
+		}
+	}
+	for _, name := range []string{"namespace/" + ns, "clusterrolebinding/" + kubeclient.ClusterRoleBindingName(ns), "deployment/vamp", "persistentvolumeclaim/mongo-persistent-storage-mongo-0", "secret/certificates-for-10.0.0.1"} {
+		if contains(deleted, name) {
+			t.Errorf("%v is shared with the other release and should not be deleted", name)
+		}
+	}
+	if len(plan.InDeleted) != 0 {
+		t.Errorf("Nothing should be deleted with the namespace, got %v", objectNames(plan.InDeleted))
+	}
+	if err := kubeclient.Uninstall(clientset, plan, options); err != nil {
+		t.Fatalf("Uninstall returned error: %v", err)
+	}
+	if _, err := clientset.AppsV1().Deployments(ns).Get("vamp", metav1.GetOptions{}); err != nil {
+		t.Errorf("Other release should be kept - %v", err)
+	}
+	if _, err := clientset.AppsV1().StatefulSets(ns).Get("mongo", metav1.GetOptions{}); err != nil {
+		t.Errorf("Database of the other release should be kept - %v", err)
+	}
+	if _, err := clientset.AppsV1().Deployments(ns).Get("staging", metav1.GetOptions{}); err == nil {
+		t.Error("Deployment of the release should be deleted")
+	}
+}
+
+func TestUninstallBackup(t *testing.T) {
+	ns := kubeclient.InstallationNamespace
+	clientset := installForUninstall(t)
+	dir, err := ioutil.TempDir("", "uninstall")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	options := kubeclient.UninstallOptions{BackupDir: filepath.Join(dir, "backup")}
+
+	plan, err := kubeclient.PlanUninstall(clientset, ns, options)
+	if err != nil {
+		t.Fatalf("PlanUninstall returned error: %v", err)
+	}
+	if err := kubeclient.Uninstall(clientset, plan, options); err != nil {
+		t.Fatalf("Uninstall returned error: %v", err)
+	}
+	files, err := ioutil.ReadDir(options.BackupDir)
+	if err != nil {
+		t.Fatalf("Backup directory is not created - %v", err)
+	}
+	if len(files) != len(plan.Delete)+len(plan.InDeleted) {
+		t.Errorf("Expected %v backup files, got %v", len(plan.Delete)+len(plan.InDeleted), len(files))
+	}
+	found := false
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), "-service-vamp.yaml") {
+			continue
+		}
+		found = true
+		data, err := ioutil.ReadFile(filepath.Join(options.BackupDir, file.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		service := corev1.Service{}
+		if err := yaml.Unmarshal(data, &service); err != nil {
+			t.Fatal(err)
+		}
+		if service.Namespace != ns || service.ResourceVersion != "" || service.Spec.ClusterIP != "" || len(service.Status.LoadBalancer.Ingress) != 0 {
+			t.Errorf("Fields set by the cluster should be removed from %+v", service)
+		}
+	}
+	if !found {
+		t.Error("Service should be exported")
+	}
+}
+
+func TestUninstallContinuesAfterFailures(t *testing.T) {
+	ns := kubeclient.InstallationNamespace
+	clientset := installForUninstall(t)
+	clientset.PrependReactor("delete", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("deployment is protected")
+	})
+	clientset.PrependReactor("delete", "services", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("service is protected")
+	})
+	options := kubeclient.UninstallOptions{KeepData: true}
+
+	plan, err := kubeclient.PlanUninstall(clientset, ns, options)
+	if err != nil {
+		t.Fatalf("PlanUninstall returned error: %v", err)
+	}
+	err = kubeclient.Uninstall(clientset, plan, options)
+	if err == nil || !strings.Contains(err.Error(), "deployment is protected") || !strings.Contains(err.Error(), "service is protected") {
+		t.Fatalf("Every failure should be reported, got %v", err)
+	}
+	if _, err := clientset.AppsV1().StatefulSets(ns).Get("mongo", metav1.GetOptions{}); err == nil {
+		t.Error("Stateful set should be deleted after failures")
+	}
+	if _, err := clientset.RbacV1().ClusterRoleBindings().Get(kubeclient.ClusterRoleBindingName(ns), metav1.GetOptions{}); err == nil {
+		t.Error("Cluster role binding should be deleted after failures")
+	}
+}
+
+func TestUninstallContinuesAfterListFailures(t *testing.T) {
+	ns := kubeclient.InstallationNamespace
+	clientset := installForUninstall(t)
+	// clusters since Kubernetes 1.22 don't serve extensions/v1beta1 ingresses
+	clientset.PrependReactor("list", "ingresses", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, k8serrors.NewNotFound(schema.GroupResource{Group: "extensions", Resource: "ingresses"}, "")
+	})
+	clientset.PrependReactor("list", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("secrets are forbidden")
+	})
+	clientset.PrependReactor("get", "clusterrolebindings", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("bindings are forbidden")
+	})
+	kubeclient.K8sClient = fakeK8sClientProvider{clientset: clientset}
+
+	_, err := kubeclient.UninstallVampServiceWithOptions("", ns, kubeclient.UninstallOptions{})
+	if err == nil || !strings.Contains(err.Error(), "secrets are forbidden") || strings.Contains(err.Error(), "ingresses") {
+		t.Fatalf("List failures should be reported except unserved kinds, got %v", err)
+	}
+	if _, err := clientset.CoreV1().Namespaces().Get(ns, metav1.GetOptions{}); err == nil {
+		t.Error("Namespace should be deleted after list failures")
+	}
+	if list, _ := clientset.RbacV1().ClusterRoleBindings().List(metav1.ListOptions{}); len(list.Items) != 0 {
+		t.Errorf("Cluster role bindings that can not be read should be deleted by name, got %v", list.Items)
+	}
+}
@@ -0,0 +1,268 @@
+package kubeclient
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2beta1 "k8s.io/api/autoscaling/v2beta1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DefaultUpgradeTimeout is how long an upgrade waits for the new version to become healthy before it is rolled back
+const DefaultUpgradeTimeout = 10 * time.Minute
+
+// vampContainerName is the name of the vamp container in the pods of the deployment
+const vampContainerName = "vamp"
+
+// UpgradeOptions configure an upgrade of vamp
+type UpgradeOptions struct {
+	ImageTag string
+	// BackendVersion is the version of vamp that the client is tested with
+	BackendVersion string
+	// Force skips compatibility checks
+	Force   bool
+	Timeout time.Duration
+}
+
+// Snapshot keeps the objects of an installation that are restored if an upgrade fails
+type Snapshot struct {
+	Deployment *appsv1.Deployment
+	HPA        *autoscalingv2beta1.HorizontalPodAutoscaler
+	Secrets    []*corev1.Secret
+}
+
+/*
+CheckUpgradeCompatibility checks that the image tag is a version the client supports.
+The major version should be the same as the backend version and the minor version shouldn't be newer.
+Downgrades and upgrades to the current version are not allowed.
+*/
+func CheckUpgradeCompatibility(currentTag string, targetTag string, backendVersion string) error {
+	target, err := version.ParseGeneric(targetTag)
+	if err != nil {
+		return fmt.Errorf("Image tag %v is not a version - %v", targetTag, err)
+	}
+	backend, err := version.ParseGeneric(backendVersion)
+	if err != nil {
+		return fmt.Errorf("Backend version %v is not a version - %v", backendVersion, err)
+	}
+	if target.Major() != backend.Major() || target.Minor() > backend.Minor() {
+		return fmt.Errorf("Image tag %v is not compatible with this client which supports vamp up to %v, update the client first", targetTag, backendVersion)
+	}
+	current, err := version.ParseGeneric(currentTag)
+	if err != nil {
+		// installations with tags that are not versions can be upgraded to any version
+		fmt.Printf("Warning: current image tag %v is not a version\n", currentTag)
+		return nil
+	}
+	if target.LessThan(current) {
+		return fmt.Errorf("Image tag %v is older than the current image tag %v", targetTag, currentTag)
+	}
+	if !current.LessThan(target) {
+		return fmt.Errorf("Vamp is already running image tag %v", currentTag)
+	}
+	return nil
+}
+
+// TakeSnapshot copies the deployment, autoscaler and secrets of an installation
+func TakeSnapshot(clientset kubernetes.Interface, ns string, releaseName string) (*Snapshot, error) {
+	names := NamesOf(releaseName)
+	deployment, err := clientset.AppsV1().Deployments(ns).Get(names.Release, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &Snapshot{Deployment: deployment.DeepCopy()}
+	hpa, err := clientset.AutoscalingV2beta1().HorizontalPodAutoscalers(ns).Get(names.Release, metav1.GetOptions{})
+	if err == nil {
+		snapshot.HPA = hpa.DeepCopy()
+	} else if !k8serrors.IsNotFound(err) {
+		return nil, err
+	}
+	secretNames := []string{names.ImagePull, names.RootPassword}
+	if certSecret, _, certErr := deploymentCertificate(deployment); certErr == nil {
+		secretNames = append(secretNames, certSecret.Name)
+	}
+	for _, name := range secretNames {
+		secret, err := clientset.CoreV1().Secrets(ns).Get(name, metav1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		snapshot.Secrets = append(snapshot.Secrets, secret.DeepCopy())
+	}
+	return snapshot, nil
+}
+
+// RestoreSnapshot updates the objects of an installation to their state in the snapshot
+func RestoreSnapshot(clientset kubernetes.Interface, ns string, snapshot *Snapshot) error {
+	for _, secret := range snapshot.Secrets {
+		restored := secret.DeepCopy()
+		current, err := clientset.CoreV1().Secrets(ns).Get(restored.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		restored.ResourceVersion = current.ResourceVersion
+		if _, err := clientset.CoreV1().Secrets(ns).Update(restored); err != nil {
+			return err
+		}
+	}
+	if snapshot.HPA != nil {
+		restored := snapshot.HPA.DeepCopy()
+		current, err := clientset.AutoscalingV2beta1().HorizontalPodAutoscalers(ns).Get(restored.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		restored.ResourceVersion = current.ResourceVersion
+		if _, err := clientset.AutoscalingV2beta1().HorizontalPodAutoscalers(ns).Update(restored); err != nil {
+			return err
+		}
+	}
+	restored := snapshot.Deployment.DeepCopy()
+	current, err := clientset.AppsV1().Deployments(ns).Get(restored.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	restored.ResourceVersion = current.ResourceVersion
+	_, err = clientset.AppsV1().Deployments(ns).Update(restored)
+	return err
+}
+
+// WaitForDeploymentReady waits until every replica of the deployment is updated and ready
+func WaitForDeploymentReady(clientset kubernetes.Interface, ns string, name string, timeout time.Duration) error {
+	count := 1
+	operation := func() error {
+		fmt.Printf("Waiting for deployment %v trial %v\n", name, count)
+		count++
+		deployment, err := clientset.AppsV1().Deployments(ns).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		desired := derefInt32(deployment.Spec.Replicas, 1)
+		status := deployment.Status
+		if status.ObservedGeneration < deployment.Generation {
+			return errors.New("Deployment is not observed yet")
+		}
+		if status.UpdatedReplicas < desired || status.ReadyReplicas < desired || status.Replicas > status.UpdatedReplicas {
+			return fmt.Errorf("%v of %v replicas are updated and %v are ready", status.UpdatedReplicas, desired, status.ReadyReplicas)
+		}
+		return nil
+	}
+	return backoff.Retry(operation, timeoutBackOff(timeout))
+}
+
+func timeoutBackOff(timeout time.Duration) backoff.BackOff {
+	b := backoff.NewExponentialBackOff()
+	b.MaxElapsedTime = timeout
+	return b
+}
+
+// UpgradeVamp upgrades the installation of a release with the kube config
+func UpgradeVamp(configPath string, ns string, releaseName string, options UpgradeOptions) (string, error) {
+	clientset, err := K8sClient.Get(configPath)
+	if err != nil {
+		return "", err
+	}
+	return Upgrade(clientset, ns, releaseName, options, PingVamp)
+}
+
+/*
+Upgrade updates the image tag of the vamp deployment with a rolling update.
+The deployment, autoscaler and secrets are kept in a snapshot before the update. If the deployment isn't ready
+or the API doesn't answer within the timeout, the snapshot is restored and an error is returned.
+*/
+func Upgrade(clientset kubernetes.Interface, ns string, releaseName string, options UpgradeOptions, ping PingFunc) (string, error) {
+	if options.Timeout <= 0 {
+		options.Timeout = DefaultUpgradeTimeout
+	}
+	snapshot, err := TakeSnapshot(clientset, ns, releaseName)
+	if err != nil {
+		return "", err
+	}
+	container, err := vampContainer(snapshot.Deployment)
+	if err != nil {
+		return "", err
+	}
+	currentTag := imageTag(container.Image)
+	if !options.Force {
+		if err := CheckUpgradeCompatibility(currentTag, options.ImageTag, options.BackendVersion); err != nil {
+			return "", err
+		}
+	}
+	certSecret, endpoint, err := deploymentCertificate(snapshot.Deployment)
+	if err != nil {
+		return "", err
+	}
+	crt, _, err := readCertificateSecret(clientset, ns, certSecret)
+	if err != nil {
+		return "", err
+	}
+	crt, err = clientCertificate(clientset, ns, releaseName, crt)
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Printf("Upgrading %v from %v to %v\n", snapshot.Deployment.Name, currentTag, options.ImageTag)
+	deadline := time.Now().Add(options.Timeout)
+	upgradeErr := updateImageTag(clientset, ns, snapshot.Deployment.Name, options.ImageTag)
+	if upgradeErr == nil {
+		upgradeErr = WaitForDeploymentReady(clientset, ns, snapshot.Deployment.Name, time.Until(deadline))
+	}
+	if upgradeErr == nil && endpoint.Host != serviceHost(releaseName, ns) {
+		// vamp that is only reachable in the cluster is not pinged
+		upgradeErr = waitForService(endpoint.URL(), crt, ping, timeoutBackOff(time.Until(deadline)))
+	}
+	if upgradeErr == nil {
+		return endpoint.URL(), nil
+	}
+
+	fmt.Printf("Warning: upgrade failed, rolling back to %v - %v\n", currentTag, upgradeErr)
+	if restoreErr := RestoreSnapshot(clientset, ns, snapshot); restoreErr != nil {
+		return "", fmt.Errorf("Upgrade to %v failed - %v, rollback failed - %v", options.ImageTag, upgradeErr, restoreErr)
+	}
+	if waitErr := WaitForDeploymentReady(clientset, ns, snapshot.Deployment.Name, options.Timeout); waitErr != nil {
+		fmt.Printf("Warning: rolled back deployment is not ready - %v\n", waitErr)
+	}
+	return "", fmt.Errorf("Upgrade to %v failed and is rolled back to %v - %v", options.ImageTag, currentTag, upgradeErr)
+}
+
+// updateImageTag sets the tag of the image of the vamp container
+func updateImageTag(clientset kubernetes.Interface, ns string, name string, tag string) error {
+	deployment, err := clientset.AppsV1().Deployments(ns).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	container, err := vampContainer(deployment)
+	if err != nil {
+		return err
+	}
+	image := container.Image
+	if i := strings.LastIndex(image, ":"); i >= 0 && !strings.Contains(image[i:], "/") {
+		image = image[:i]
+	}
+	container.Image = image + ":" + tag
+	_, err = clientset.AppsV1().Deployments(ns).Update(deployment)
+	return err
+}
+
+// vampContainer returns the vamp container of a deployment, a deployment with a single container has no other
+func vampContainer(deployment *appsv1.Deployment) (*corev1.Container, error) {
+	containers := deployment.Spec.Template.Spec.Containers
+	for i := range containers {
+		if containers[i].Name == vampContainerName {
+			return &containers[i], nil
+		}
+	}
+	if len(containers) == 1 {
+		return &containers[0], nil
+	}
+	return nil, fmt.Errorf("Deployment %v doesn't have a %v container", deployment.Name, vampContainerName)
+}
@@ -0,0 +1,185 @@
+package kubeclient_test
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	kubeclient "github.com/magneticio/vampkubistcli/kubernetes"
+	"github.com/magneticio/vampkubistcli/models"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/util/cert"
+)
+
+func TestCheckUpgradeCompatibility(t *testing.T) {
+	tests := []struct {
+		current string
+		target  string
+		valid   bool
+	}{
+		{"0.7.13", "0.7.14", true},
+		{"0.7.13", "0.8.17", true},
+		{"0.7.13", "v0.8.0", true},
+		{"latest", "0.8.0", true},
+		{"0.7.13", "0.9.0", false},
+		{"0.7.13", "1.0.0", false},
+		{"0.7.13", "0.7.12", false},
+		{"0.7.13", "0.7.13", false},
+		{"0.7.13", "latest", false},
+	}
+	for _, test := range tests {
+		err := kubeclient.CheckUpgradeCompatibility(test.current, test.target, "0.8.17")
+		if test.valid && err != nil {
+			t.Errorf("%v to %v: unexpected error %v", test.current, test.target, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%v to %v: expected an error", test.current, test.target)
+		}
+	}
+}
+
+func installReadyVamp(t *testing.T) *fake.Clientset {
+	ns := kubeclient.InstallationNamespace
+	clientset := newFakeClientset()
+	config := tlsTestConfig(nil)
+	if _, _, _, err := kubeclient.InstallVamp(clientset, ns, &config); err != nil {
+		t.Fatalf("InstallVamp returned error: %v", err)
+	}
+	deployment, _ := clientset.AppsV1().Deployments(ns).Get("vamp", metav1.GetOptions{})
+	deployment.Status.Replicas = 3
+	deployment.Status.UpdatedReplicas = 3
+	deployment.Status.ReadyReplicas = 3
+	if _, err := clientset.AppsV1().Deployments(ns).Update(deployment); err != nil {
+		t.Fatal(err)
+	}
+	return clientset
+}
+
+func deploymentImage(t *testing.T, clientset *fake.Clientset) string {
+	deployment, err := clientset.AppsV1().Deployments(kubeclient.InstallationNamespace).Get("vamp", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return deployment.Spec.Template.Spec.Containers[0].Image
+}
+
+func TestUpgrade(t *testing.T) {
+	clientset := installReadyVamp(t)
+	options := kubeclient.UpgradeOptions{ImageTag: "0.8.0", BackendVersion: "0.8.17", Timeout: time.Second}
+	pinged := ""
+	url, err := kubeclient.Upgrade(clientset, kubeclient.InstallationNamespace, "", options, func(url string, cert []byte) error {
+		pinged = url
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Upgrade returned error: %v", err)
+	}
+	if url != "https://10.0.0.1:8888" || pinged != url {
+		t.Errorf("Upgraded service should be pinged, got %v %v", url, pinged)
+	}
+	if image := deploymentImage(t, clientset); image != "magneticio/vampkubist:0.8.0" {
+		t.Errorf("Unexpected image %v", image)
+	}
+
+	options.ImageTag = "0.9.0"
+	if _, err := kubeclient.Upgrade(clientset, kubeclient.InstallationNamespace, "", options, nil); err == nil {
+		t.Error("Incompatible version should not be installed")
+	}
+	if image := deploymentImage(t, clientset); image != "magneticio/vampkubist:0.8.0" {
+		t.Errorf("Deployment should not be changed by incompatible upgrades, got %v", image)
+	}
+}
+
+func TestUpgradeWithSidecar(t *testing.T) {
+	ns := kubeclient.InstallationNamespace
+	clientset := installReadyVamp(t)
+	deployment, _ := clientset.AppsV1().Deployments(ns).Get("vamp", metav1.GetOptions{})
+	containers := deployment.Spec.Template.Spec.Containers
+	deployment.Spec.Template.Spec.Containers = append([]corev1.Container{{Name: "istio-proxy", Image: "istio/proxyv2:1.1.0"}}, containers...)
+	if _, err := clientset.AppsV1().Deployments(ns).Update(deployment); err != nil {
+		t.Fatal(err)
+	}
+
+	options := kubeclient.UpgradeOptions{ImageTag: "0.8.0", BackendVersion: "0.8.17", Timeout: time.Second}
+	if _, err := kubeclient.Upgrade(clientset, ns, "", options, func(url string, cert []byte) error { return nil }); err != nil {
+		t.Fatalf("Upgrade returned error: %v", err)
+	}
+	deployment, _ = clientset.AppsV1().Deployments(ns).Get("vamp", metav1.GetOptions{})
+	images := []string{}
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		images = append(images, container.Image)
+	}
+	if len(images) != 2 || images[0] != "istio/proxyv2:1.1.0" || images[1] != "magneticio/vampkubist:0.8.0" {
+		t.Errorf("Only the vamp container should be upgraded, got %v", images)
+	}
+
+	deployment.Spec.Template.Spec.Containers = nil
+	if _, err := clientset.AppsV1().Deployments(ns).Update(deployment); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kubeclient.Upgrade(clientset, ns, "", options, nil); err == nil {
+		t.Error("Deployment without a vamp container should not be upgraded")
+	}
+}
+
+func TestUpgradePingsIngressCertificate(t *testing.T) {
+	ingressCrt, ingressKey, _ := cert.GenerateSelfSignedCertKey("vamp.example.com", []net.IP{}, []string{})
+	ns := kubeclient.InstallationNamespace
+	clientset := newFakeClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "vamp-ingress-tls", Namespace: ns},
+		Data:       map[string][]byte{corev1.TLSCertKey: ingressCrt, corev1.TLSPrivateKeyKey: ingressKey},
+		Type:       corev1.SecretTypeTLS,
+	})
+	config := tlsTestConfig(nil)
+	config.ServiceType = kubeclient.ServiceTypeClusterIP
+	config.Ingress = &models.IngressConfig{Host: "vamp.example.com", SecretName: "vamp-ingress-tls"}
+	if _, _, _, err := kubeclient.InstallVamp(clientset, ns, &config); err != nil {
+		t.Fatalf("InstallVamp returned error: %v", err)
+	}
+	deployment, _ := clientset.AppsV1().Deployments(ns).Get("vamp", metav1.GetOptions{})
+	deployment.Status.Replicas = 3
+	deployment.Status.UpdatedReplicas = 3
+	deployment.Status.ReadyReplicas = 3
+	if _, err := clientset.AppsV1().Deployments(ns).Update(deployment); err != nil {
+		t.Fatal(err)
+	}
+	var pinged []byte
+	ping := func(url string, cert []byte) error {
+		pinged = cert
+		return nil
+	}
+
+	options := kubeclient.UpgradeOptions{ImageTag: "0.8.0", BackendVersion: "0.8.17", Timeout: time.Second}
+	if _, err := kubeclient.Upgrade(clientset, ns, "", options, ping); err != nil {
+		t.Fatalf("Upgrade returned error: %v", err)
+	}
+	if !bytes.Equal(pinged, ingressCrt) {
+		t.Error("Upgrade should verify the certificate of the ingress")
+	}
+
+	pinged = nil
+	status := kubeclient.GetInstallationStatus(clientset, ns, "", ping)
+	if check := findCheck(status, "ping"); check == nil || !check.Healthy || !bytes.Equal(pinged, ingressCrt) {
+		t.Errorf("Status should verify the certificate of the ingress %+v", check)
+	}
+}
+
+func TestUpgradeRollback(t *testing.T) {
+	clientset := installReadyVamp(t)
+	original := deploymentImage(t, clientset)
+	options := kubeclient.UpgradeOptions{ImageTag: "0.8.0", BackendVersion: "0.8.17", Timeout: time.Second}
+	_, err := kubeclient.Upgrade(clientset, kubeclient.InstallationNamespace, "", options, func(url string, cert []byte) error {
+		return errors.New("connection refused")
+	})
+	if err == nil || !strings.Contains(err.Error(), "rolled back") {
+		t.Fatalf("Upgrade should be rolled back, got %v", err)
+	}
+	if image := deploymentImage(t, clientset); image != original {
+		t.Errorf("Image should be rolled back to %v, got %v", original, image)
+	}
+}
@@ -0,0 +1,188 @@
+package kubeclient
+
+import (
+	"github.com/magneticio/vampkubistcli/logging"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DefaultNearLimitPercentage is the usage as a percentage of a limit that a pod is flagged as near its limits from
+const DefaultNearLimitPercentage = 90.0
+
+// NodeMetrics provides CPU and memory usage of a node and its usage as a percentage of allocatable resources
+type NodeMetrics struct {
+	Name              string
+	Labels            map[string]string
+	CPU               float64
+	Memory            float64
+	AllocatableCPU    float64
+	AllocatableMemory float64
+	CPUPercentage     float64
+	MemoryPercentage  float64
+}
+
+// ContainerUtilisation provides container usage with its requests and limits, percentages are 0 if there is no request or limit
+type ContainerUtilisation struct {
+	Name                    string
+	CPU                     float64
+	Memory                  float64
+	RequestCPU              float64
+	LimitCPU                float64
+	RequestMemory           float64
+	LimitMemory             float64
+	CPURequestPercentage    float64
+	CPULimitPercentage      float64
+	MemoryRequestPercentage float64
+	MemoryLimitPercentage   float64
+	NearLimit               bool
+}
+
+// PodUtilisation provides utilisation of containers of a pod, pod is near limits if any of its containers is
+type PodUtilisation struct {
+	Name       string
+	Namespace  string
+	Labels     map[string]string
+	Containers []ContainerUtilisation
+	NearLimit  bool
+}
+
+// GetNodeMetrics returns usage of nodes joined with their allocatable resources
+func GetNodeMetrics(configPath string, labelSelector string) ([]NodeMetrics, error) {
+	clientset, err := K8sClient.Get(configPath)
+	if err != nil {
+		return nil, err
+	}
+	metricsClient, err := K8sClient.Metrics(configPath)
+	if err != nil {
+		return nil, err
+	}
+	nodeMetrics, err := metricsClient.MetricsV1beta1().NodeMetricses().List(metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := clientset.CoreV1().Nodes().List(metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return nil, err
+	}
+	allocatable := make(map[string]v1.Node, len(nodes.Items))
+	for _, node := range nodes.Items {
+		allocatable[node.Name] = node
+	}
+
+	res := make([]NodeMetrics, len(nodeMetrics.Items))
+	for i, item := range nodeMetrics.Items {
+		res[i].Name = item.Name
+		res[i].Labels = item.Labels
+		if cpu, err := ConvertCPU(item.Usage.Cpu().String()); err == nil {
+			res[i].CPU = cpu
+		} else {
+			logging.Error("Conversion of CPU for %v failed - %v", item.Name, err)
+		}
+		if mem, err := ConvertMemory(item.Usage.Memory().String()); err == nil {
+			res[i].Memory = mem
+		} else {
+			logging.Error("Conversion of Memory for %v failed - %v", item.Name, err)
+		}
+		node, ok := allocatable[item.Name]
+		if !ok {
+			continue
+		}
+		if len(res[i].Labels) == 0 {
+			res[i].Labels = node.Labels
+		}
+		res[i].AllocatableCPU = cpuQuantity(node.Status.Allocatable, v1.ResourceCPU)
+		res[i].AllocatableMemory = memoryQuantity(node.Status.Allocatable, v1.ResourceMemory)
+		res[i].CPUPercentage = percentage(res[i].CPU, res[i].AllocatableCPU)
+		res[i].MemoryPercentage = percentage(res[i].Memory, res[i].AllocatableMemory)
+	}
+	return res, nil
+}
+
+// GetUtilisation joins usage of containers with requests and limits from pod specs of pods selected by options
+func GetUtilisation(configPath string, options MetricsOptions, nearLimitPercentage float64) ([]PodUtilisation, error) {
+	var pods PodMetricsList
+	if err := GetRawMetricsWithOptions(configPath, options, &pods); err != nil {
+		return nil, err
+	}
+	clientset, err := K8sClient.Get(configPath)
+	if err != nil {
+		return nil, err
+	}
+	specs, err := listPods(clientset, options)
+	if err != nil {
+		return nil, err
+	}
+	return CalculateUtilisation(&pods, specs, nearLimitPercentage), nil
+}
+
+// CalculateUtilisation calculates usage of containers as a percentage of their requests and limits
+func CalculateUtilisation(pods *PodMetricsList, specs map[string]v1.Pod, nearLimitPercentage float64) []PodUtilisation {
+	res := make([]PodUtilisation, 0, len(pods.Items))
+	for _, item := range pods.Items {
+		spec, ok := specs[item.Metadata.Namespace+"/"+item.Metadata.Name]
+		if !ok {
+			// the pod can be deleted after metrics are read
+			logging.Info("Pod %v is not found in namespace %v", item.Metadata.Name, item.Metadata.Namespace)
+			continue
+		}
+		resources := make(map[string]v1.ResourceRequirements, len(spec.Spec.Containers))
+		for _, container := range spec.Spec.Containers {
+			resources[container.Name] = container.Resources
+		}
+		pod := PodUtilisation{
+			Name:       item.Metadata.Name,
+			Namespace:  item.Metadata.Namespace,
+			Labels:     spec.Labels,
+			Containers: make([]ContainerUtilisation, len(item.Containers)),
+		}
+		for j, container := range item.Containers {
+			utilisation := &pod.Containers[j]
+			utilisation.Name = container.Name
+			if cpu, err := ConvertCPU(container.Usage.CPU); err == nil {
+				utilisation.CPU = cpu
+			} else {
+				logging.Error("Conversion of CPU for %v failed - %v", item.Metadata.Name, err)
+			}
+			if mem, err := ConvertMemory(container.Usage.Memory); err == nil {
+				utilisation.Memory = mem
+			} else {
+				logging.Error("Conversion of Memory for %v failed - %v", item.Metadata.Name, err)
+			}
+			requirements := resources[container.Name]
+			utilisation.RequestCPU = cpuQuantity(requirements.Requests, v1.ResourceCPU)
+			utilisation.LimitCPU = cpuQuantity(requirements.Limits, v1.ResourceCPU)
+			utilisation.RequestMemory = memoryQuantity(requirements.Requests, v1.ResourceMemory)
+			utilisation.LimitMemory = memoryQuantity(requirements.Limits, v1.ResourceMemory)
+			utilisation.CPURequestPercentage = percentage(utilisation.CPU, utilisation.RequestCPU)
+			utilisation.CPULimitPercentage = percentage(utilisation.CPU, utilisation.LimitCPU)
+			utilisation.MemoryRequestPercentage = percentage(utilisation.Memory, utilisation.RequestMemory)
+			utilisation.MemoryLimitPercentage = percentage(utilisation.Memory, utilisation.LimitMemory)
+			utilisation.NearLimit = utilisation.CPULimitPercentage >= nearLimitPercentage ||
+				utilisation.MemoryLimitPercentage >= nearLimitPercentage
+			pod.NearLimit = pod.NearLimit || utilisation.NearLimit
+		}
+		res = append(res, pod)
+	}
+	return res
+}
+
+func cpuQuantity(resources v1.ResourceList, name v1.ResourceName) float64 {
+	if q, ok := resources[name]; ok {
+		return float64(q.MilliValue()) / 1000
+	}
+	return 0
+}
+
+func memoryQuantity(resources v1.ResourceList, name v1.ResourceName) float64 {
+	if q, ok := resources[name]; ok {
+		return float64(q.Value())
+	}
+	return 0
+}
+
+func percentage(value float64, of float64) float64 {
+	if of == 0 {
+		return 0
+	}
+	return value * 100 / of
+}
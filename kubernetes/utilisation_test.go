@@ -0,0 +1,76 @@
+package kubeclient_test
+
+import (
+	"math"
+	"testing"
+
+	kubeclient "github.com/magneticio/vampkubistcli/kubernetes"
+)
+
+func TestGetNodeMetrics(t *testing.T) {
+	CreateMockedK8s(t, "metrics_test.json", false)
+
+	nodes, err := kubeclient.GetNodeMetrics("", "")
+	if err != nil {
+		t.Fatalf("GetNodeMetrics returned error: %v", err)
+	}
+	if len(nodes) != 2 {
+		t.Fatalf("Expected 2 nodes, got %v", nodes)
+	}
+	node := nodes[1]
+	if node.Name != "node-2" || node.CPU != 1.5 || node.AllocatableCPU != 2 {
+		t.Errorf("CPU of node isn't correct in %v", node)
+	}
+	if node.CPUPercentage != 75 || node.MemoryPercentage != 75 {
+		t.Errorf("Percentages of node aren't correct in %v", node)
+	}
+	if node.Labels["kubernetes.io/hostname"] != "node-2" {
+		t.Errorf("Labels of node should be taken from node list in %v", node)
+	}
+}
+
+func TestGetUtilisation(t *testing.T) {
+	CreateMockedK8s(t, "metrics_test.json", false)
+
+	pods, err := kubeclient.GetUtilisation("", kubeclient.MetricsOptions{Namespace: "vamp-system"}, kubeclient.DefaultNearLimitPercentage)
+	if err != nil {
+		t.Fatalf("GetUtilisation returned error: %v", err)
+	}
+	if len(pods) != 6 {
+		t.Fatalf("Expected 6 pods, got %v", len(pods))
+	}
+
+	nearLimit := map[string]bool{}
+	for _, pod := range pods {
+		nearLimit[pod.Name] = pod.NearLimit
+	}
+	expected := map[string]bool{
+		"vamp-6dc7f8cd87-47kdw": true,
+		"vamp-6dc7f8cd87-zrltl": true,
+		"vamp-6dc7f8cd87-ldj55": false,
+		"mongo-0":               false,
+		"mongo-1":               false,
+		"mongo-2":               false,
+	}
+	for name, near := range expected {
+		if nearLimit[name] != near {
+			t.Errorf("Near limit of %v should be %v", name, near)
+		}
+	}
+
+	vamp := pods[0].Containers[0]
+	if vamp.RequestCPU != 0.1 || vamp.LimitCPU != 0.5 || vamp.LimitMemory != 320*1024*1024 {
+		t.Errorf("Requests and limits aren't correct in %v", vamp)
+	}
+	if math.Abs(vamp.CPURequestPercentage-24.946926) > 1e-6 {
+		t.Errorf("CPU request percentage isn't correct in %v", vamp)
+	}
+	if math.Abs(vamp.MemoryLimitPercentage-float64(308068)*100/(320*1024)) > 1e-9 {
+		t.Errorf("Memory limit percentage isn't correct in %v", vamp)
+	}
+
+	sidecar := pods[2].Containers[1]
+	if sidecar.Name != "mongo-sidecar" || sidecar.CPURequestPercentage != 0 || sidecar.NearLimit {
+		t.Errorf("Container without requests and limits should have no percentages in %v", sidecar)
+	}
+}
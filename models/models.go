@@ -1,28 +1,74 @@
 package models
 
 type VampConfig struct {
-	RootPassword                      string `yaml:"rootPassword,omitempty" json:"rootPassword,omitempty"`
-	DatabaseUrl                       string `yaml:"databaseUrl,omitempty" json:"databaseUrl,omitempty"`
-	DatabaseName                      string `yaml:"databaseName,omitempty" json:"databaseName,omitempty"`
-	ImageName                         string `yaml:"imageName,omitempty" json:"imageName,omitempty"`
-	RepoUsername                      string `yaml:"repoUsername,omitempty" json:"repoUsername,omitempty"`
-	RepoPassword                      string `yaml:"repoPassword,omitempty" json:"repoPassword,omitempty"`
-	ImageTag                          string `yaml:"imageTag,omitempty" json:"imageTag,omitempty"`
-	Mode                              string `yaml:"mode,omitempty" json:"mode,omitempty"`
-	AccessTokenExpiration             string `yaml:"accessTokenExpiration,omitempty" json:"accessTokenExpiration,omitempty"`
-	IstioInstallerImage               string `yaml:"istioInstallerImage,omitempty" json:"istioInstallerImage,omitempty"`
-	IstioAdapterImage                 string `yaml:"istioAdapterImage,omitempty" json:"istioAdapterImage,omitempty"`
-	MinReplicas                       *int32 `yaml:"minReplicas,omitempty" json:"minReplicas,omitempty"`
-	MaxReplicas                       int32  `yaml:"maxReplicas,omitempty" json:"maxReplicas,omitempty"`
-	TargetCPUUtilizationPercentage    *int32 `yaml:"targetCPUUtilizationPercentage,omitempty" json:"targetCPUUtilizationPercentage,omitempty"`
-	TargetMemoryUtilizationPercentage *int32 `yaml:"targetMemoryUtilizationPercentage,omitempty" json:"targetMemoryUtilizationPercentage,omitempty"`
-	TargetCPUAverageValue             string `yaml:"targetCPUAverageValue,omitempty" json:"targetCPUAverageValue,omitempty"`
-	TargetMemoryAverageValue          string `yaml:"targetMemoryAverageValue,omitempty" json:"targetMemoryAverageValue,omitempty"`
-	RequestCPU                        string `yaml:"requestCPU,omitempty" json:"requestCPU,omitempty"`
-	RequestMemory                     string `yaml:"requestMemory,omitempty" json:"requestMemory,omitempty"`
-	LimitCPU                          string `yaml:"limitCPU,omitempty" json:"limitCPU,omitempty"`
-	LimitMemory                       string `yaml:"limitMemory,omitempty" json:"limitMemory,omitempty"`
-	EnableLogstash                    string `yaml:"enableLogstash,omitempty" json:"enableLogstash,omitempty"`
+	RootPassword                      string            `yaml:"rootPassword,omitempty" json:"rootPassword,omitempty"`
+	DatabaseUrl                       string            `yaml:"databaseUrl,omitempty" json:"databaseUrl,omitempty"`
+	DatabaseName                      string            `yaml:"databaseName,omitempty" json:"databaseName,omitempty"`
+	ImageName                         string            `yaml:"imageName,omitempty" json:"imageName,omitempty"`
+	RepoUsername                      string            `yaml:"repoUsername,omitempty" json:"repoUsername,omitempty"`
+	RepoPassword                      string            `yaml:"repoPassword,omitempty" json:"repoPassword,omitempty"`
+	ImageTag                          string            `yaml:"imageTag,omitempty" json:"imageTag,omitempty"`
+	Mode                              string            `yaml:"mode,omitempty" json:"mode,omitempty"`
+	AccessTokenExpiration             string            `yaml:"accessTokenExpiration,omitempty" json:"accessTokenExpiration,omitempty"`
+	IstioInstallerImage               string            `yaml:"istioInstallerImage,omitempty" json:"istioInstallerImage,omitempty"`
+	IstioAdapterImage                 string            `yaml:"istioAdapterImage,omitempty" json:"istioAdapterImage,omitempty"`
+	MinReplicas                       *int32            `yaml:"minReplicas,omitempty" json:"minReplicas,omitempty"`
+	MaxReplicas                       int32             `yaml:"maxReplicas,omitempty" json:"maxReplicas,omitempty"`
+	TargetCPUUtilizationPercentage    *int32            `yaml:"targetCPUUtilizationPercentage,omitempty" json:"targetCPUUtilizationPercentage,omitempty"`
+	TargetMemoryUtilizationPercentage *int32            `yaml:"targetMemoryUtilizationPercentage,omitempty" json:"targetMemoryUtilizationPercentage,omitempty"`
+	TargetCPUAverageValue             string            `yaml:"targetCPUAverageValue,omitempty" json:"targetCPUAverageValue,omitempty"`
+	TargetMemoryAverageValue          string            `yaml:"targetMemoryAverageValue,omitempty" json:"targetMemoryAverageValue,omitempty"`
+	RequestCPU                        string            `yaml:"requestCPU,omitempty" json:"requestCPU,omitempty"`
+	RequestMemory                     string            `yaml:"requestMemory,omitempty" json:"requestMemory,omitempty"`
+	LimitCPU                          string            `yaml:"limitCPU,omitempty" json:"limitCPU,omitempty"`
+	LimitMemory                       string            `yaml:"limitMemory,omitempty" json:"limitMemory,omitempty"`
+	EnableLogstash                    string            `yaml:"enableLogstash,omitempty" json:"enableLogstash,omitempty"`
+	Namespace                         string            `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+	ReleaseName                       string            `yaml:"releaseName,omitempty" json:"releaseName,omitempty"`
+	Labels                            map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+	Annotations                       map[string]string `yaml:"annotations,omitempty" json:"annotations,omitempty"`
+	TLS                               *TLSConfig        `yaml:"tls,omitempty" json:"tls,omitempty"`
+	ServiceType                       string            `yaml:"serviceType,omitempty" json:"serviceType,omitempty"`
+	Ingress                           *IngressConfig    `yaml:"ingress,omitempty" json:"ingress,omitempty"`
+	Mongo                             *MongoConfig      `yaml:"mongo,omitempty" json:"mongo,omitempty"`
+}
+
+// TLSConfig selects the certificate of the vamp API, a self-signed certificate is generated if no certificate is given
+type TLSConfig struct {
+	CertificateFile string           `yaml:"certificateFile,omitempty" json:"certificateFile,omitempty"`
+	KeyFile         string           `yaml:"keyFile,omitempty" json:"keyFile,omitempty"`
+	SecretName      string           `yaml:"secretName,omitempty" json:"secretName,omitempty"`
+	DNSNames        []string         `yaml:"dnsNames,omitempty" json:"dnsNames,omitempty"`
+	Issuer          *IssuerReference `yaml:"issuer,omitempty" json:"issuer,omitempty"`
+}
+
+// IssuerReference refers to a cert-manager Issuer or ClusterIssuer
+type IssuerReference struct {
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+	Kind string `yaml:"kind,omitempty" json:"kind,omitempty"`
+}
+
+// IngressConfig exposes vamp with an Ingress, TLS is passed through to vamp unless a secret is given
+type IngressConfig struct {
+	Host        string            `yaml:"host,omitempty" json:"host,omitempty"`
+	SecretName  string            `yaml:"secretName,omitempty" json:"secretName,omitempty"`
+	ClassName   string            `yaml:"className,omitempty" json:"className,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty" json:"annotations,omitempty"`
+}
+
+// MongoConfig configures the internal MongoDB, authentication is enabled if a username is given
+type MongoConfig struct {
+	Replicas      *int32 `yaml:"replicas,omitempty" json:"replicas,omitempty"`
+	Image         string `yaml:"image,omitempty" json:"image,omitempty"`
+	SidecarImage  string `yaml:"sidecarImage,omitempty" json:"sidecarImage,omitempty"`
+	StorageClass  string `yaml:"storageClass,omitempty" json:"storageClass,omitempty"`
+	StorageSize   string `yaml:"storageSize,omitempty" json:"storageSize,omitempty"`
+	RequestCPU    string `yaml:"requestCPU,omitempty" json:"requestCPU,omitempty"`
+	RequestMemory string `yaml:"requestMemory,omitempty" json:"requestMemory,omitempty"`
+	LimitCPU      string `yaml:"limitCPU,omitempty" json:"limitCPU,omitempty"`
+	LimitMemory   string `yaml:"limitMemory,omitempty" json:"limitMemory,omitempty"`
+	Username      string `yaml:"username,omitempty" json:"username,omitempty"`
+	Password      string `yaml:"password,omitempty" json:"password,omitempty"`
 }
 
 type ErrorResponse struct {
@@ -106,6 +152,29 @@ type ExperimentMetric struct {
 	Average           float64 `json:"average"`
 }
 
+type Experiment struct {
+	VampServiceName string                  `json:"vampServiceName"`
+	Period          *int                    `json:"period,omitempty"`
+	Step            *int                    `json:"step,omitempty"`
+	Destinations    []ExperimentDestination `json:"destinations"`
+}
+
+type ExperimentDestination struct {
+	Destination string   `json:"destination"`
+	Port        int64    `json:"port"`
+	Subset      string   `json:"subset"`
+	Target      string   `json:"target,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+type ExperimentSubsetMetric struct {
+	Destination string           `json:"destination"`
+	Port        int64            `json:"port"`
+	Subset      string           `json:"subset"`
+	MetricName  string           `json:"metricName"`
+	Metric      ExperimentMetric `json:"metric"`
+}
+
 type MetricValue struct {
 	Timestamp         int64   `yaml:"timestamp" json:"timestamp"`
 	NumberOfElements  int64   `yaml:"numberOfElements" json:"numberOfElements"`
@@ -0,0 +1,357 @@
+// Copyright © 2019 Developer developer@vamp.io
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/magneticio/vampkubistcli/client"
+	"github.com/magneticio/vampkubistcli/models"
+)
+
+/*
+Mapping maps series of a family to a vamp metric.
+Destination, subset and port are either fixed or taken from series labels,
+if ResolveSubset is set they are resolved from series labels with the subset map.
+*/
+type Mapping struct {
+	Series           string            `yaml:"series" json:"series"`
+	Metric           string            `yaml:"metric" json:"metric"`
+	MatchLabels      map[string]string `yaml:"matchLabels,omitempty" json:"matchLabels,omitempty"`
+	Destination      string            `yaml:"destination,omitempty" json:"destination,omitempty"`
+	DestinationLabel string            `yaml:"destinationLabel,omitempty" json:"destinationLabel,omitempty"`
+	Subset           string            `yaml:"subset,omitempty" json:"subset,omitempty"`
+	SubsetLabel      string            `yaml:"subsetLabel,omitempty" json:"subsetLabel,omitempty"`
+	Port             string            `yaml:"port,omitempty" json:"port,omitempty"`
+	PortLabel        string            `yaml:"portLabel,omitempty" json:"portLabel,omitempty"`
+	ResolveSubset    bool              `yaml:"resolveSubset,omitempty" json:"resolveSubset,omitempty"`
+	// Scale multiplies values, e.g. 1000 to convert seconds to milliseconds, 0 means no scaling
+	Scale float64 `yaml:"scale,omitempty" json:"scale,omitempty"`
+}
+
+// MappingConfig is the mapping file of the bridge
+type MappingConfig struct {
+	Mappings []Mapping `yaml:"mappings" json:"mappings"`
+}
+
+// Target is the vamp metric a series is pushed to
+type Target struct {
+	Metric      string
+	Destination string
+	Subset      string
+	Port        string
+}
+
+// MappedValue is a metric value with its target
+type MappedValue struct {
+	Target Target
+	Series string
+	Value  models.MetricValue
+}
+
+// ParseMappingConfig parses a mapping file in yaml or json
+func ParseMappingConfig(source []byte) (*MappingConfig, error) {
+	var config MappingConfig
+	if err := yaml.Unmarshal(source, &config); err != nil {
+		return nil, err
+	}
+	for i, mapping := range config.Mappings {
+		if mapping.Series == "" {
+			return nil, fmt.Errorf("mapping %v: series is required", i)
+		}
+		if mapping.Metric == "" {
+			config.Mappings[i].Metric = mapping.Series
+		}
+	}
+	return &config, nil
+}
+
+// Matches returns true if the series belongs to the mapped family and has all match labels
+func (m *Mapping) Matches(series *Series) bool {
+	if series.Family != m.Series {
+		return false
+	}
+	for name, value := range m.MatchLabels {
+		if series.Labels[name] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Resolve returns the target of the series
+func (m *Mapping) Resolve(labels map[string]string, subsetMap *models.DestinationsSubsetsMap) (*Target, error) {
+	target := &Target{
+		Metric:      m.Metric,
+		Destination: labelOrValue(labels, m.DestinationLabel, m.Destination),
+		Subset:      labelOrValue(labels, m.SubsetLabel, m.Subset),
+		Port:        labelOrValue(labels, m.PortLabel, m.Port),
+	}
+	if m.ResolveSubset {
+		candidates := []client.SubsetResolution{}
+		for _, resolution := range client.ResolveSubsets(subsetMap, labels) {
+			if target.Destination == "" || resolution.Destination == target.Destination {
+				candidates = append(candidates, resolution)
+			}
+		}
+		if len(candidates) == 0 {
+			return nil, fmt.Errorf("no subset matches labels %v", labels)
+		}
+		if len(candidates) > 1 {
+			return nil, fmt.Errorf("labels %v match %v subsets", labels, len(candidates))
+		}
+		target.Destination = candidates[0].Destination
+		target.Subset = candidates[0].Subset
+		if target.Port == "" && len(candidates[0].Ports) == 1 {
+			target.Port = strconv.Itoa(candidates[0].Ports[0].Port)
+		}
+	}
+	if target.Destination == "" {
+		return nil, errors.New("destination can not be resolved")
+	}
+	return target, nil
+}
+
+func labelOrValue(labels map[string]string, label string, value string) string {
+	if label != "" {
+		if labelValue, ok := labels[label]; ok {
+			return labelValue
+		}
+	}
+	return value
+}
+
+// InstanceLabel is the label that keeps series of different targets apart
+const InstanceLabel = "instance"
+
+/*
+WithInstance sets the instance label of every sample to the target it is scraped from like Prometheus does,
+an instance label that is exposed by the target is kept as exported_instance.
+*/
+func WithInstance(families []*MetricFamily, instance string) []*MetricFamily {
+	for _, family := range families {
+		for i := range family.Samples {
+			labels := make(map[string]string, len(family.Samples[i].Labels)+1)
+			for name, value := range family.Samples[i].Labels {
+				labels[name] = value
+			}
+			if exported, ok := labels[InstanceLabel]; ok {
+				labels["exported_"+InstanceLabel] = exported
+			}
+			labels[InstanceLabel] = instance
+			family.Samples[i].Labels = labels
+		}
+	}
+	return families
+}
+
+// scraped is the state of a series at the previous scrape
+type scraped struct {
+	total     float64
+	sum       float64
+	buckets   []bucket
+	timestamp int64
+}
+
+// Bridge maps scraped families to vamp metrics and derives rates and interval values between scrapes
+type Bridge struct {
+	Mappings []Mapping
+	previous map[string]scraped
+}
+
+// NewBridge creates a bridge with the given mappings
+func NewBridge(mappings []Mapping) *Bridge {
+	return &Bridge{
+		Mappings: mappings,
+		previous: make(map[string]scraped),
+	}
+}
+
+// contribution is a scaled series value that is mapped to a target
+type contribution struct {
+	value   models.MetricValue
+	buckets []bucket
+}
+
+/*
+Convert maps series of families scraped at the timestamp in milliseconds.
+Histograms and summaries describe the interval since the previous scrape, see interval.
+Series that map to the same target, e.g. the same series of several instances, are merged into one value.
+Series that can not be resolved are returned as errors and skipped.
+*/
+func (b *Bridge) Convert(families []*MetricFamily, subsetMap *models.DestinationsSubsetsMap, timestamp int64) ([]MappedValue, []error) {
+	errs := []error{}
+	targets := []Target{}
+	keys := make(map[Target][]string)
+	contributions := make(map[Target][]contribution)
+	for _, family := range families {
+		var mappings []Mapping
+		for _, mapping := range b.Mappings {
+			if mapping.Series == family.Name {
+				mappings = append(mappings, mapping)
+			}
+		}
+		if len(mappings) == 0 {
+			continue
+		}
+		for _, series := range Convert(family, timestamp) {
+			key := series.Key()
+			b.interval(key, &series, timestamp)
+			for _, mapping := range mappings {
+				if !mapping.Matches(&series) {
+					continue
+				}
+				target, err := mapping.Resolve(series.Labels, subsetMap)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("series %v: %v", key, err))
+					continue
+				}
+				if _, ok := keys[*target]; !ok {
+					targets = append(targets, *target)
+				}
+				keys[*target] = append(keys[*target], key)
+				contributions[*target] = append(contributions[*target], contribution{
+					value:   scale(series.Value, mapping.Scale),
+					buckets: scaleBuckets(series.buckets, mapping.Scale),
+				})
+			}
+		}
+	}
+	res := []MappedValue{}
+	for _, target := range targets {
+		res = append(res, MappedValue{
+			Target: target,
+			Series: strings.Join(keys[target], " "),
+			Value:  merge(contributions[target], timestamp),
+		})
+	}
+	return res, errs
+}
+
+/*
+merge combines values that are mapped to the same target.
+Counts, sums and rates are added, histograms are merged by bucket and
+other percentiles are averaged weighted by the number of elements.
+*/
+func merge(contributions []contribution, timestamp int64) models.MetricValue {
+	if len(contributions) == 1 {
+		return contributions[0].value
+	}
+	res := models.MetricValue{Timestamp: timestamp}
+	histograms := [][]bucket{}
+	weights := 0.0
+	for i, c := range contributions {
+		res.NumberOfElements += c.value.NumberOfElements
+		res.Sum += c.value.Sum
+		res.Rate += c.value.Rate
+		if len(c.buckets) > 0 {
+			histograms = append(histograms, c.buckets)
+		}
+		if i == 0 || c.value.Min < res.Min {
+			res.Min = c.value.Min
+		}
+		if i == 0 || c.value.Max > res.Max {
+			res.Max = c.value.Max
+		}
+		weight := float64(c.value.NumberOfElements)
+		weights += weight
+		res.Median += c.value.Median * weight
+		res.P75 += c.value.P75 * weight
+		res.P95 += c.value.P95 * weight
+		res.P99 += c.value.P99 * weight
+		res.P999 += c.value.P999 * weight
+	}
+	if weights > 0 {
+		res.Median /= weights
+		res.P75 /= weights
+		res.P95 /= weights
+		res.P99 /= weights
+		res.P999 /= weights
+	}
+	if res.NumberOfElements > 0 {
+		res.Average = res.Sum / float64(res.NumberOfElements)
+	}
+	if len(histograms) == len(contributions) {
+		setPercentiles(&res, mergeBuckets(histograms))
+	}
+	return res
+}
+
+/*
+interval sets the per second increase of the series total since the previous scrape as rate, 0 if it is not known.
+Count, sum and buckets of histograms are diffed with the previous scrape so percentiles describe the scrape interval
+instead of the lifetime of the process, the first scrape and a restart are counted from the start of the process.
+Summary quantiles are computed by the client and can't be diffed, they are pushed as they are exposed
+and only count, sum and average of summaries describe the interval.
+*/
+func (b *Bridge) interval(key string, series *Series, timestamp int64) {
+	if !series.HasTotal {
+		return
+	}
+	previous, ok := b.previous[key]
+	b.previous[key] = scraped{total: series.Total, sum: series.Value.Sum, buckets: series.buckets, timestamp: timestamp}
+	// a decreasing total means the process restarted
+	if !ok || timestamp <= previous.timestamp || series.Total < previous.total {
+		previous = scraped{}
+	} else {
+		series.Value.Rate = (series.Total - previous.total) * 1000 / float64(timestamp-previous.timestamp)
+	}
+	if !series.cumulative {
+		return
+	}
+	count := series.Total - previous.total
+	series.Value.NumberOfElements = int64(count)
+	series.Value.Sum -= previous.sum
+	series.Value.Average = 0
+	if count > 0 {
+		series.Value.Average = series.Value.Sum / count
+	}
+	if len(series.buckets) > 0 {
+		series.buckets = diffBuckets(series.buckets, previous.buckets)
+		setPercentiles(&series.Value, series.buckets)
+	}
+}
+
+func scaleBuckets(buckets []bucket, factor float64) []bucket {
+	if factor == 0 || factor == 1 || len(buckets) == 0 {
+		return buckets
+	}
+	res := make([]bucket, len(buckets))
+	for i, b := range buckets {
+		res[i] = bucket{upperBound: b.upperBound * factor, count: b.count}
+	}
+	return res
+}
+
+func scale(value models.MetricValue, factor float64) models.MetricValue {
+	if factor == 0 || factor == 1 {
+		return value
+	}
+	value.StandardDeviation *= factor
+	value.Average *= factor
+	value.Sum *= factor
+	value.Median *= factor
+	value.Min *= factor
+	value.Max *= factor
+	value.P999 *= factor
+	value.P99 *= factor
+	value.P95 *= factor
+	value.P75 *= factor
+	return value
+}
@@ -0,0 +1,311 @@
+// Copyright © 2019 Developer developer@vamp.io
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/magneticio/vampkubistcli/models"
+)
+
+// Series is a time series of a metric family converted to a metric value
+type Series struct {
+	Family string
+	Labels map[string]string
+	Value  models.MetricValue
+	// Total is the cumulative number of observations or the counter value that rate is derived from
+	Total    float64
+	HasTotal bool
+	// buckets of a histogram, cumulative and sorted by upper bound
+	buckets []bucket
+	// cumulative is true if sum and total are counted since the process started like in histograms and summaries
+	cumulative bool
+}
+
+// Key identifies the series by family name and sorted labels
+func (s *Series) Key() string {
+	names := make([]string, 0, len(s.Labels))
+	for name := range s.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var key strings.Builder
+	key.WriteString(s.Family)
+	for _, name := range names {
+		key.WriteString("," + name + "=" + s.Labels[name])
+	}
+	return key.String()
+}
+
+type bucket struct {
+	upperBound float64
+	count      float64
+}
+
+/*
+Convert converts samples of a family to a metric value per series.
+Histogram percentiles are interpolated linearly inside buckets and min and max are bucket bounds,
+summary percentiles are taken from quantiles, counters and gauges are single values.
+*/
+func Convert(family *MetricFamily, timestamp int64) []Series {
+	switch family.Type {
+	case Histogram:
+		return convertHistogram(family, timestamp)
+	case Summary:
+		return convertSummary(family, timestamp)
+	}
+	res := []Series{}
+	for _, sample := range family.Samples {
+		v := sample.Value
+		res = append(res, Series{
+			Family: family.Name,
+			Labels: sample.Labels,
+			Value: models.MetricValue{
+				Timestamp:        timestamp,
+				NumberOfElements: 1,
+				Average:          v,
+				Sum:              v,
+				Median:           v,
+				Min:              v,
+				Max:              v,
+				P999:             v,
+				P99:              v,
+				P95:              v,
+				P75:              v,
+			},
+			Total:    v,
+			HasTotal: family.Type == Counter,
+		})
+	}
+	return res
+}
+
+// group collects samples of a family by labels without the given label
+func group(family *MetricFamily, without string, add func(series *Series, sample Sample, labelValue string)) []Series {
+	res := []Series{}
+	index := make(map[string]int)
+	for _, sample := range family.Samples {
+		labels := make(map[string]string)
+		for name, value := range sample.Labels {
+			if name != without {
+				labels[name] = value
+			}
+		}
+		series := Series{Family: family.Name, Labels: labels}
+		key := series.Key()
+		i, ok := index[key]
+		if !ok {
+			i = len(res)
+			index[key] = i
+			res = append(res, series)
+		}
+		add(&res[i], sample, sample.Labels[without])
+	}
+	return res
+}
+
+func convertHistogram(family *MetricFamily, timestamp int64) []Series {
+	buckets := make(map[string][]bucket)
+	res := group(family, "le", func(series *Series, sample Sample, le string) {
+		switch sample.Name {
+		case family.Name + "_bucket":
+			upperBound, err := parseValue(le)
+			if err != nil {
+				return
+			}
+			key := series.Key()
+			buckets[key] = append(buckets[key], bucket{upperBound: upperBound, count: sample.Value})
+		case family.Name + "_sum":
+			series.Value.Sum = sample.Value
+		case family.Name + "_count":
+			series.Total = sample.Value
+			series.HasTotal = true
+		}
+	})
+	for i := range res {
+		series := &res[i]
+		b := buckets[series.Key()]
+		sort.Slice(b, func(i, j int) bool {
+			return b[i].upperBound < b[j].upperBound
+		})
+		if !series.HasTotal && len(b) > 0 {
+			series.Total = b[len(b)-1].count
+			series.HasTotal = true
+		}
+		series.buckets = b
+		series.cumulative = true
+		series.Value.Timestamp = timestamp
+		series.Value.NumberOfElements = int64(series.Total)
+		if series.Total > 0 {
+			series.Value.Average = series.Value.Sum / series.Total
+		}
+		setPercentiles(&series.Value, b)
+	}
+	return res
+}
+
+// setPercentiles sets percentiles, min and max of the value from cumulative sorted buckets
+func setPercentiles(value *models.MetricValue, buckets []bucket) {
+	value.Median = histogramQuantile(0.5, buckets)
+	value.P75 = histogramQuantile(0.75, buckets)
+	value.P95 = histogramQuantile(0.95, buckets)
+	value.P99 = histogramQuantile(0.99, buckets)
+	value.P999 = histogramQuantile(0.999, buckets)
+	value.Min, value.Max = histogramBounds(buckets)
+}
+
+// mergeBuckets adds cumulative buckets, a bound that is missing in one of them counts its observations up to the bound below
+func mergeBuckets(lists [][]bucket) []bucket {
+	bounds := []float64{}
+	seen := make(map[float64]bool)
+	for _, list := range lists {
+		for _, b := range list {
+			if !seen[b.upperBound] {
+				seen[b.upperBound] = true
+				bounds = append(bounds, b.upperBound)
+			}
+		}
+	}
+	sort.Float64s(bounds)
+	res := make([]bucket, len(bounds))
+	for i, upperBound := range bounds {
+		res[i].upperBound = upperBound
+		for _, list := range lists {
+			res[i].count += countAt(list, upperBound)
+		}
+	}
+	return res
+}
+
+// diffBuckets subtracts the counts of previous cumulative buckets so the buckets count observations since then
+func diffBuckets(current []bucket, previous []bucket) []bucket {
+	res := make([]bucket, len(current))
+	for i, b := range current {
+		res[i] = bucket{upperBound: b.upperBound, count: math.Max(b.count-countAt(previous, b.upperBound), 0)}
+	}
+	return res
+}
+
+// countAt returns the cumulative count of observations up to the bound, which is the count of the last bucket up to it
+func countAt(buckets []bucket, upperBound float64) float64 {
+	count := 0.0
+	for _, b := range buckets {
+		if b.upperBound > upperBound {
+			break
+		}
+		count = b.count
+	}
+	return count
+}
+
+// histogramQuantile interpolates the quantile inside the bucket that contains it, buckets are cumulative and sorted
+func histogramQuantile(q float64, buckets []bucket) float64 {
+	if len(buckets) == 0 {
+		return 0
+	}
+	total := buckets[len(buckets)-1].count
+	if total == 0 {
+		return 0
+	}
+	rank := q * total
+	lowerBound := 0.0
+	lowerCount := 0.0
+	for i, b := range buckets {
+		if b.count >= rank {
+			if math.IsInf(b.upperBound, 1) {
+				return lowerBound
+			}
+			if i == 0 && b.upperBound <= 0 {
+				return b.upperBound
+			}
+			if b.count == lowerCount {
+				return b.upperBound
+			}
+			return lowerBound + (b.upperBound-lowerBound)*(rank-lowerCount)/(b.count-lowerCount)
+		}
+		lowerBound = b.upperBound
+		lowerCount = b.count
+	}
+	return lowerBound
+}
+
+// histogramBounds returns the lower bound of the first and the upper bound of the last bucket that has observations
+func histogramBounds(buckets []bucket) (float64, float64) {
+	min, max := 0.0, 0.0
+	lowerBound := 0.0
+	previous := 0.0
+	found := false
+	for _, b := range buckets {
+		if b.count > previous {
+			if !found {
+				min = lowerBound
+				found = true
+			}
+			max = b.upperBound
+			if math.IsInf(max, 1) {
+				max = lowerBound
+			}
+		}
+		lowerBound = b.upperBound
+		previous = b.count
+	}
+	return min, max
+}
+
+func convertSummary(family *MetricFamily, timestamp int64) []Series {
+	res := group(family, "quantile", func(series *Series, sample Sample, quantile string) {
+		switch sample.Name {
+		case family.Name:
+			q, err := strconv.ParseFloat(quantile, 64)
+			if err != nil || math.IsNaN(sample.Value) {
+				return
+			}
+			switch q {
+			case 0:
+				series.Value.Min = sample.Value
+			case 0.5:
+				series.Value.Median = sample.Value
+			case 0.75:
+				series.Value.P75 = sample.Value
+			case 0.95:
+				series.Value.P95 = sample.Value
+			case 0.99:
+				series.Value.P99 = sample.Value
+			case 0.999:
+				series.Value.P999 = sample.Value
+			case 1:
+				series.Value.Max = sample.Value
+			}
+		case family.Name + "_sum":
+			series.Value.Sum = sample.Value
+		case family.Name + "_count":
+			series.Total = sample.Value
+			series.HasTotal = true
+		}
+	})
+	for i := range res {
+		series := &res[i]
+		series.cumulative = true
+		series.Value.Timestamp = timestamp
+		series.Value.NumberOfElements = int64(series.Total)
+		if series.Total > 0 {
+			series.Value.Average = series.Value.Sum / series.Total
+		}
+	}
+	return res
+}
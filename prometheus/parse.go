@@ -0,0 +1,204 @@
+// Copyright © 2019 Developer developer@vamp.io
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Metric types of the Prometheus text format
+const (
+	Counter   = "counter"
+	Gauge     = "gauge"
+	Histogram = "histogram"
+	Summary   = "summary"
+	Untyped   = "untyped"
+)
+
+// Sample is a single line of the Prometheus text format
+type Sample struct {
+	Name   string
+	Labels map[string]string
+	Value  float64
+}
+
+// MetricFamily is a metric with its type and samples, histogram and summary samples include _bucket, _sum and _count series
+type MetricFamily struct {
+	Name    string
+	Type    string
+	Samples []Sample
+}
+
+/*
+Parse reads metric families in the Prometheus text exposition format.
+Samples without a TYPE line are grouped into untyped families by name.
+*/
+func Parse(reader io.Reader) ([]*MetricFamily, error) {
+	families := []*MetricFamily{}
+	byName := make(map[string]*MetricFamily)
+	family := func(name string, metricType string) *MetricFamily {
+		if f, ok := byName[name]; ok {
+			if metricType != "" {
+				f.Type = metricType
+			}
+			return f
+		}
+		if metricType == "" {
+			metricType = Untyped
+		}
+		f := &MetricFamily{Name: name, Type: metricType}
+		byName[name] = f
+		families = append(families, f)
+		return f
+	}
+	scanner := bufio.NewScanner(reader)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			fields := strings.Fields(line)
+			if len(fields) >= 4 && fields[1] == "TYPE" {
+				family(fields[2], fields[3])
+			}
+			continue
+		}
+		sample, err := parseSample(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %v: %v", lineNumber, err)
+		}
+		f, ok := byName[familyName(sample.Name, byName)]
+		if !ok {
+			f = family(sample.Name, "")
+		}
+		f.Samples = append(f.Samples, *sample)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return families, nil
+}
+
+// familyName returns the name of a known histogram or summary family the series belongs to
+func familyName(name string, families map[string]*MetricFamily) string {
+	for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+		if !strings.HasSuffix(name, suffix) {
+			continue
+		}
+		base := strings.TrimSuffix(name, suffix)
+		if f, ok := families[base]; ok && (f.Type == Histogram || f.Type == Summary) {
+			return base
+		}
+	}
+	return name
+}
+
+func parseSample(line string) (*Sample, error) {
+	sample := &Sample{Labels: make(map[string]string)}
+	nameEnd := strings.IndexAny(line, "{ \t")
+	if nameEnd < 0 {
+		return nil, fmt.Errorf("value is missing in %q", line)
+	}
+	sample.Name = line[:nameEnd]
+	rest := line[nameEnd:]
+	if strings.HasPrefix(rest, "{") {
+		consumed, err := parseLabels(rest[1:], sample.Labels)
+		if err != nil {
+			return nil, err
+		}
+		rest = rest[1+consumed:]
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("value is missing in %q", line)
+	}
+	value, err := parseValue(fields[0])
+	if err != nil {
+		return nil, err
+	}
+	sample.Value = value
+	return sample, nil
+}
+
+// parseLabels parses labels after the opening brace and returns the number of characters including the closing brace
+func parseLabels(text string, labels map[string]string) (int, error) {
+	i := 0
+	for {
+		for i < len(text) && (text[i] == ' ' || text[i] == ',') {
+			i++
+		}
+		if i >= len(text) {
+			return 0, fmt.Errorf("labels are not closed in %q", text)
+		}
+		if text[i] == '}' {
+			return i + 1, nil
+		}
+		equals := strings.IndexByte(text[i:], '=')
+		if equals < 0 {
+			return 0, fmt.Errorf("label value is missing in %q", text)
+		}
+		name := strings.TrimSpace(text[i : i+equals])
+		i += equals + 1
+		if i >= len(text) || text[i] != '"' {
+			return 0, fmt.Errorf("label value of %v should be quoted", name)
+		}
+		i++
+		var value strings.Builder
+		closed := false
+		for i < len(text) {
+			c := text[i]
+			i++
+			if c == '"' {
+				closed = true
+				break
+			}
+			if c == '\\' && i < len(text) {
+				switch text[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(text[i])
+				}
+				i++
+				continue
+			}
+			value.WriteByte(c)
+		}
+		if !closed {
+			return 0, fmt.Errorf("label value of %v is not closed", name)
+		}
+		labels[name] = value.String()
+	}
+}
+
+func parseValue(text string) (float64, error) {
+	switch text {
+	case "+Inf", "Inf":
+		return math.Inf(1), nil
+	case "-Inf":
+		return math.Inf(-1), nil
+	case "NaN":
+		return math.NaN(), nil
+	}
+	return strconv.ParseFloat(text, 64)
+}
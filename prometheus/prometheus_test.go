@@ -0,0 +1,165 @@
+package prometheus_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/magneticio/vampkubistcli/models"
+	"github.com/magneticio/vampkubistcli/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+const exposition = `
+# HELP http_request_duration_seconds Request latency
+# TYPE http_request_duration_seconds histogram
+http_request_duration_seconds_bucket{app="shop",version="v1",le="0.1"} 50
+http_request_duration_seconds_bucket{app="shop",version="v1",le="0.2"} 90
+http_request_duration_seconds_bucket{app="shop",version="v1",le="0.5"} 100
+http_request_duration_seconds_bucket{app="shop",version="v1",le="+Inf"} 100
+http_request_duration_seconds_sum{app="shop",version="v1"} 11
+http_request_duration_seconds_count{app="shop",version="v1"} 100
+# TYPE rpc_duration_seconds summary
+rpc_duration_seconds{service="a",quantile="0.5"} 0.05
+rpc_duration_seconds{service="a",quantile="0.95"} 0.2
+rpc_duration_seconds{service="a",quantile="0.99"} NaN
+rpc_duration_seconds_sum{service="a"} 8
+rpc_duration_seconds_count{service="a"} 100
+# TYPE requests_total counter
+requests_total{path="/a \"quoted\""} 1027 1395066363000
+up 1
+`
+
+func TestParse(t *testing.T) {
+	families, err := prometheus.Parse(strings.NewReader(exposition))
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(families))
+	assert.Equal(t, prometheus.Histogram, families[0].Type)
+	assert.Equal(t, 6, len(families[0].Samples))
+	assert.Equal(t, prometheus.Summary, families[1].Type)
+	assert.Equal(t, 5, len(families[1].Samples))
+	assert.True(t, math.IsNaN(families[1].Samples[2].Value))
+	assert.Equal(t, `/a "quoted"`, families[2].Samples[0].Labels["path"])
+	assert.Equal(t, 1027.0, families[2].Samples[0].Value)
+	assert.Equal(t, prometheus.Untyped, families[3].Type)
+
+	_, err = prometheus.Parse(strings.NewReader(`broken{a="b" 1`))
+	assert.Error(t, err)
+}
+
+func TestConvertHistogram(t *testing.T) {
+	families, _ := prometheus.Parse(strings.NewReader(exposition))
+	series := prometheus.Convert(families[0], 1000)
+	assert.Equal(t, 1, len(series))
+	value := series[0].Value
+	assert.Equal(t, map[string]string{"app": "shop", "version": "v1"}, series[0].Labels)
+	assert.Equal(t, int64(100), value.NumberOfElements)
+	assert.InDelta(t, 0.11, value.Average, 1e-9)
+	assert.InDelta(t, 0.1, value.Median, 1e-9)
+	assert.InDelta(t, 0.1+0.1*25/40, value.P75, 1e-9)
+	assert.InDelta(t, 0.2+0.3*5/10, value.P95, 1e-9)
+	assert.Equal(t, 0.0, value.Min)
+	assert.Equal(t, 0.5, value.Max)
+}
+
+func TestConvertSummary(t *testing.T) {
+	families, _ := prometheus.Parse(strings.NewReader(exposition))
+	series := prometheus.Convert(families[1], 1000)
+	assert.Equal(t, 1, len(series))
+	assert.Equal(t, 0.05, series[0].Value.Median)
+	assert.Equal(t, 0.2, series[0].Value.P95)
+	assert.Equal(t, 0.0, series[0].Value.P99)
+	assert.InDelta(t, 0.08, series[0].Value.Average, 1e-9)
+}
+
+func TestBridge(t *testing.T) {
+	config, err := prometheus.ParseMappingConfig([]byte(`
+mappings:
+- series: http_request_duration_seconds
+  metric: latency
+  resolveSubset: true
+  scale: 1000
+- series: requests_total
+  destination: dest-1
+  subset: subset1
+`))
+	assert.NoError(t, err)
+	subsetMap := &models.DestinationsSubsetsMap{
+		DestinationsMap: map[string]models.LabelsToPortMap{
+			"dest-1": {
+				DestinationName: "dest-1",
+				Map: map[string]models.SubsetToPorts{
+					"version=v1": {Subset: "subset1", Ports: []models.DestinationPortSpecification{{Port: 9191}}},
+					"version=v2": {Subset: "subset2", Ports: []models.DestinationPortSpecification{{Port: 9191}}},
+				},
+			},
+		},
+	}
+	bridge := prometheus.NewBridge(config.Mappings)
+	families, _ := prometheus.Parse(strings.NewReader(exposition))
+	values, errs := bridge.Convert(families, subsetMap, 1000)
+	assert.Equal(t, 0, len(errs))
+	assert.Equal(t, 2, len(values))
+	assert.Equal(t, prometheus.Target{Metric: "latency", Destination: "dest-1", Subset: "subset1", Port: "9191"}, values[0].Target)
+	assert.InDelta(t, 110, values[0].Value.Average, 1e-9)
+	assert.Equal(t, 0.0, values[0].Value.Rate)
+	assert.Equal(t, "requests_total", values[1].Target.Metric)
+
+	// 30 requests between 0.2 and 0.5 seconds in the next interval
+	next := strings.NewReplacer(
+		`le="0.5"} 100`, `le="0.5"} 130`,
+		`le="+Inf"} 100`, `le="+Inf"} 130`,
+		`_sum{app="shop",version="v1"} 11`, `_sum{app="shop",version="v1"} 20`,
+		`_count{app="shop",version="v1"} 100`, `_count{app="shop",version="v1"} 130`,
+	).Replace(exposition)
+	families, _ = prometheus.Parse(strings.NewReader(next))
+	values, _ = bridge.Convert(families, subsetMap, 16000)
+	assert.InDelta(t, 2, values[0].Value.Rate, 1e-9)
+	assert.Equal(t, int64(30), values[0].Value.NumberOfElements)
+	assert.InDelta(t, 300, values[0].Value.Average, 1e-9)
+	assert.InDelta(t, 350, values[0].Value.Median, 1e-9)
+	assert.InDelta(t, 200, values[0].Value.Min, 1e-9)
+	assert.InDelta(t, 500, values[0].Value.Max, 1e-9)
+}
+
+func TestBridgeMergesTargets(t *testing.T) {
+	config, err := prometheus.ParseMappingConfig([]byte(`
+mappings:
+- series: requests_total
+  destination: dest-1
+  subset: subset1
+- series: http_request_duration_seconds
+  metric: latency
+  destination: dest-1
+  subset: subset1
+`))
+	assert.NoError(t, err)
+	scrape := func(requests string, count string) []*prometheus.MetricFamily {
+		families, err := prometheus.Parse(strings.NewReader(`
+# TYPE requests_total counter
+requests_total ` + requests + `
+# TYPE http_request_duration_seconds histogram
+http_request_duration_seconds_bucket{le="0.1"} ` + count + `
+http_request_duration_seconds_bucket{le="+Inf"} ` + count + `
+http_request_duration_seconds_sum ` + count + `
+http_request_duration_seconds_count ` + count + `
+`))
+		assert.NoError(t, err)
+		return families
+	}
+	bridge := prometheus.NewBridge(config.Mappings)
+	families := append(prometheus.WithInstance(scrape("100", "10"), "a"), prometheus.WithInstance(scrape("5000", "30"), "b")...)
+	values, errs := bridge.Convert(families, nil, 1000)
+	assert.Equal(t, 0, len(errs))
+	assert.Equal(t, 2, len(values))
+	assert.Equal(t, 5100.0, values[0].Value.Sum)
+	assert.Equal(t, int64(2), values[0].Value.NumberOfElements)
+	assert.Equal(t, 100.0, values[0].Value.Min)
+	assert.Equal(t, 5000.0, values[0].Value.Max)
+	assert.Equal(t, int64(40), values[1].Value.NumberOfElements)
+	assert.InDelta(t, 0.05, values[1].Value.Median, 1e-9)
+
+	families = append(prometheus.WithInstance(scrape("110", "10"), "a"), prometheus.WithInstance(scrape("5090", "30"), "b")...)
+	values, _ = bridge.Convert(families, nil, 11000)
+	assert.InDelta(t, 10, values[0].Value.Rate, 1e-9)
+}
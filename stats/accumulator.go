@@ -0,0 +1,132 @@
+// Copyright © 2019 Developer developer@vamp.io
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stats
+
+import (
+	"math"
+	"sort"
+
+	"github.com/magneticio/vampkubistcli/models"
+)
+
+// Accumulator computes count, mean and standard deviation in a single pass with Welford's algorithm
+type Accumulator struct {
+	count int64
+	mean  float64
+	m2    float64
+}
+
+// Add adds an observation
+func (a *Accumulator) Add(value float64) {
+	a.count++
+	delta := value - a.mean
+	a.mean += delta / float64(a.count)
+	a.m2 += delta * (value - a.mean)
+}
+
+// Merge adds observations of another accumulator
+func (a *Accumulator) Merge(other *Accumulator) {
+	if other.count == 0 {
+		return
+	}
+	count := a.count + other.count
+	delta := other.mean - a.mean
+	a.mean += delta * float64(other.count) / float64(count)
+	a.m2 += other.m2 + delta*delta*float64(a.count)*float64(other.count)/float64(count)
+	a.count = count
+}
+
+// Count returns the number of observations
+func (a *Accumulator) Count() int64 {
+	return a.count
+}
+
+// Mean returns the mean of observations
+func (a *Accumulator) Mean() float64 {
+	return a.mean
+}
+
+// StandardDeviation returns the sample standard deviation of observations
+func (a *Accumulator) StandardDeviation() float64 {
+	if a.count < 2 {
+		return 0
+	}
+	return math.Sqrt(a.m2 / float64(a.count-1))
+}
+
+// ExperimentMetric converts accumulated observations to an experiment metric
+func (a *Accumulator) ExperimentMetric(timestamp int64) models.ExperimentMetric {
+	return models.ExperimentMetric{
+		Timestamp:         timestamp,
+		NumberOfElements:  a.Count(),
+		StandardDeviation: a.StandardDeviation(),
+		Average:           a.Mean(),
+	}
+}
+
+// Window is a completed time window with its accumulated observations
+type Window struct {
+	Start       int64
+	End         int64
+	Accumulator *Accumulator
+}
+
+// Windows accumulates timestamped observations into fixed size time windows
+type Windows struct {
+	Size    int64
+	windows map[int64]*Accumulator
+}
+
+// NewWindows creates windows of the given size, size is in the same unit as timestamps
+func NewWindows(size int64) *Windows {
+	return &Windows{
+		Size:    size,
+		windows: make(map[int64]*Accumulator),
+	}
+}
+
+// Add adds an observation to the window that contains the timestamp
+func (w *Windows) Add(timestamp int64, value float64) {
+	start := timestamp - timestamp%w.Size
+	if timestamp < 0 && timestamp%w.Size != 0 {
+		start -= w.Size
+	}
+	accumulator, ok := w.windows[start]
+	if !ok {
+		accumulator = &Accumulator{}
+		w.windows[start] = accumulator
+	}
+	accumulator.Add(value)
+}
+
+// Flush removes and returns windows that end before or at the given timestamp sorted by start
+func (w *Windows) Flush(until int64) []Window {
+	res := []Window{}
+	for start, accumulator := range w.windows {
+		if start+w.Size <= until {
+			res = append(res, Window{Start: start, End: start + w.Size, Accumulator: accumulator})
+			delete(w.windows, start)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Start < res[j].Start
+	})
+	return res
+}
+
+// FlushAll removes and returns all windows sorted by start
+func (w *Windows) FlushAll() []Window {
+	return w.Flush(math.MaxInt64 - w.Size)
+}
@@ -0,0 +1,56 @@
+package stats_test
+
+import (
+	"testing"
+
+	"github.com/magneticio/vampkubistcli/models"
+	"github.com/magneticio/vampkubistcli/stats"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccumulator(t *testing.T) {
+	var accumulator stats.Accumulator
+	for _, v := range []float64{2, 4, 4, 4, 5, 5, 7, 9} {
+		accumulator.Add(v)
+	}
+	assert.Equal(t, int64(8), accumulator.Count())
+	assert.InDelta(t, 5, accumulator.Mean(), 1e-12)
+	// sample standard deviation, sqrt(32 / 7)
+	assert.InDelta(t, 2.138090, accumulator.StandardDeviation(), 1e-6)
+	metric := accumulator.ExperimentMetric(1000)
+	assert.Equal(t, int64(1000), metric.Timestamp)
+	assert.Equal(t, int64(8), metric.NumberOfElements)
+}
+
+func TestWindows(t *testing.T) {
+	windows := stats.NewWindows(1000)
+	windows.Add(100, 1)
+	windows.Add(900, 3)
+	windows.Add(1500, 10)
+	windows.Add(2999, 20)
+
+	flushed := windows.Flush(2000)
+	assert.Equal(t, 2, len(flushed))
+	assert.Equal(t, int64(0), flushed[0].Start)
+	assert.Equal(t, int64(1000), flushed[0].End)
+	assert.Equal(t, models.ExperimentMetric{Timestamp: 0, NumberOfElements: 2, StandardDeviation: 1.4142135623730951, Average: 2}, flushed[0].Accumulator.ExperimentMetric(0))
+	assert.Equal(t, int64(1000), flushed[1].Start)
+
+	assert.Equal(t, 0, len(windows.Flush(2000)))
+	rest := windows.FlushAll()
+	assert.Equal(t, 1, len(rest))
+	assert.Equal(t, int64(2000), rest[0].Start)
+}
+
+func TestDescribe(t *testing.T) {
+	value := stats.Describe([]float64{4, 1, 3, 2}, 1000)
+	assert.Equal(t, int64(1000), value.Timestamp)
+	assert.Equal(t, int64(4), value.NumberOfElements)
+	assert.Equal(t, 10.0, value.Sum)
+	assert.Equal(t, 2.5, value.Average)
+	assert.Equal(t, 2.5, value.Median)
+	assert.Equal(t, 1.0, value.Min)
+	assert.Equal(t, 4.0, value.Max)
+	assert.Equal(t, 3.25, value.P75)
+	assert.Equal(t, models.MetricValue{Timestamp: 5}, stats.Describe(nil, 5))
+}
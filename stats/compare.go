@@ -0,0 +1,172 @@
+// Copyright © 2019 Developer developer@vamp.io
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stats
+
+import (
+	"errors"
+	"math"
+)
+
+// Decisions that can be recommended after comparing variants
+const (
+	KeepRunning  = "keep running"
+	Winner       = "winner"
+	NoDifference = "no difference"
+)
+
+// Variant is a named sample that takes part in a comparison
+type Variant struct {
+	Name   string
+	Sample Sample
+}
+
+// VariantResult contains statistics of a variant compared to the control
+type VariantResult struct {
+	Name               string      `json:"name"`
+	Mean               float64     `json:"mean"`
+	StandardDeviation  float64     `json:"standardDeviation"`
+	Count              int64       `json:"count"`
+	ConfidenceInterval [2]float64  `json:"confidenceInterval"`
+	Comparison         *Comparison `json:"comparison,omitempty"`
+	RequiredSampleSize int64       `json:"requiredSampleSize,omitempty"`
+	Significant        bool        `json:"significant"`
+}
+
+// Recommendation is the outcome of comparing variants with a control
+type Recommendation struct {
+	Decision string          `json:"decision"`
+	Winner   string          `json:"winner,omitempty"`
+	Control  VariantResult   `json:"control"`
+	Variants []VariantResult `json:"variants"`
+}
+
+// CompareOptions configures how variants are compared
+type CompareOptions struct {
+	Confidence float64
+	Power      float64
+	// MinimumDetectableEffect is relative to the control mean, if it or the control mean is 0 observed difference is used
+	MinimumDetectableEffect float64
+	LowerIsBetter           bool
+}
+
+// DefaultCompareOptions are commonly used values for A/B tests
+var DefaultCompareOptions = CompareOptions{
+	Confidence: 0.95,
+	Power:      0.8,
+}
+
+/*
+Compare runs Welch's t-test for every variant against the control.
+Significance level is Bonferroni corrected for the number of variants.
+Winner is the significantly better variant with the largest improvement,
+or the control if every variant is significantly worse.
+No difference is recommended when every comparison without a significant result
+has reached the required sample size, otherwise experiment should keep running.
+*/
+func Compare(control Variant, variants []Variant, options CompareOptions) (*Recommendation, error) {
+	if len(variants) == 0 {
+		return nil, errors.New("At least one variant is required to compare with the control")
+	}
+	if err := validateProbability("confidence", options.Confidence); err != nil {
+		return nil, err
+	}
+	if err := validateProbability("power", options.Power); err != nil {
+		return nil, err
+	}
+	alpha := (1 - options.Confidence) / float64(len(variants))
+	controlResult, err := describe(control, options.Confidence)
+	if err != nil {
+		return nil, err
+	}
+	res := &Recommendation{
+		Decision: KeepRunning,
+		Control:  *controlResult,
+	}
+	better := func(difference float64) bool {
+		if options.LowerIsBetter {
+			return difference < 0
+		}
+		return difference > 0
+	}
+	bestImprovement := 0.0
+	allWorse := true
+	allConclusive := true
+	for _, variant := range variants {
+		variantResult, err := describe(variant, options.Confidence)
+		if err != nil {
+			return nil, err
+		}
+		comparison, err := WelchTTest(control.Sample, variant.Sample, 1-alpha)
+		if err != nil {
+			return nil, err
+		}
+		variantResult.Comparison = comparison
+		variantResult.Significant = comparison.PValue < alpha
+		difference := comparison.Difference
+		if options.MinimumDetectableEffect > 0 && control.Sample.Mean != 0 {
+			difference = options.MinimumDetectableEffect * math.Abs(control.Sample.Mean)
+		}
+		// without a difference to detect the required sample size is unknown and the comparison is not conclusive
+		if difference != 0 {
+			required, err := RequiredSampleSize(control.Sample, variant.Sample, difference, alpha, options.Power)
+			if err != nil {
+				return nil, err
+			}
+			variantResult.RequiredSampleSize = required
+		}
+		switch {
+		case variantResult.Significant && better(comparison.Difference):
+			allWorse = false
+			if improvement := math.Abs(comparison.Difference); improvement > bestImprovement {
+				bestImprovement = improvement
+				res.Decision = Winner
+				res.Winner = variant.Name
+			}
+		case variantResult.Significant:
+		default:
+			allWorse = false
+			if variantResult.RequiredSampleSize == 0 ||
+				control.Sample.Count < variantResult.RequiredSampleSize ||
+				variant.Sample.Count < variantResult.RequiredSampleSize {
+				allConclusive = false
+			}
+		}
+		res.Variants = append(res.Variants, *variantResult)
+	}
+	if res.Decision == Winner {
+		return res, nil
+	}
+	if allWorse {
+		res.Decision = Winner
+		res.Winner = control.Name
+	} else if allConclusive {
+		res.Decision = NoDifference
+	}
+	return res, nil
+}
+
+func describe(variant Variant, confidence float64) (*VariantResult, error) {
+	low, high, err := ConfidenceInterval(variant.Sample, confidence)
+	if err != nil {
+		return nil, err
+	}
+	return &VariantResult{
+		Name:               variant.Name,
+		Mean:               variant.Sample.Mean,
+		StandardDeviation:  variant.Sample.StandardDeviation,
+		Count:              variant.Sample.Count,
+		ConfidenceInterval: [2]float64{low, high},
+	}, nil
+}
@@ -0,0 +1,65 @@
+// Copyright © 2019 Developer developer@vamp.io
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stats
+
+import (
+	"math"
+	"sort"
+
+	"github.com/magneticio/vampkubistcli/models"
+)
+
+// Percentile returns the exact p-percentile of sorted values with linear interpolation between ranks
+func Percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := p * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	if lower < 0 {
+		return sorted[0]
+	}
+	if upper >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+// Describe computes a metric value with exact percentiles from a small set of values
+func Describe(values []float64, timestamp int64) models.MetricValue {
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	var accumulator Accumulator
+	sum := 0.0
+	for _, v := range sorted {
+		accumulator.Add(v)
+		sum += v
+	}
+	return models.MetricValue{
+		Timestamp:         timestamp,
+		NumberOfElements:  accumulator.Count(),
+		StandardDeviation: accumulator.StandardDeviation(),
+		Average:           accumulator.Mean(),
+		Sum:               sum,
+		Median:            Percentile(sorted, 0.5),
+		Min:               Percentile(sorted, 0),
+		Max:               Percentile(sorted, 1),
+		P999:              Percentile(sorted, 0.999),
+		P99:               Percentile(sorted, 0.99),
+		P95:               Percentile(sorted, 0.95),
+		P75:               Percentile(sorted, 0.75),
+	}
+}
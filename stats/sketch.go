@@ -0,0 +1,163 @@
+// Copyright © 2019 Developer developer@vamp.io
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stats
+
+import (
+	"math"
+	"sort"
+)
+
+// DefaultRelativeAccuracy is the relative error of quantiles estimated by a sketch
+const DefaultRelativeAccuracy = 0.01
+
+/*
+Sketch is a streaming quantile estimator with logarithmic buckets,
+every estimated quantile is within the relative accuracy of the exact value.
+Sketches can be merged, so windows can be combined without keeping observations.
+*/
+type Sketch struct {
+	gamma    float64
+	logGamma float64
+	positive map[int]int64
+	negative map[int]int64
+	zero     int64
+	count    int64
+	min      float64
+	max      float64
+}
+
+// NewSketch creates a sketch with the given relative accuracy
+func NewSketch(relativeAccuracy float64) *Sketch {
+	if relativeAccuracy <= 0 || relativeAccuracy >= 1 {
+		relativeAccuracy = DefaultRelativeAccuracy
+	}
+	gamma := (1 + relativeAccuracy) / (1 - relativeAccuracy)
+	return &Sketch{
+		gamma:    gamma,
+		logGamma: math.Log(gamma),
+		positive: make(map[int]int64),
+		negative: make(map[int]int64),
+		min:      math.Inf(1),
+		max:      math.Inf(-1),
+	}
+}
+
+// Add adds an observation
+func (s *Sketch) Add(value float64) {
+	switch {
+	case value > 0:
+		s.positive[s.index(value)]++
+	case value < 0:
+		s.negative[s.index(-value)]++
+	default:
+		s.zero++
+	}
+	s.count++
+	s.min = math.Min(s.min, value)
+	s.max = math.Max(s.max, value)
+}
+
+// Merge adds observations of another sketch with the same accuracy
+func (s *Sketch) Merge(other *Sketch) {
+	for i, c := range other.positive {
+		s.positive[i] += c
+	}
+	for i, c := range other.negative {
+		s.negative[i] += c
+	}
+	s.zero += other.zero
+	s.count += other.count
+	s.min = math.Min(s.min, other.min)
+	s.max = math.Max(s.max, other.max)
+}
+
+// Count returns the number of observations
+func (s *Sketch) Count() int64 {
+	return s.count
+}
+
+// Min returns the smallest observation, 0 if there are none
+func (s *Sketch) Min() float64 {
+	if s.count == 0 {
+		return 0
+	}
+	return s.min
+}
+
+// Max returns the largest observation, 0 if there are none
+func (s *Sketch) Max() float64 {
+	if s.count == 0 {
+		return 0
+	}
+	return s.max
+}
+
+// Quantile estimates the q-quantile, q is between 0 and 1
+func (s *Sketch) Quantile(q float64) float64 {
+	if s.count == 0 {
+		return 0
+	}
+	if q <= 0 {
+		return s.min
+	}
+	if q >= 1 {
+		return s.max
+	}
+	rank := int64(q * float64(s.count-1))
+	seen := int64(0)
+	// negative values in ascending order have descending indexes
+	for _, i := range sortedIndexes(s.negative, true) {
+		seen += s.negative[i]
+		if seen > rank {
+			return s.clamp(-s.value(i))
+		}
+	}
+	seen += s.zero
+	if seen > rank {
+		return 0
+	}
+	for _, i := range sortedIndexes(s.positive, false) {
+		seen += s.positive[i]
+		if seen > rank {
+			return s.clamp(s.value(i))
+		}
+	}
+	return s.max
+}
+
+func (s *Sketch) index(value float64) int {
+	return int(math.Ceil(math.Log(value) / s.logGamma))
+}
+
+func (s *Sketch) value(index int) float64 {
+	return 2 * math.Pow(s.gamma, float64(index)) / (s.gamma + 1)
+}
+
+func (s *Sketch) clamp(value float64) float64 {
+	return math.Max(s.min, math.Min(s.max, value))
+}
+
+func sortedIndexes(buckets map[int]int64, descending bool) []int {
+	indexes := make([]int, 0, len(buckets))
+	for i := range buckets {
+		indexes = append(indexes, i)
+	}
+	if descending {
+		sort.Sort(sort.Reverse(sort.IntSlice(indexes)))
+	} else {
+		sort.Ints(indexes)
+	}
+	return indexes
+}
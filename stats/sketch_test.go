@@ -0,0 +1,65 @@
+package stats_test
+
+import (
+	"testing"
+
+	"github.com/magneticio/vampkubistcli/stats"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSketch(t *testing.T) {
+	sketch := stats.NewSketch(0.01)
+	for i := 1; i <= 1000; i++ {
+		sketch.Add(float64(i))
+	}
+	assert.Equal(t, int64(1000), sketch.Count())
+	assert.Equal(t, 1.0, sketch.Min())
+	assert.Equal(t, 1000.0, sketch.Max())
+	assert.InEpsilon(t, 500, sketch.Quantile(0.5), 0.02)
+	assert.InEpsilon(t, 950, sketch.Quantile(0.95), 0.02)
+	assert.InEpsilon(t, 990, sketch.Quantile(0.99), 0.02)
+
+	negative := stats.NewSketch(0.01)
+	for i := -500; i < 500; i++ {
+		negative.Add(float64(i))
+	}
+	assert.InDelta(t, 0, negative.Quantile(0.5), 1)
+	assert.InEpsilon(t, -400, negative.Quantile(0.1), 0.02)
+	assert.Equal(t, 0.0, stats.NewSketch(0.01).Quantile(0.5))
+}
+
+func TestSketchMerge(t *testing.T) {
+	a := stats.NewSketch(0.01)
+	b := stats.NewSketch(0.01)
+	for i := 1; i <= 500; i++ {
+		a.Add(float64(i))
+		b.Add(float64(i + 500))
+	}
+	a.Merge(b)
+	assert.Equal(t, int64(1000), a.Count())
+	assert.Equal(t, 1000.0, a.Max())
+	assert.InEpsilon(t, 500, a.Quantile(0.5), 0.02)
+}
+
+func TestSlidingWindow(t *testing.T) {
+	window := stats.NewSlidingWindow(10000, 10, 0.01)
+	for i := int64(0); i < 100; i++ {
+		window.Add(i*100, float64(i))
+	}
+	value := window.MetricValue(10000)
+	assert.Equal(t, int64(100), value.NumberOfElements)
+	assert.InDelta(t, 49.5, value.Average, 1e-9)
+	assert.InDelta(t, 4950, value.Sum, 1e-9)
+	assert.Equal(t, 0.0, value.Min)
+	assert.Equal(t, 99.0, value.Max)
+	assert.InDelta(t, 10, value.Rate, 1e-9)
+	assert.InEpsilon(t, 95, value.P95, 0.02)
+
+	// first half of the observations is out of the window
+	value = window.MetricValue(15000)
+	assert.Equal(t, int64(50), value.NumberOfElements)
+	assert.Equal(t, 50.0, value.Min)
+
+	window.Expire(30000)
+	assert.True(t, window.Empty())
+}
@@ -0,0 +1,119 @@
+// Copyright © 2019 Developer developer@vamp.io
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stats
+
+import (
+	"github.com/magneticio/vampkubistcli/models"
+)
+
+// DefaultSlidingWindowBuckets is the number of buckets a sliding window is divided into
+const DefaultSlidingWindowBuckets = 12
+
+type slidingBucket struct {
+	accumulator Accumulator
+	sketch      *Sketch
+	sum         float64
+}
+
+/*
+SlidingWindow keeps observations of the last Size time units.
+The window is divided into buckets so expired observations can be dropped
+without keeping every observation, summaries merge the buckets in the window.
+*/
+type SlidingWindow struct {
+	Size       int64
+	bucketSize int64
+	accuracy   float64
+	buckets    map[int64]*slidingBucket
+}
+
+// NewSlidingWindow creates a sliding window of the given size divided into the given number of buckets
+func NewSlidingWindow(size int64, buckets int64, relativeAccuracy float64) *SlidingWindow {
+	if buckets < 1 {
+		buckets = DefaultSlidingWindowBuckets
+	}
+	bucketSize := size / buckets
+	if bucketSize < 1 {
+		bucketSize = 1
+	}
+	return &SlidingWindow{
+		Size:       size,
+		bucketSize: bucketSize,
+		accuracy:   relativeAccuracy,
+		buckets:    make(map[int64]*slidingBucket),
+	}
+}
+
+// Add adds an observation at the given timestamp
+func (w *SlidingWindow) Add(timestamp int64, value float64) {
+	start := timestamp - timestamp%w.bucketSize
+	bucket, ok := w.buckets[start]
+	if !ok {
+		bucket = &slidingBucket{sketch: NewSketch(w.accuracy)}
+		w.buckets[start] = bucket
+	}
+	bucket.accumulator.Add(value)
+	bucket.sketch.Add(value)
+	bucket.sum += value
+}
+
+// Expire drops buckets that are completely outside of the window ending at now
+func (w *SlidingWindow) Expire(now int64) {
+	for start := range w.buckets {
+		if start+w.bucketSize <= now-w.Size {
+			delete(w.buckets, start)
+		}
+	}
+}
+
+// Empty returns true if there are no observations in the window
+func (w *SlidingWindow) Empty() bool {
+	return len(w.buckets) == 0
+}
+
+/*
+MetricValue summarises observations in the window ending at now.
+Rate is the number of observations per second, timestamps are expected in milliseconds.
+*/
+func (w *SlidingWindow) MetricValue(now int64) models.MetricValue {
+	w.Expire(now)
+	var accumulator Accumulator
+	sketch := NewSketch(w.accuracy)
+	sum := 0.0
+	for _, bucket := range w.buckets {
+		accumulator.Merge(&bucket.accumulator)
+		sketch.Merge(bucket.sketch)
+		sum += bucket.sum
+	}
+	rate := 0.0
+	if w.Size > 0 {
+		rate = float64(accumulator.Count()) * 1000 / float64(w.Size)
+	}
+	return models.MetricValue{
+		Timestamp:         now,
+		NumberOfElements:  accumulator.Count(),
+		StandardDeviation: accumulator.StandardDeviation(),
+		Average:           accumulator.Mean(),
+		Sum:               sum,
+		Median:            sketch.Quantile(0.5),
+		Min:               sketch.Min(),
+		Max:               sketch.Max(),
+		Rate:              rate,
+		P999:              sketch.Quantile(0.999),
+		P99:               sketch.Quantile(0.99),
+		P95:               sketch.Quantile(0.95),
+		P75:               sketch.Quantile(0.75),
+	}
+}
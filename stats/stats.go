@@ -0,0 +1,239 @@
+// Copyright © 2019 Developer developer@vamp.io
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stats
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/magneticio/vampkubistcli/models"
+)
+
+// Sample summarises observations of a variant
+type Sample struct {
+	Mean              float64
+	StandardDeviation float64
+	Count             int64
+}
+
+// Comparison is the result of a Welch's t-test between a control and a variant
+type Comparison struct {
+	Difference         float64    `json:"difference"`
+	RelativeDifference float64    `json:"relativeDifference"`
+	EffectSize         float64    `json:"effectSize"`
+	T                  float64    `json:"t"`
+	DegreesOfFreedom   float64    `json:"degreesOfFreedom"`
+	PValue             float64    `json:"pValue"`
+	ConfidenceInterval [2]float64 `json:"confidenceInterval"`
+}
+
+// FromExperimentMetric converts an experiment metric to a sample
+func FromExperimentMetric(metric models.ExperimentMetric) Sample {
+	return Sample{
+		Mean:              metric.Average,
+		StandardDeviation: metric.StandardDeviation,
+		Count:             metric.NumberOfElements,
+	}
+}
+
+func (s Sample) variance() float64 {
+	return s.StandardDeviation * s.StandardDeviation
+}
+
+// StandardError returns the standard error of the mean
+func (s Sample) StandardError() float64 {
+	return s.StandardDeviation / math.Sqrt(float64(s.Count))
+}
+
+// ConfidenceInterval returns the t distribution based confidence interval of the mean
+func ConfidenceInterval(s Sample, confidence float64) (float64, float64, error) {
+	if err := validateProbability("confidence", confidence); err != nil {
+		return 0, 0, err
+	}
+	if s.Count < 2 {
+		return 0, 0, fmt.Errorf("At least 2 elements are required, sample has %v", s.Count)
+	}
+	margin := StudentTQuantile(1-(1-confidence)/2, float64(s.Count-1)) * s.StandardError()
+	return s.Mean - margin, s.Mean + margin, nil
+}
+
+/*
+WelchTTest compares the mean of a variant with the mean of a control
+without assuming equal variances.
+Difference, effect size and confidence interval are variant minus control.
+*/
+func WelchTTest(control Sample, variant Sample, confidence float64) (*Comparison, error) {
+	if err := validateProbability("confidence", confidence); err != nil {
+		return nil, err
+	}
+	if control.Count < 2 || variant.Count < 2 {
+		return nil, errors.New("At least 2 elements are required in each sample")
+	}
+	vc := control.variance() / float64(control.Count)
+	vv := variant.variance() / float64(variant.Count)
+	se := math.Sqrt(vc + vv)
+	res := &Comparison{
+		Difference: variant.Mean - control.Mean,
+	}
+	if control.Mean != 0 {
+		res.RelativeDifference = res.Difference / math.Abs(control.Mean)
+	}
+	if pooled := math.Sqrt((control.variance() + variant.variance()) / 2); pooled > 0 {
+		res.EffectSize = res.Difference / pooled
+	}
+	if se == 0 {
+		// no variance at all, means are either equal or certainly different
+		res.DegreesOfFreedom = float64(control.Count + variant.Count - 2)
+		res.PValue = 1
+		if res.Difference != 0 {
+			res.PValue = 0
+			res.T = math.Copysign(math.Inf(1), res.Difference)
+		}
+		res.ConfidenceInterval = [2]float64{res.Difference, res.Difference}
+		return res, nil
+	}
+	res.T = res.Difference / se
+	res.DegreesOfFreedom = (vc + vv) * (vc + vv) /
+		(vc*vc/float64(control.Count-1) + vv*vv/float64(variant.Count-1))
+	res.PValue = 2 * (1 - StudentTCDF(math.Abs(res.T), res.DegreesOfFreedom))
+	margin := StudentTQuantile(1-(1-confidence)/2, res.DegreesOfFreedom) * se
+	res.ConfidenceInterval = [2]float64{res.Difference - margin, res.Difference + margin}
+	return res, nil
+}
+
+/*
+RequiredSampleSize returns the number of elements needed in each sample
+to detect the given absolute difference of means with the given significance level and power.
+*/
+func RequiredSampleSize(control Sample, variant Sample, difference float64, alpha float64, power float64) (int64, error) {
+	if err := validateProbability("alpha", alpha); err != nil {
+		return 0, err
+	}
+	if err := validateProbability("power", power); err != nil {
+		return 0, err
+	}
+	if difference == 0 {
+		return 0, errors.New("Difference to detect can not be zero")
+	}
+	z := NormalQuantile(1-alpha/2) + NormalQuantile(power)
+	n := z * z * (control.variance() + variant.variance()) / (difference * difference)
+	return int64(math.Ceil(n)), nil
+}
+
+// NormalQuantile returns the inverse of the standard normal cumulative distribution function
+func NormalQuantile(p float64) float64 {
+	return math.Sqrt2 * math.Erfinv(2*p-1)
+}
+
+// StudentTCDF returns the cumulative distribution function of Student's t distribution
+func StudentTCDF(t float64, df float64) float64 {
+	if math.IsInf(t, 1) {
+		return 1
+	}
+	if math.IsInf(t, -1) {
+		return 0
+	}
+	tail := 0.5 * regularizedIncompleteBeta(df/(df+t*t), df/2, 0.5)
+	if t > 0 {
+		return 1 - tail
+	}
+	return tail
+}
+
+// StudentTQuantile returns the inverse of the cumulative distribution function of Student's t distribution
+func StudentTQuantile(p float64, df float64) float64 {
+	if p <= 0 {
+		return math.Inf(-1)
+	}
+	if p >= 1 {
+		return math.Inf(1)
+	}
+	// quantile is always wider than the normal one, start from there and expand
+	low, high := -1.0, 1.0
+	for StudentTCDF(low, df) > p {
+		low *= 2
+	}
+	for StudentTCDF(high, df) < p {
+		high *= 2
+	}
+	for i := 0; i < 200 && high-low > 1e-12; i++ {
+		mid := (low + high) / 2
+		if StudentTCDF(mid, df) < p {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+	return (low + high) / 2
+}
+
+/*
+regularizedIncompleteBeta is evaluated with a continued fraction
+as described in Numerical Recipes, chapter 6.4
+*/
+func regularizedIncompleteBeta(x float64, a float64, b float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	lgab, _ := math.Lgamma(a + b)
+	lga, _ := math.Lgamma(a)
+	lgb, _ := math.Lgamma(b)
+	front := math.Exp(lgab - lga - lgb + a*math.Log(x) + b*math.Log(1-x))
+	if x < (a+1)/(a+b+2) {
+		return front * betaContinuedFraction(x, a, b) / a
+	}
+	return 1 - front*betaContinuedFraction(1-x, b, a)/b
+}
+
+func betaContinuedFraction(x float64, a float64, b float64) float64 {
+	const maxIterations = 300
+	const epsilon = 3e-14
+	const minimum = 1e-300
+	clamp := func(v float64) float64 {
+		if math.Abs(v) < minimum {
+			return minimum
+		}
+		return v
+	}
+	c := 1.0
+	d := 1 / clamp(1-(a+b)*x/(a+1))
+	h := d
+	for m := 1.0; m <= maxIterations; m++ {
+		aa := m * (b - m) * x / ((a + 2*m - 1) * (a + 2*m))
+		d = 1 / clamp(1+aa*d)
+		c = clamp(1 + aa/c)
+		h *= d * c
+		aa = -(a + m) * (a + b + m) * x / ((a + 2*m) * (a + 2*m + 1))
+		d = 1 / clamp(1+aa*d)
+		c = clamp(1 + aa/c)
+		delta := d * c
+		h *= delta
+		if math.Abs(delta-1) < epsilon {
+			break
+		}
+	}
+	return h
+}
+
+func validateProbability(name string, p float64) error {
+	if p <= 0 || p >= 1 {
+		return fmt.Errorf("%v should be between 0 and 1", name)
+	}
+	return nil
+}
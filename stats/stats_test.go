@@ -0,0 +1,98 @@
+package stats_test
+
+import (
+	"testing"
+
+	"github.com/magneticio/vampkubistcli/models"
+	"github.com/magneticio/vampkubistcli/stats"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStudentT(t *testing.T) {
+	assert.InDelta(t, 0.963306, stats.StudentTCDF(2.0, 10), 1e-6)
+	assert.InDelta(t, 0.036694, stats.StudentTCDF(-2.0, 10), 1e-6)
+	assert.InDelta(t, 0.5, stats.StudentTCDF(0, 3), 1e-12)
+	assert.InDelta(t, 2.228139, stats.StudentTQuantile(0.975, 10), 1e-6)
+	assert.InDelta(t, 2.042272, stats.StudentTQuantile(0.975, 30), 1e-6)
+	assert.InDelta(t, -2.042272, stats.StudentTQuantile(0.025, 30), 1e-6)
+	assert.InDelta(t, 1.959964, stats.NormalQuantile(0.975), 1e-6)
+}
+
+func TestConfidenceInterval(t *testing.T) {
+	sample := stats.FromExperimentMetric(models.ExperimentMetric{Average: 10, StandardDeviation: 2, NumberOfElements: 11})
+	low, high, err := stats.ConfidenceInterval(sample, 0.95)
+	assert.NoError(t, err)
+	// 2.228139 * 2 / sqrt(11)
+	assert.InDelta(t, 10-1.343618, low, 1e-5)
+	assert.InDelta(t, 10+1.343618, high, 1e-5)
+
+	_, _, err = stats.ConfidenceInterval(stats.Sample{Mean: 1, Count: 1}, 0.95)
+	assert.Error(t, err)
+}
+
+func TestWelchTTest(t *testing.T) {
+	control := stats.Sample{Mean: 10, StandardDeviation: 2, Count: 30}
+	variant := stats.Sample{Mean: 11, StandardDeviation: 2.5, Count: 30}
+	res, err := stats.WelchTTest(control, variant, 0.95)
+	assert.NoError(t, err)
+	assert.InDelta(t, 1, res.Difference, 1e-12)
+	assert.InDelta(t, 0.1, res.RelativeDifference, 1e-12)
+	assert.InDelta(t, 0.441726, res.EffectSize, 1e-6)
+	assert.InDelta(t, 1.710800, res.T, 1e-5)
+	assert.InDelta(t, 55.33, res.DegreesOfFreedom, 1e-2)
+	assert.InDelta(t, 0.0927, res.PValue, 1e-3)
+	assert.True(t, res.ConfidenceInterval[0] < 0 && res.ConfidenceInterval[1] > 0)
+}
+
+func TestRequiredSampleSize(t *testing.T) {
+	sample := stats.Sample{Mean: 10, StandardDeviation: 2, Count: 30}
+	// (1.959964 + 0.841621)^2 * 8 / 1
+	n, err := stats.RequiredSampleSize(sample, sample, 1, 0.05, 0.8)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(63), n)
+
+	_, err = stats.RequiredSampleSize(sample, sample, 0, 0.05, 0.8)
+	assert.Error(t, err)
+}
+
+func TestCompare(t *testing.T) {
+	control := stats.Variant{Name: "subset1", Sample: stats.Sample{Mean: 0.10, StandardDeviation: 0.30, Count: 5000}}
+	better := stats.Variant{Name: "subset2", Sample: stats.Sample{Mean: 0.13, StandardDeviation: 0.336, Count: 5000}}
+	same := stats.Variant{Name: "subset3", Sample: stats.Sample{Mean: 0.10, StandardDeviation: 0.30, Count: 5000}}
+
+	res, err := stats.Compare(control, []stats.Variant{better, same}, stats.DefaultCompareOptions)
+	assert.NoError(t, err)
+	assert.Equal(t, stats.Winner, res.Decision)
+	assert.Equal(t, "subset2", res.Winner)
+	assert.True(t, res.Variants[0].Significant)
+	assert.False(t, res.Variants[1].Significant)
+
+	options := stats.DefaultCompareOptions
+	options.LowerIsBetter = true
+	res, err = stats.Compare(control, []stats.Variant{better}, options)
+	assert.NoError(t, err)
+	assert.Equal(t, stats.Winner, res.Decision)
+	assert.Equal(t, "subset1", res.Winner)
+
+	options = stats.DefaultCompareOptions
+	options.MinimumDetectableEffect = 0.2
+	res, err = stats.Compare(control, []stats.Variant{same}, options)
+	assert.NoError(t, err)
+	assert.Equal(t, stats.NoDifference, res.Decision)
+
+	small := stats.Variant{Name: "subset2", Sample: stats.Sample{Mean: 0.11, StandardDeviation: 0.31, Count: 100}}
+	res, err = stats.Compare(stats.Variant{Name: "subset1", Sample: stats.Sample{Mean: 0.10, StandardDeviation: 0.30, Count: 100}}, []stats.Variant{small}, options)
+	assert.NoError(t, err)
+	assert.Equal(t, stats.KeepRunning, res.Decision)
+	assert.True(t, res.Variants[0].RequiredSampleSize > 100)
+
+	// an effect relative to a control mean of 0 falls back to the observed difference
+	zero := stats.Variant{Name: "subset1", Sample: stats.Sample{Mean: 0, StandardDeviation: 0.30, Count: 100}}
+	res, err = stats.Compare(zero, []stats.Variant{small}, options)
+	assert.NoError(t, err)
+	assert.True(t, res.Variants[0].RequiredSampleSize > 0)
+
+	options.Power = 0
+	_, err = stats.Compare(control, []stats.Variant{same}, options)
+	assert.Error(t, err)
+}
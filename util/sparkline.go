@@ -0,0 +1,49 @@
+package util
+
+import (
+	"math"
+	"strings"
+)
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+/*
+Sparkline renders values as a line of block characters scaled between the smallest and the largest value.
+If there are more values than width, consecutive values are averaged into width characters.
+*/
+func Sparkline(values []float64, width int) string {
+	if len(values) == 0 {
+		return ""
+	}
+	if width > 0 && len(values) > width {
+		values = downsample(values, width)
+	}
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		min = math.Min(min, v)
+		max = math.Max(max, v)
+	}
+	var line strings.Builder
+	for _, v := range values {
+		i := 0
+		if max > min {
+			i = int(math.Round((v - min) / (max - min) * float64(len(sparks)-1)))
+		}
+		line.WriteRune(sparks[i])
+	}
+	return line.String()
+}
+
+func downsample(values []float64, width int) []float64 {
+	res := make([]float64, width)
+	for i := range res {
+		start := i * len(values) / width
+		end := (i + 1) * len(values) / width
+		sum := 0.0
+		for _, v := range values[start:end] {
+			sum += v
+		}
+		res[i] = sum / float64(end-start)
+	}
+	return res
+}
@@ -0,0 +1,15 @@
+package util_test
+
+import (
+	"testing"
+
+	"github.com/magneticio/vampkubistcli/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSparkline(t *testing.T) {
+	assert.Equal(t, "▁▂▃▄▅▆▇█", util.Sparkline([]float64{0, 1, 2, 3, 4, 5, 6, 7}, 0))
+	assert.Equal(t, "▁▁▁", util.Sparkline([]float64{5, 5, 5}, 10))
+	assert.Equal(t, "▁█", util.Sparkline([]float64{1, 1, 3, 3}, 2))
+	assert.Equal(t, "", util.Sparkline(nil, 10))
+}
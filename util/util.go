@@ -170,6 +170,19 @@ func VerifyCertForHost(resourceUrl string, cert string) error {
 	return err
 }
 
+// CertificateExpiry returns the time after which the certificate is no longer valid
+func CertificateExpiry(cert string) (time.Time, error) {
+	block, _ := pem.Decode([]byte(cert))
+	if block == nil {
+		return time.Time{}, errors.New("failed to decode certificate")
+	}
+	crt, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return crt.NotAfter, nil
+}
+
 func GetParameterFromTerminalAsSecret(text1 string, text2 string, errorText string) (string, error) {
 	fmt.Println(text1)
 	byteInput1, errInput1 := terminal.ReadPassword(int(syscall.Stdin))
@@ -1,10 +1,13 @@
 package util_test
 
 import (
+	"net"
 	"testing"
+	"time"
 
 	"github.com/magneticio/vampkubistcli/util"
 	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/util/cert"
 )
 
 func TestMergeBasic(t *testing.T) {
@@ -67,3 +70,15 @@ func TestMergeMapInMap(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, expectedMerged, merged)
 }
+
+func TestCertificateExpiry(t *testing.T) {
+	crt, _, err := cert.GenerateSelfSignedCertKey("vamp.example.com", []net.IP{}, []string{})
+	assert.NoError(t, err)
+
+	expiry, err := util.CertificateExpiry(string(crt))
+	assert.NoError(t, err)
+	assert.True(t, expiry.After(time.Now().Add(300*24*time.Hour)))
+
+	_, err = util.CertificateExpiry("not a certificate")
+	assert.Error(t, err)
+}